@@ -0,0 +1,343 @@
+package analytics
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// every report accepts ?from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week|month
+
+func (h *Handler) GetSales(w http.ResponseWriter, r *http.Request) {
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := h.db.GetSalesSeries(r.Context(), repo.GetSalesSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching sales analytics")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := SalesReport{Period: period, Series: []SalesPoint{}}
+	for _, d := range data {
+		s := Sales{
+			Transactions:   d.Transactions,
+			GrossRevenue:   d.GrossRevenue,
+			Refunds:        d.Refunds,
+			RefundedAmount: d.RefundedAmount,
+			NetRevenue:     d.GrossRevenue - d.RefundedAmount,
+		}
+		res.Series = append(res.Series, SalesPoint{Bucket: d.Bucket, Sales: s})
+
+		res.Totals.Transactions += s.Transactions
+		res.Totals.GrossRevenue += s.GrossRevenue
+		res.Totals.Refunds += s.Refunds
+		res.Totals.RefundedAmount += s.RefundedAmount
+		res.Totals.NetRevenue += s.NetRevenue
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := h.db.GetPaymentStatusSeries(r.Context(), repo.GetPaymentStatusSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching payment analytics")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := PaymentReport{Period: period, Totals: map[string]PaymentTotal{}, Series: []PaymentPoint{}}
+	for _, d := range data {
+		// rows are ordered by bucket, a new bucket starts a new point
+		if n := len(res.Series); n == 0 || !res.Series[n-1].Bucket.Equal(d.Bucket) {
+			res.Series = append(res.Series, PaymentPoint{Bucket: d.Bucket, Statuses: map[string]PaymentTotal{}})
+		}
+		res.Series[len(res.Series)-1].Statuses[d.Status] = PaymentTotal{Payments: d.Payments, Amount: d.Amount}
+
+		t := res.Totals[d.Status]
+		t.Payments += d.Payments
+		t.Amount += d.Amount
+		res.Totals[d.Status] = t
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	newUsers, err := h.db.GetNewUsersSeries(ctx, repo.GetNewUsersSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching new users analytics")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	active, err := h.db.GetActiveStudentsSeries(ctx, repo.GetActiveStudentsSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching active students analytics")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	// distinct students over the whole period, not the sum of every bucket
+	totalActive, err := h.db.CountActiveStudents(ctx, repo.CountActiveStudentsParams{
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error counting active students")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := UsersReport{Period: period, NewUsers: map[string]int64{}, ActiveStudents: totalActive}
+
+	points := map[time.Time]*UsersPoint{}
+	point := func(bucket time.Time) *UsersPoint {
+		if p, ok := points[bucket]; ok {
+			return p
+		}
+		p := &UsersPoint{Bucket: bucket, NewUsers: map[string]int64{}}
+		points[bucket] = p
+		return p
+	}
+
+	for _, d := range newUsers {
+		point(d.Bucket).NewUsers[d.Role] = d.Users
+		res.NewUsers[d.Role] += d.Users
+	}
+	for _, d := range active {
+		point(d.Bucket).ActiveStudents = d.Students
+	}
+
+	res.Series = make([]UsersPoint, 0, len(points))
+	for _, p := range points {
+		res.Series = append(res.Series, *p)
+	}
+	sort.Slice(res.Series, func(i, j int) bool { return res.Series[i].Bucket.Before(res.Series[j].Bucket) })
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetEnrollments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	series, err := h.db.GetEnrollmentSeries(ctx, repo.GetEnrollmentSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching enrollment analytics")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	byCourse, err := h.db.GetEnrollmentsByCourse(ctx, repo.GetEnrollmentsByCourseParams{
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching enrollments by course")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	byCategory, err := h.db.GetEnrollmentsByCategory(ctx, repo.GetEnrollmentsByCategoryParams{
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching enrollments by category")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := EnrollmentReport{
+		Period:     period,
+		Series:     []EnrollmentPoint{},
+		ByCourse:   []CourseEnrollment{},
+		ByCategory: []CategoryEnrollment{},
+	}
+	for _, d := range series {
+		res.Series = append(res.Series, EnrollmentPoint{Bucket: d.Bucket, Enrollments: d.Enrollments})
+		res.Total += d.Enrollments
+	}
+	for _, d := range byCourse {
+		res.ByCourse = append(res.ByCourse, CourseEnrollment{
+			CourseID:     d.CourseID,
+			CourseName:   d.CourseName,
+			CategoryName: d.CategoryName,
+			Enrollments:  d.Enrollments,
+		})
+	}
+	for _, d := range byCategory {
+		res.ByCategory = append(res.ByCategory, CategoryEnrollment{
+			CategoryID:   d.CategoryID,
+			CategoryName: d.CategoryName,
+			Enrollments:  d.Enrollments,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetFunnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	cart, err := h.db.GetCartFunnelSeries(ctx, repo.GetCartFunnelSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching cart funnel")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	wishlist, err := h.db.GetWishlistFunnelSeries(ctx, repo.GetWishlistFunnelSeriesParams{
+		Bucket:   period.Interval,
+		FromDate: util.SqlTime(period.From),
+		ToDate:   util.SqlTime(period.End()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching wishlist funnel")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := FunnelReport{
+		Period:   period,
+		Cart:     Funnel{Series: []ConversionPoint{}},
+		Wishlist: Funnel{Series: []ConversionPoint{}},
+	}
+	for _, d := range cart {
+		res.Cart.add(d.Bucket, d.Added, d.Purchased)
+	}
+	for _, d := range wishlist {
+		res.Wishlist.add(d.Bucket, d.Added, d.Purchased)
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetCartRecovery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	period, ok := parsePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	totals, err := h.db.GetCartRecoveryTotals(ctx, repo.GetCartRecoveryTotalsParams{
+		FromDate: period.From,
+		ToDate:   period.End(),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching cart recovery totals")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	series, err := h.db.GetCartRecoverySeries(ctx, repo.GetCartRecoverySeriesParams{
+		Bucket:   period.Interval,
+		FromDate: period.From,
+		ToDate:   period.End(),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching cart recovery series")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := CartRecoveryReport{
+		Period: period,
+		Totals: newCartRecovery(totals.Reminders, totals.Clicked, totals.Carts, totals.Recovered),
+		Series: []CartRecoveryPoint{},
+	}
+	for _, d := range series {
+		res.Series = append(res.Series, CartRecoveryPoint{
+			Bucket:       d.Bucket,
+			CartRecovery: newCartRecovery(d.Reminders, d.Clicked, d.Carts, d.Recovered),
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func newCartRecovery(reminders, clicked, carts, recovered int64) CartRecovery {
+	c := CartRecovery{Reminders: reminders, Clicked: clicked, Carts: carts, Recovered: recovered}
+	if carts > 0 {
+		c.Rate = float64(recovered) / float64(carts)
+	}
+	return c
+}
+
+func (f *Funnel) add(bucket time.Time, added, purchased int64) {
+	f.Series = append(f.Series, ConversionPoint{Bucket: bucket, Conversion: newConversion(added, purchased)})
+	f.Conversion = newConversion(f.Added+added, f.Purchased+purchased)
+}
+
+func newConversion(added, purchased int64) Conversion {
+	c := Conversion{Added: added, Purchased: purchased}
+	if added > 0 {
+		c.Rate = float64(purchased) / float64(added)
+	}
+	return c
+}
+
+func parsePeriod(w http.ResponseWriter, r *http.Request) (util.DateRange, bool) {
+	period, err := util.ParseDateRange(r.URL.Query(), "day")
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation(err.Error()))
+		return period, false
+	}
+	return period, true
+}
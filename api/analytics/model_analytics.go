@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+)
+
+type (
+	Sales struct {
+		Transactions   int64 `json:"transactions"`
+		GrossRevenue   int64 `json:"gross_revenue"`
+		Refunds        int64 `json:"refunds"`
+		RefundedAmount int64 `json:"refunded_amount"`
+		NetRevenue     int64 `json:"net_revenue"` // gross revenue minus refunded amount
+	}
+
+	SalesPoint struct {
+		Bucket time.Time `json:"bucket"`
+		Sales
+	}
+
+	SalesReport struct {
+		Period util.DateRange `json:"period"`
+		Totals Sales          `json:"totals"`
+		Series []SalesPoint   `json:"series"`
+	}
+
+	PaymentTotal struct {
+		Payments int64 `json:"payments"`
+		Amount   int64 `json:"amount"`
+	}
+
+	PaymentPoint struct {
+		Bucket   time.Time               `json:"bucket"`
+		Statuses map[string]PaymentTotal `json:"statuses"` // keyed by payment status name, e.g. paid, pending, failed
+	}
+
+	PaymentReport struct {
+		Period util.DateRange          `json:"period"`
+		Totals map[string]PaymentTotal `json:"totals"`
+		Series []PaymentPoint          `json:"series"`
+	}
+
+	UsersPoint struct {
+		Bucket         time.Time        `json:"bucket"`
+		NewUsers       map[string]int64 `json:"new_users"` // keyed by role
+		ActiveStudents int64            `json:"active_students"`
+	}
+
+	// UsersReport counts new accounts and active students, a student is active when they
+	// enrolled in or rated a course during the period
+	UsersReport struct {
+		Period         util.DateRange   `json:"period"`
+		NewUsers       map[string]int64 `json:"new_users"`
+		ActiveStudents int64            `json:"active_students"`
+		Series         []UsersPoint     `json:"series"`
+	}
+
+	EnrollmentPoint struct {
+		Bucket      time.Time `json:"bucket"`
+		Enrollments int64     `json:"enrollments"`
+	}
+
+	CourseEnrollment struct {
+		CourseID     int32  `json:"course_id"`
+		CourseName   string `json:"course_name"`
+		CategoryName string `json:"category_name"`
+		Enrollments  int64  `json:"enrollments"`
+	}
+
+	CategoryEnrollment struct {
+		CategoryID   int32  `json:"category_id"`
+		CategoryName string `json:"category_name"`
+		Enrollments  int64  `json:"enrollments"`
+	}
+
+	EnrollmentReport struct {
+		Period     util.DateRange       `json:"period"`
+		Total      int64                `json:"total"`
+		Series     []EnrollmentPoint    `json:"series"`
+		ByCourse   []CourseEnrollment   `json:"by_course"`
+		ByCategory []CategoryEnrollment `json:"by_category"`
+	}
+
+	Conversion struct {
+		Added     int64   `json:"added"`
+		Purchased int64   `json:"purchased"`
+		Rate      float64 `json:"rate"` // purchased / added, between 0 and 1
+	}
+
+	ConversionPoint struct {
+		Bucket time.Time `json:"bucket"`
+		Conversion
+	}
+
+	Funnel struct {
+		Conversion
+		Series []ConversionPoint `json:"series"`
+	}
+
+	// FunnelReport measure how many courses added to a cart or a wishlist during the period were bought
+	FunnelReport struct {
+		Period   util.DateRange `json:"period"`
+		Cart     Funnel         `json:"cart"`
+		Wishlist Funnel         `json:"wishlist"`
+	}
+
+	CartRecovery struct {
+		Reminders int64   `json:"reminders"`
+		Clicked   int64   `json:"clicked"`
+		Carts     int64   `json:"carts"` // distinct carts that got a reminder
+		Recovered int64   `json:"recovered"`
+		Rate      float64 `json:"rate"` // recovered / carts, between 0 and 1
+	}
+
+	CartRecoveryPoint struct {
+		Bucket time.Time `json:"bucket"`
+		CartRecovery
+	}
+
+	// CartRecoveryReport measure how many carts reminded during the period were bought afterwards
+	CartRecoveryReport struct {
+		Period util.DateRange      `json:"period"`
+		Totals CartRecovery        `json:"totals"`
+		Series []CartRecoveryPoint `json:"series"`
+	}
+)
@@ -0,0 +1,141 @@
+package auditlog
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// GetAuditLogs search the audit log, newest first,
+// ?actor_id=&resource=&resource_id=&action=&from=&to=&page=&limit=
+// from and to are RFC3339 times or YYYY-MM-DD dates, a `to` date includes the whole day
+func (h *Handler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > maxAuditLimit {
+		limit = defaultAuditLimit
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	params := repo.SearchAuditLogsParams{
+		Limit:        int32(limit),
+		Offset:       int32((page - 1) * limit),
+		ResourceType: nullString(query.Get("resource")),
+		ResourceID:   nullString(query.Get("resource_id")),
+		Action:       nullString(query.Get("action")),
+	}
+
+	if v := query.Get("actor_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			apperr.WriteError(w, r, apperr.Validation("Invalid actor_id"))
+			return
+		}
+		params.ActorID = sql.NullInt32{Int32: int32(id), Valid: true}
+	}
+
+	var err error
+	if params.FromTime, err = parseTime(query.Get("from"), false); err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid from, "+err.Error()))
+		return
+	}
+	if params.ToTime, err = parseTime(query.Get("to"), true); err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid to, "+err.Error()))
+		return
+	}
+	if params.FromTime.Valid && params.ToTime.Valid && !params.FromTime.Time.Before(params.ToTime.Time) {
+		apperr.WriteError(w, r, apperr.Validation("from must be before to"))
+		return
+	}
+
+	data, err := h.db.SearchAuditLogs(r.Context(), params)
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error searching audit logs")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []AuditLog{}
+	for _, d := range data {
+		res = append(res, toAuditLog(d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid audit log ID"))
+		return
+	}
+
+	d, err := h.db.GetAuditLog(r.Context(), id)
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Audit log not found"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching audit log")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toAuditLog(d)).WriteResponse(w, r)
+}
+
+// parseTime read an RFC3339 time or a YYYY-MM-DD date, the end of a range is exclusive so an
+// end date moves to the start of the next day
+func parseTime(v string, end bool) (sql.NullTime, error) {
+	if v == "" {
+		return sql.NullTime{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		// created_at holds the wall clock of the server
+		return sql.NullTime{Time: t.Local(), Valid: true}, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return sql.NullTime{}, errors.New("use RFC3339 or YYYY-MM-DD")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+func toAuditLog(d repo.AuditLog) AuditLog {
+	return AuditLog{
+		AuditID:      d.AuditID,
+		ActorID:      d.ActorID.Int32,
+		ActorRole:    d.ActorRole,
+		Action:       d.Action,
+		ResourceType: d.ResourceType,
+		ResourceID:   d.ResourceID,
+		Before:       d.Before,
+		After:        d.After,
+		IP:           d.Ip,
+		RequestID:    d.RequestID,
+		CreatedAt:    d.CreatedAt,
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
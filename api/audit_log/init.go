@@ -0,0 +1,13 @@
+package auditlog
+
+import (
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+type Handler struct {
+	db *repo.Queries
+}
+
+func NewHandler(db *repo.Queries) *Handler {
+	return &Handler{db}
+}
@@ -0,0 +1,22 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog is a change made by an admin, a teacher or a payment, Before and After hold the
+// fields that changed
+type AuditLog struct {
+	AuditID      int64           `json:"audit_id"`
+	ActorID      int32           `json:"actor_id,omitempty"`
+	ActorRole    string          `json:"actor_role"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	IP           string          `json:"ip"`
+	RequestID    string          `json:"request_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
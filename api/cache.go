@@ -0,0 +1,35 @@
+package api
+
+import (
+	"time"
+
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	catalogCacheEnabled = true
+	catalogCacheTTL     = 5 * time.Minute
+	catalogCacheMaxAge  = time.Minute
+)
+
+// SetCatalogCacheConfig set how long the public catalog is kept in redis and how long clients may
+// reuse a response before revalidating it with its ETag
+func SetCatalogCacheConfig(enabled bool, ttl, maxAge time.Duration) {
+	catalogCacheEnabled = enabled
+	if ttl > 0 {
+		catalogCacheTTL = ttl
+	}
+	if maxAge >= 0 {
+		catalogCacheMaxAge = maxAge
+	}
+}
+
+// newCatalogCache is nil when the cache is disabled, the catalog is then read from the database
+// on every request
+func newCatalogCache(rdb *redis.Client) *cache.Cache {
+	if !catalogCacheEnabled || rdb == nil {
+		return nil
+	}
+	return cache.New(rdb, catalogCacheTTL, catalogCacheMaxAge)
+}
@@ -3,13 +3,15 @@ package cart
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
@@ -19,22 +21,32 @@ func (h *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
 	var req CartRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
-	// Calculate TotalAmount
-	totalAmount := req.Price * req.Quantity
+	// Only courses visible in the catalog can be bought, at their current price
+	course, err := h.db.GetCourseByID(ctx, repo.GetCourseByIDParams{Now: time.Now(), CourseID: req.CourseID})
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	// Calculate TotalAmount, the price sent by the client is ignored
+	price := course.EffectivePrice
+	totalAmount := price * req.Quantity
 
 	// Save Cart item to database
 	err = h.db.CreateCart(ctx, repo.CreateCartParams{
@@ -47,7 +59,7 @@ func (h *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
 			Valid: req.CourseID != 0,
 		},
 		Price: sql.NullInt32{
-			Int32: req.Price,
+			Int32: price,
 			Valid: true,
 		},
 		Quantity: sql.NullInt32{
@@ -58,12 +70,12 @@ func (h *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
 			Int32: totalAmount,
 			Valid: true,
 		},
+		CreatedAt: util.SqlTime(time.Now()),
 	})
 
 	if err != nil {
-		log.Println("error storing cart item to db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error storing cart item to db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
@@ -76,8 +88,8 @@ func (h *Handler) CreateCart(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetAllCart(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllCart(r.Context())
 	if err != nil {
-		log.Println("error fetching all cart items:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all cart items")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -123,25 +135,28 @@ func (h *Handler) GetCartByUserID(w http.ResponseWriter, r *http.Request) {
 	// Ambil user_id dari parameter URL atau query string
 	userID := r.Context().Value("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	// Panggil metode yang mengeksekusi query GetCartByUserID
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
 	// Panggil metode yang mengeksekusi query GetCartByUserID
-	data, err := h.db.GetCartByUserID(r.Context(), sql.NullInt32{
-		Int32: int32(userIDInt),
-		Valid: true,
+	data, err := h.db.GetCartByUserID(r.Context(), repo.GetCartByUserIDParams{
+		PricedAt: time.Now(),
+		UserID: sql.NullInt32{
+			Int32: int32(userIDInt),
+			Valid: true,
+		},
 	})
 	if err != nil {
-		log.Println("error fetching cart data:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching cart data")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -152,9 +167,9 @@ func (h *Handler) GetCartByUserID(w http.ResponseWriter, r *http.Request) {
 			CourseID:    c.CourseID.Int32,
 			Thumbnail:   c.Thumbnail.String,
 			CourseName:  c.CourseName.String,
-			Price:       c.Price.Int32,
+			Price:       c.Price,
 			Quantity:    c.Quantity.Int32,
-			TotalAmount: c.TotalAmount.Int32,
+			TotalAmount: c.TotalAmount,
 		})
 	}
 
@@ -166,14 +181,14 @@ func (h *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 	// Ambil user_id dari r.Context().Value
 	userID := r.Context().Value("user_id")
 	if userID == nil {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	// Cek apakah userID bisa di-cast ke tipe int32
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
@@ -181,7 +196,7 @@ func (h *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 	courseID := chi.URLParam(r, "course_id")
 	courseIDInt, error := strconv.Atoi(courseID)
 	if error != nil {
-		http.Error(w, "Invalid course id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid course id"))
 		return
 	}
 
@@ -191,8 +206,8 @@ func (h *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 		CourseID: util.SqlInt32(int32(courseIDInt)),
 	})
 	if err != nil {
-		log.Println("error deleting cart item:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error deleting cart item")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -0,0 +1,28 @@
+package cart
+
+import (
+	"net/http"
+
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/cartreminder"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// ReturnToCart is the link of a cart reminder, the click is recorded for the
+// recovery analytics before redirecting to the checkout page
+func (h *Handler) ReturnToCart(w http.ResponseWriter, r *http.Request) {
+	err := h.reminder.Click(r.Context(), r.URL.Query().Get("token"))
+	if err == cartreminder.ErrInvalidToken {
+		apperr.WriteError(w, r, apperr.Validation("Invalid or expired link"))
+		return
+	} else if err != nil {
+		// the user still gets to the checkout
+		logger.Err(r.Context(), err).Msg("error recording cart reminder click")
+	}
+
+	if cartreminder.CheckoutURL() == "" {
+		apperr.WriteError(w, r, apperr.NotFound("404 Not found!"))
+		return
+	}
+	http.Redirect(w, r, cartreminder.CheckoutURL(), http.StatusFound)
+}
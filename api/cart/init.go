@@ -3,13 +3,15 @@ package cart
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/cartreminder"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	reminder *cartreminder.Reminder
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, reminder *cartreminder.Reminder) *Handler {
+	return &Handler{validate, db, reminder}
 }
@@ -4,18 +4,22 @@ import (
 	"database/sql"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path"
 	"strconv"
-	"strings"
 	"time"
 
+	"context"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 // CreateCategory handles the creation of a new category
@@ -25,8 +29,8 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	err := r.ParseMultipartForm(10 << 20) // batasan ukuran file (10MB)
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
@@ -36,8 +40,8 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	// Ambil file icon dari form
 	file, handler, err := r.FormFile("icon")
 	if err != nil {
-		log.Printf("error retrieving the file: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error retrieving the file", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the file"))
 		return
 	}
 	defer file.Close()
@@ -48,15 +52,15 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	iconPath := path.Join(publicPath, handler.Filename)
 	dst, err := os.Create(iconPath)
 	if err != nil {
-		log.Printf("error saving the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error saving the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error saving the file")
+		apperr.WriteError(w, r, apperr.Internal("Error saving the file"))
 		return
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, file); err != nil {
-		log.Printf("error copying the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error copying the file")
+		apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 		return
 	}
 
@@ -64,30 +68,34 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	req.Icon = handler.Filename
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	now := time.Now()
 	// Save category data to the database
-	err = h.db.CreateCategory(r.Context(), repo.CreateCategoryParams{
-		CategoryName: req.CategoryName,
-		Icon:         util.SqlString(path.Join("static", "category", handler.Filename)).String, // Save the path to the icon
-		CreatedAt:    sql.NullTime{Time: now, Valid: true},
-		UpdatedAt:    sql.NullTime{Time: now, Valid: true},
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		category, err := q.CreateCategory(r.Context(), repo.CreateCategoryParams{
+			CategoryName: req.CategoryName,
+			Icon:         util.SqlString(path.Join("static", "category", handler.Filename)).String, // Save the path to the icon
+			CreatedAt:    sql.NullTime{Time: now, Valid: true},
+			UpdatedAt:    sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCategoryCreate,
+			Resource:   constant.AuditCategory,
+			ResourceID: category.CategoryID,
+			After:      category,
+		}, nil
 	})
-
 	if err != nil {
-		log.Printf("error storing category to db: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating category", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error storing category to db")
+		apperr.WriteError(w, r, apperr.Internal("Error creating category"))
 		return
 	}
 
@@ -97,28 +105,35 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 		"icon":          req.Icon,
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagCategories)
+
 	util.NewResponse(http.StatusOK, http.StatusOK, "Category created successfully", responseData).WriteResponse(w, r)
 }
 
 // GetAllCategories handles retrieving all categories
 func (h *Handler) GetAllCategories(w http.ResponseWriter, r *http.Request) {
-	data, err := h.db.GetAllCategories(r.Context())
+	e, err := h.cache.Load(r.Context(), "public:category", []string{cache.TagCategories}, func(ctx context.Context) (any, error) {
+		data, err := h.db.GetAllCategories(ctx)
+		if err != nil {
+			return nil, apperr.Internal("Internal server error").Wrap(err)
+		}
+
+		var res []Category
+		for _, d := range data {
+			res = append(res, Category{
+				CategoryID:   d.CategoryID,
+				CategoryName: d.CategoryName,
+				Icon:         d.Icon,
+			})
+		}
+		return res, nil
+	})
 	if err != nil {
-		log.Println("error fetching all categories:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apperr.WriteError(w, r, err)
 		return
 	}
 
-	var res []Category
-	for _, d := range data {
-		res = append(res, Category{
-			CategoryID:   d.CategoryID,
-			CategoryName: d.CategoryName,
-			Icon:         d.Icon,
-		})
-	}
-
-	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+	h.cache.Write(w, r, e)
 }
 
 func (h *Handler) GetCoursesByCategoryID(w http.ResponseWriter, r *http.Request) {
@@ -128,8 +143,8 @@ func (h *Handler) GetCoursesByCategoryID(w http.ResponseWriter, r *http.Request)
 	// Convert categoryID to int32
 	categoryID, err := strconv.Atoi(categoryIDParam)
 	if err != nil {
-		log.Println("invalid category ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid category ID", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("invalid category ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category ID"))
 		return
 	}
 
@@ -138,13 +153,13 @@ func (h *Handler) GetCoursesByCategoryID(w http.ResponseWriter, r *http.Request)
 		Valid: true,
 	}
 
-	course, err := h.db.GetCoursesByCategoryID(r.Context(), nullCategoryID)
+	course, err := h.db.GetCoursesByCategoryID(r.Context(), repo.GetCoursesByCategoryIDParams{Now: time.Now(), CategoryID: nullCategoryID})
 	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "No courses found for this category", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.NotFound("No courses found for this category"))
 		return
 	} else if err != nil {
-		log.Println("error fetching courses by category ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching courses by category ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -156,7 +171,7 @@ func (h *Handler) GetCoursesByCategoryID(w http.ResponseWriter, r *http.Request)
 			CourseName:        c.CourseName,
 			CourseDescription: c.CourseDescription,
 			CategoryID:        c.CategoryID.Int32,
-			Price:             float64(c.Price),
+			Price:             float64(c.EffectivePrice),
 			Thumbnail:         sql.NullString{String: c.Thumbnail.String, Valid: true},
 			CreatedAt:         c.CreatedAt.Time,
 			UpdatedAt:         c.UpdatedAt.Time,
@@ -171,8 +186,8 @@ func (h *Handler) GetCoursesByCategoryID(w http.ResponseWriter, r *http.Request)
 func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllCategories(r.Context()) // Adjust the method name according to your actual implementation
 	if err != nil {
-		log.Println("error fetching all categories:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all categories")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -197,19 +212,19 @@ func (h *Handler) GetCategoryByID(w http.ResponseWriter, r *http.Request) {
 	// Convert categoryID to int32
 	id, err := strconv.Atoi(categoryID)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
 	// Get the category from the database by ID
 	data, err := h.db.GetCategoryByID(r.Context(), int32(id))
 	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Invalid ID", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.NotFound("Category not found"))
 		return
 	} else if err != nil {
-		log.Println("error fetching category by ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching category by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -231,53 +246,69 @@ func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 	// 	// Get the category ID from the URL parameters
 	categoryID := chi.URLParam(r, "id")
 	if categoryID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Category ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Category ID is required"))
 		return
 	}
 
 	// Convert categoryID to int32
 	CategoryID, err := strconv.Atoi(categoryID)
 	if err != nil {
-		log.Println("invalid category ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid category ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid category ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category ID"))
 		return
 	}
 
 	var req CategoryRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validation request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	now := time.Now()
 	// Update the category in the database
-	err = h.db.UpdateCategory(ctx, repo.UpdateCategoryParams{
-		CategoryID:   int32(CategoryID),
-		CategoryName: req.CategoryName,
-		Icon:         req.Icon,
-		UpdatedAt:    sql.NullTime{Time: now, Valid: true},
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		category, err := q.GetCategoryByID(ctx, int32(CategoryID))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		updated := category
+		updated.CategoryName, updated.Icon = req.CategoryName, req.Icon
+		updated.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+		err = q.UpdateCategory(ctx, repo.UpdateCategoryParams{
+			CategoryID:   category.CategoryID,
+			CategoryName: updated.CategoryName,
+			Icon:         updated.Icon,
+			UpdatedAt:    updated.UpdatedAt,
+			CategoryID_2: category.CategoryID,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCategoryUpdate,
+			Resource:   constant.AuditCategory,
+			ResourceID: category.CategoryID,
+			Before:     category,
+			After:      updated,
+		}, nil
 	})
-
 	if err != nil {
-		log.Println("error updating category in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Category"))
 		return
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagCategories)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Category updated successfully"
@@ -292,31 +323,171 @@ func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	// Get the category ID from the URL parameters
 	categoryID := chi.URLParam(r, "id")
 	if categoryID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Category ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Category ID is required"))
 		return
 	}
 
 	// Convert categoryID to int32
 	id, err := strconv.Atoi(categoryID)
 	if err != nil {
-		log.Println("invalid category ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid category ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid category ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category ID"))
 		return
 	}
 
-	// Delete the category from the database
-	err = h.db.DeleteCategory(ctx, int32(id))
+	// Soft delete the category
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		category, err := q.GetCategoryByID(ctx, int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		deleted := category
+		deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+		affected, err := q.DeleteCategory(ctx, repo.DeleteCategoryParams{
+			CategoryID: category.CategoryID,
+			DeletedAt:  deleted.DeletedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCategoryDelete,
+			Resource:   constant.AuditCategory,
+			ResourceID: category.CategoryID,
+			Before:     category,
+			After:      deleted,
+		}, nil
+	})
 	if err != nil {
-		log.Println("error deleting category from db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Category"))
 		return
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagCategories)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Category deleted successfully"
 	resp.WriteResponse(w, r)
 }
+
+// GetDeletedCategories handles listing soft deleted categories
+func (h *Handler) GetDeletedCategories(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetDeletedCategories(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching deleted categories")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []Category{}
+	for _, d := range data {
+		res = append(res, Category{
+			CategoryID:   d.CategoryID,
+			CategoryName: d.CategoryName,
+			Icon:         d.Icon,
+			CreatedAt:    d.CreatedAt.Time,
+			UpdatedAt:    d.UpdatedAt.Time,
+			DeletedAt:    d.DeletedAt,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// RestoreCategory handles restoring a soft deleted category
+func (h *Handler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid category ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category ID"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		category, err := q.GetDeletedCategory(r.Context(), int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		restored := category
+		restored.DeletedAt, restored.UpdatedAt = sql.NullTime{}, sql.NullTime{Time: time.Now(), Valid: true}
+
+		affected, err := q.RestoreCategory(r.Context(), repo.RestoreCategoryParams{
+			CategoryID: category.CategoryID,
+			UpdatedAt:  restored.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCategoryRestore,
+			Resource:   constant.AuditCategory,
+			ResourceID: category.CategoryID,
+			Before:     category,
+			After:      restored,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted category"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCategories)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Category restored successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// PurgeCategory handles permanently removing a soft deleted category, refused while any course,
+// deleted or not, still belongs to it
+func (h *Handler) PurgeCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid category ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category ID"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		category, err := q.GetDeletedCategory(r.Context(), int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+
+		courses, err := q.CountCoursesByCategory(r.Context(), util.SqlInt32(category.CategoryID))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if courses > 0 {
+			return audit.Entry{}, apperr.Conflict(fmt.Sprintf("Category is used by %d courses", courses)).WithCode("category_in_use")
+		}
+
+		affected, err := q.PurgeCategory(r.Context(), category.CategoryID)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCategoryPurge,
+			Resource:   constant.AuditCategory,
+			ResourceID: category.CategoryID,
+			Before:     category,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted category"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCategories)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Category purged successfully", struct{}{}).WriteResponse(w, r)
+}
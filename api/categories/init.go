@@ -3,13 +3,17 @@ package categories
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	cache    *cache.Cache
+	audit    *audit.Log
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, cache *cache.Cache, audit *audit.Log) *Handler {
+	return &Handler{validate, db, cache, audit}
 }
@@ -2,19 +2,24 @@ package courses
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path"
 	"strconv"
 	"time"
 
+	"context"
 	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/middleware/auth"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
@@ -23,8 +28,8 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	err := r.ParseMultipartForm(10 << 20) // 10MB limit
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
@@ -37,8 +42,8 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	categoryIDStr := r.FormValue("category_id")
 	categoryID, err := strconv.Atoi(categoryIDStr)
 	if err != nil {
-		log.Printf("error converting category_id to int32: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid category_id", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error converting category_id to int32")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category_id"))
 		return
 	}
 	req.CategoryID = int32(categoryID) // Convert int to int32
@@ -47,8 +52,8 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	priceStr := r.FormValue("price")
 	price, err := strconv.ParseFloat(priceStr, 64)
 	if err != nil {
-		log.Printf("error converting price to float64: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid price", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error converting price to float64")
+		apperr.WriteError(w, r, apperr.Validation("Invalid price"))
 		return
 	}
 	req.Price = int32(price)
@@ -56,8 +61,8 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	// Ambil file thumbnail dari form
 	file, handler, err := r.FormFile("thumbnail")
 	if err != nil {
-		log.Printf("error retrieving the file: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error retrieving the file", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the file"))
 		return
 	}
 	defer file.Close()
@@ -65,31 +70,31 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	// Simpan file thumbnail
 	basePath, err := os.Getwd()
 	if err != nil {
-		log.Printf("error getting current working directory: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error getting working directory", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error getting current working directory")
+		apperr.WriteError(w, r, apperr.Internal("Error getting working directory"))
 		return
 	}
 	publicPath := path.Join(basePath, "public", "course")
 	thumbnailPath := path.Join(publicPath, handler.Filename)
 	dst, err := os.Create(thumbnailPath)
 	if err != nil {
-		log.Printf("error creating file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error creating file")
+		apperr.WriteError(w, r, apperr.Internal("Error creating file"))
 		return
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, file); err != nil {
-		log.Printf("error copying the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error copying the file")
+		apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 		return
 	}
 
 	// Ambil file video dari form
 	fileVideo, videoHandler, err := r.FormFile("video")
 	if err != nil {
-		log.Printf("error retrieving the file: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error retrieving the video", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the video"))
 		return
 	}
 	defer fileVideo.Close()
@@ -99,64 +104,28 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 	videoPath := path.Join(publicPath, videoHandler.Filename)
 	videoDst, err := os.Create(videoPath)
 	if err != nil {
-		log.Printf("error creating file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error creating file")
+		apperr.WriteError(w, r, apperr.Internal("Error creating file"))
 		return
 	}
 	defer videoDst.Close()
 
 	if _, err := io.Copy(videoDst, fileVideo); err != nil {
-		log.Printf("error copying the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error copying the file")
+		apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
-	now := time.Now()
-	// Save course data to the database
-	err = h.db.CreateCourse(ctx, repo.CreateCourseParams{
-		CourseName:        req.CourseName,
-		CourseDescription: req.CourseDescription,
-		CategoryID:        util.SqlInt32(req.CategoryID),
-		Price:             req.Price,
-		Thumbnail:         util.SqlString(path.Join("static", "course", handler.Filename)),
-		DeletedAt:         sql.NullTime{},
-		CreatedAt:         sql.NullTime{Time: now, Valid: true},
-		UpdatedAt:         sql.NullTime{Time: now, Valid: true},
-	})
-
-	if err != nil {
-		log.Println("error storing course to db:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating course", struct{}{}).WriteResponse(w, r)
-		return
-	}
+	userID, _ := ctx.Value("user_id").(int32)
 
-	// Get final course id
-	courseID, err := h.db.GetLastCourseID(ctx)
-	if err != nil {
-		log.Println("error getting last course id:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating course", struct{}{}).WriteResponse(w, r)
-	}
-
-	// Save course video to the database
-	err = h.db.CreateCourseVideo(ctx, repo.CreateCourseVideoParams{
-		CourseID:        util.SqlInt32(courseID),
-		CourseVideoName: req.CourseName,
-		PathVideo:       path.Join("static", "video", "course", videoHandler.Filename),
-		CreatedAt:       util.SqlTime(time.Now()),
-		UpdatedAt:       util.SqlTime(time.Now()),
-	})
-	if err != nil {
-		log.Println("error storing course video to db:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating course video", struct{}{}).WriteResponse(w, r)
-		return
-	}
+	now := time.Now()
 
 	// Create a response with the course data
 	responseData := map[string]interface{}{
@@ -166,8 +135,72 @@ func (h *Handler) CreateCourses(w http.ResponseWriter, r *http.Request) {
 		"price":              req.Price,
 		"thumbnail":          path.Join("static", "course", handler.Filename),
 		"video":              path.Join("static", "video", "course", videoHandler.Filename),
+		"status":             constant.CourseDraft,
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		// Save course data to the database, new courses stay hidden until an admin approves them
+		err := q.CreateCourse(ctx, repo.CreateCourseParams{
+			CourseName:        req.CourseName,
+			CourseDescription: req.CourseDescription,
+			CategoryID:        util.SqlInt32(req.CategoryID),
+			Price:             req.Price,
+			Thumbnail:         util.SqlString(path.Join("static", "course", handler.Filename)),
+			DeletedAt:         sql.NullTime{},
+			CreatedAt:         sql.NullTime{Time: now, Valid: true},
+			UpdatedAt:         sql.NullTime{Time: now, Valid: true},
+			UserID:            util.SqlInt32(userID),
+			Status:            constant.CourseDraft,
+		})
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("store course: %w", err)
+		}
+
+		// Get final course id
+		courseID, err := q.GetLastCourseID(ctx)
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("get last course id: %w", err)
+		}
+
+		// Save course video to the database
+		_, err = q.CreateCourseVideo(ctx, repo.CreateCourseVideoParams{
+			CourseID:        util.SqlInt32(courseID),
+			CourseVideoName: req.CourseName,
+			PathVideo:       path.Join("static", "video", "course", videoHandler.Filename),
+			CreatedAt:       util.SqlTime(now),
+			UpdatedAt:       util.SqlTime(now),
+		})
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("store course video: %w", err)
+		}
+
+		responseData["course_id"] = courseID
+		return audit.Entry{
+			Action:     constant.AuditCourseCreate,
+			Resource:   constant.AuditCourse,
+			ResourceID: courseID,
+			After:      responseData,
+		}, nil
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating course")
+		apperr.WriteError(w, r, apperr.Internal("Error creating course"))
+		return
 	}
 
+	// Course detail links to the owner profile, make sure there is one
+	if auth.HasPermission(ctx, auth.PermTeacherProfile) {
+		err = h.db.EnsureTeacherProfile(ctx, repo.EnsureTeacherProfileParams{
+			UserID:    userID,
+			CreatedAt: util.SqlTime(now),
+		})
+		if err != nil {
+			logger.Err(ctx, err).Msg("error creating teacher profile")
+		}
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCourses)
+
 	util.NewResponse(http.StatusOK, http.StatusOK, "Course created successfully", responseData).WriteResponse(w, r)
 }
 
@@ -176,20 +209,20 @@ func (h *Handler) GetMyCourse(w http.ResponseWriter, r *http.Request) {
 	courseIDParam := chi.URLParam(r, "course_id")
 	courseID, err := strconv.ParseInt(courseIDParam, 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
 		return
 	}
 
 	// Extract user ID from the token
 	userIDContext := r.Context().Value("user_id")
 	if userIDContext == nil {
-		http.Error(w, "User ID missing", http.StatusUnauthorized)
+		apperr.WriteError(w, r, apperr.Unauthorized("User ID missing"))
 		return
 	}
 
 	userID, ok := userIDContext.(int32)
 	if !ok {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user ID"))
 		return
 	}
 
@@ -202,10 +235,10 @@ func (h *Handler) GetMyCourse(w http.ResponseWriter, r *http.Request) {
 	// Call the method that executes the GetMyCourse query
 	data, err := h.db.GetMyCourse(r.Context(), params)
 	if err == sql.ErrNoRows {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
 		return
 	} else if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		apperr.WriteError(w, r, apperr.Internal("Internal Server Error"))
 		return
 	}
 
@@ -225,38 +258,43 @@ func (h *Handler) GetMyCourse(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetAllCourses(w http.ResponseWriter, r *http.Request) {
-	data, err := h.db.GetAllCourse(r.Context())
-	if err != nil {
-		log.Println("error fetching all courses:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	var res []Course
-	for _, c := range data {
-		// Convert sql.NullString to string
-		thumbnail := ""
-		if c.Thumbnail.Valid {
-			thumbnail = c.Thumbnail.String
+	e, err := h.cache.Load(r.Context(), "public:getall-course", []string{cache.TagCourses}, func(ctx context.Context) (any, error) {
+		data, err := h.db.GetAllCourse(ctx, time.Now())
+		if err != nil {
+			return nil, apperr.Internal("Internal server error").Wrap(err)
 		}
 
-		// Convert sql.NullInt32 to int32
-		categoryID := int32(0)
-		if c.CategoryID.Valid {
-			categoryID = c.CategoryID.Int32
+		var res []Course
+		for _, c := range data {
+			// Convert sql.NullString to string
+			thumbnail := ""
+			if c.Thumbnail.Valid {
+				thumbnail = c.Thumbnail.String
+			}
+
+			// Convert sql.NullInt32 to int32
+			categoryID := int32(0)
+			if c.CategoryID.Valid {
+				categoryID = c.CategoryID.Int32
+			}
+
+			res = append(res, Course{
+				CourseID:          c.CourseID,
+				CourseName:        c.CourseName,
+				CourseDescription: c.CourseDescription,
+				CategoryID:        categoryID, // Use converted value
+				Price:             c.EffectivePrice,
+				Thumbnail:         thumbnail, // Use converted value
+			})
 		}
-
-		res = append(res, Course{
-			CourseID:          c.CourseID,
-			CourseName:        c.CourseName,
-			CourseDescription: c.CourseDescription,
-			CategoryID:        categoryID, // Use converted value
-			Price:             c.Price,
-			Thumbnail:         thumbnail, // Use converted value
-		})
+		return res, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, err)
+		return
 	}
 
-	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+	h.cache.Write(w, r, e)
 }
 
 func (h *Handler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
@@ -264,16 +302,19 @@ func (h *Handler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
 	courseIDParam := chi.URLParam(r, "course_id")
 	courseID, err := strconv.Atoi(courseIDParam)
 	if err != nil {
-		log.Println("invalid course ID:", err)
-		http.Error(w, "Invalid course ID", http.StatusBadRequest)
+		logger.Warn(r.Context(), err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
 		return
 	}
 
 	// Fetch the course by ID from the database
-	c, err := h.db.GetCourseByID(r.Context(), int32(courseID))
-	if err != nil {
-		log.Println("error fetching course by ID:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	c, err := h.db.GetCourseByID(r.Context(), repo.GetCourseByIDParams{Now: time.Now(), CourseID: int32(courseID)})
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching course by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -295,21 +336,33 @@ func (h *Handler) GetCourseByID(w http.ResponseWriter, r *http.Request) {
 		CourseName:        c.CourseName,
 		CourseDescription: c.CourseDescription,
 		CategoryID:        categoryID,
-		Price:             c.Price,
+		Price:             c.EffectivePrice,
 		Thumbnail:         thumbnail,
 		Video:             c.PathVideo.String,
 	}
 
+	teacher, err := h.db.GetCourseTeacher(r.Context(), c.CourseID)
+	if err == nil {
+		course.Teacher = &CourseTeacher{
+			TeacherID:   teacher.TeacherID,
+			TeacherName: teacher.TeacherName,
+			Headline:    teacher.Headline.String,
+			Avatar:      teacher.Avatar.String,
+		}
+	} else if err != sql.ErrNoRows {
+		logger.Err(r.Context(), err).Msg("error fetching course teacher")
+	}
+
 	// Send the response
 	util.NewResponse(http.StatusOK, http.StatusOK, "", course).WriteResponse(w, r)
 }
 
 func (h *Handler) GetCoursePrice(w http.ResponseWriter, r *http.Request) {
 	// Call the method that executes the GetCoursePrice query
-	data, err := h.db.GetCoursePrice(r.Context())
+	data, err := h.db.GetCoursePrice(r.Context(), time.Now())
 	if err != nil {
-		log.Println("error fetching courses by price:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching courses by price")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -328,25 +381,32 @@ func (h *Handler) GetCoursePrice(w http.ResponseWriter, r *http.Request) {
 	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
 }
 
+// GetPopularCourses is cached until a course changes or the cache ttl, new enrollments only
+// show up after the latter
 func (h *Handler) GetPopularCourses(w http.ResponseWriter, r *http.Request) {
-	data, err := h.db.GetPopularCourse(r.Context())
+	e, err := h.cache.Load(r.Context(), "public:popular", []string{cache.TagCourses}, func(ctx context.Context) (any, error) {
+		data, err := h.db.GetPopularCourse(ctx)
+		if err != nil {
+			return nil, apperr.Internal("Internal server error").Wrap(err)
+		}
+
+		var res []GetPopularCourseRow
+		for _, course := range data {
+			res = append(res, GetPopularCourseRow{
+				CourseID:         course.CourseID,
+				CourseName:       course.CourseName,
+				TotalEnrollments: course.TotalEnrollments,
+				Thumbnail:        course.Thumbnail.String,
+			})
+		}
+		return res, nil
+	})
 	if err != nil {
-		log.Println("error fetching popular courses:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apperr.WriteError(w, r, err)
 		return
 	}
 
-	var res []GetPopularCourseRow
-	for _, course := range data {
-		res = append(res, GetPopularCourseRow{
-			CourseID:         course.CourseID,
-			CourseName:       course.CourseName,
-			TotalEnrollments: course.TotalEnrollments,
-			Thumbnail:        course.Thumbnail.String,
-		})
-	}
-
-	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+	h.cache.Write(w, r, e)
 }
 
 func (h *Handler) GetMyCoursePage(w http.ResponseWriter, r *http.Request) {
@@ -354,22 +414,22 @@ func (h *Handler) GetMyCoursePage(w http.ResponseWriter, r *http.Request) {
 	userIDContext := r.Context().Value("user_id")
 
 	if userIDContext == nil {
-		http.Error(w, "User ID incorrect", http.StatusUnauthorized)
+		apperr.WriteError(w, r, apperr.Unauthorized("User ID incorrect"))
 		return
 	}
 
 	userID, ok := userIDContext.(int32)
 	if !ok {
-		log.Println("invalid user ID")
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		logger.FromContext(r.Context()).Warn().Msg("invalid user ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid user ID"))
 		return
 	}
 
 	// Call the method that executes the GetMyCoursePage query
 	data, err := h.db.GetAllMyCourse(r.Context(), util.SqlInt32(userID))
 	if err != nil {
-		log.Println("error fetching my courses:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching my courses")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -394,25 +454,38 @@ func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 	courseID := chi.URLParam(r, "id")
 	resp := util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Bad request", struct{}{})
 	if courseID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Course ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Course ID is required"))
 		return
 	}
 
 	// Convert courseID to int32
 	id, err := strconv.Atoi(courseID)
 	if err != nil {
-		log.Println("invalid course ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid course ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	// Get current data, only the owner may change it
+	course, err := h.db.GetCourseForUpdate(r.Context(), int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
 		return
 	}
 
 	// Parse form data
 	err = r.ParseMultipartForm(10 << 20) // 10MB limit
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
@@ -425,8 +498,8 @@ func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 	categoryIDStr := r.FormValue("category_id")
 	categoryID, err := strconv.Atoi(categoryIDStr)
 	if err != nil {
-		log.Printf("error converting category_id to int32: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid category_id", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error converting category_id to int32")
+		apperr.WriteError(w, r, apperr.Validation("Invalid category_id"))
 		return
 	}
 	req.CategoryID = int32(categoryID) // Convert int to int32
@@ -435,17 +508,16 @@ func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 	priceStr := r.FormValue("price")
 	price, err := strconv.ParseFloat(priceStr, 64)
 	if err != nil {
-		log.Printf("error converting price to float64: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid price", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error converting price to float64")
+		apperr.WriteError(w, r, apperr.Validation("Invalid price"))
 		return
 	}
 	req.Price = int32(price)
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
@@ -460,23 +532,23 @@ func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 		// Simpan file thumbnail
 		basePath, err := os.Getwd()
 		if err != nil {
-			log.Printf("error getting current working directory: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error getting working directory", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error getting current working directory")
+			apperr.WriteError(w, r, apperr.Internal("Error getting working directory"))
 			return
 		}
 		publicPath := path.Join(basePath, "public", "course")
 		thumbnailPath := path.Join(publicPath, handler.Filename)
 		dst, err := os.Create(thumbnailPath)
 		if err != nil {
-			log.Printf("error creating file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating file", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error creating file")
+			apperr.WriteError(w, r, apperr.Internal("Error creating file"))
 			return
 		}
 		defer dst.Close()
 
 		if _, err := io.Copy(dst, file); err != nil {
-			log.Printf("error copying the file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error copying the file")
+			apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 			return
 		}
 
@@ -496,88 +568,96 @@ func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
 		// Simpan file thumbnail
 		basePath, err := os.Getwd()
 		if err != nil {
-			log.Printf("error getting current working directory: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error getting working directory", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error getting current working directory")
+			apperr.WriteError(w, r, apperr.Internal("Error getting working directory"))
 			return
 		}
 		publicPath := path.Join(basePath, "public", "video", "course")
 		videoPath := path.Join(publicPath, videoHandler.Filename)
 		videoDst, err := os.Create(videoPath)
 		if err != nil {
-			log.Printf("error creating file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating file", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error creating file")
+			apperr.WriteError(w, r, apperr.Internal("Error creating file"))
 			return
 		}
 		defer videoDst.Close()
 
 		if _, err := io.Copy(videoDst, fileVideo); err != nil {
-			log.Printf("error copying the file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error copying the file")
+			apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 			return
 		}
 
 		videoFilePath = path.Join("static", "video", "course", videoHandler.Filename)
 	}
 
-	// Get current data
-	course, err := h.db.GetCourseByID(r.Context(), int32(id))
-	if err != nil {
-		log.Println("error getting course in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
-		return
-	}
-
 	if !fileExist {
 		filePath = course.Thumbnail.String
 	}
 
-	fmt.Println(filePath)
-	fmt.Println(id)
+	updated := course
+	updated.CourseName = req.CourseName
+	updated.CourseDescription = req.CourseDescription
+	updated.CategoryID = util.SqlInt32(req.CategoryID)
+	updated.Price = req.Price
+	updated.Thumbnail = util.SqlString(filePath)
+	updated.UpdatedAt = util.SqlTime(time.Now())
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		// Update the course in the database
+		err := q.UpdateCourse(r.Context(), repo.UpdateCourseParams{
+			CourseID:          updated.CourseID,
+			CourseName:        updated.CourseName,
+			CourseDescription: updated.CourseDescription,
+			CategoryID:        updated.CategoryID,
+			Price:             updated.Price,
+			Thumbnail:         updated.Thumbnail,
+			UpdatedAt:         updated.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("update course: %w", err)
+		}
 
-	// Update the course in the database
-	err = h.db.UpdateCourse(r.Context(), repo.UpdateCourseParams{
-		CourseID:          int32(id),
-		CourseName:        req.CourseName,
-		CourseDescription: req.CourseDescription,
-		CategoryID:        util.SqlInt32(int32(id)),
-		Price:             req.Price,
-		Thumbnail:         util.SqlString(filePath),
-		UpdatedAt:         util.SqlTime(time.Now()),
-	})
-	if err != nil {
-		log.Println("error updating course in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
-		return
-	}
+		videoCourse, err := q.GetCourseVideoByCourseID(r.Context(), util.SqlInt32(course.CourseID))
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("get course video: %w", err)
+		}
 
-	videoCourse, err := h.db.GetCourseVideoByCourseID(r.Context(), util.SqlInt32(course.CourseID))
-	if err != nil {
-		log.Println("error getting course video in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
-		return
-	}
+		if !videoFileExist {
+			videoFilePath = videoCourse.PathVideo
+		}
 
-	if !videoFileExist {
-		videoFilePath = videoCourse.PathVideo
-	}
+		err = q.UpdateCourseVideo(r.Context(), repo.UpdateCourseVideoParams{
+			CourseID:        util.SqlInt32(course.CourseID),
+			CourseVideoName: videoCourse.CourseVideoName,
+			PathVideo:       videoFilePath,
+			UpdatedAt:       updated.UpdatedAt,
+			CourseVideoID:   videoCourse.CourseVideoID,
+		})
+		if err != nil {
+			return audit.Entry{}, fmt.Errorf("update course video: %w", err)
+		}
 
-	err = h.db.UpdateCourseVideo(r.Context(), repo.UpdateCourseVideoParams{
-		CourseID:        util.SqlInt32(course.CourseID),
-		CourseVideoName: videoCourse.CourseVideoName,
-		PathVideo:       videoFilePath,
-		UpdatedAt:       util.SqlTime(time.Now()),
-		CourseVideoID:   videoCourse.CourseVideoID,
+		return audit.Entry{
+			Action:     constant.AuditCourseUpdate,
+			Resource:   constant.AuditCourse,
+			ResourceID: course.CourseID,
+			Before:     course,
+			After:      updated,
+		}, nil
 	})
 	if err != nil {
-		log.Println("error updating course video in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error updating course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
+	if req.Price != course.Price {
+		h.checkPrice(r, course.CourseID)
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCourses)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Course updated successfully"
@@ -591,93 +671,218 @@ func (h *Handler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 	// Get the course ID from the URL parameters
 	courseID := chi.URLParam(r, "id")
 	if courseID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Course ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Course ID is required"))
 		return
 	}
 
 	// Convert courseID to int32
 	id, err := strconv.Atoi(courseID)
 	if err != nil {
-		log.Println("invalid course ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid course ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
 		return
 	}
 
-	// Delete the course from the database
-	err = h.db.DeleteCourse(ctx, int32(id))
+	course, err := h.db.GetCourseForUpdate(ctx, int32(id))
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course"))
+		return
+	}
+	if !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	// Soft delete the course, students who bought it keep their access
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		deleted := course
+		deleted.DeletedAt = util.SqlTime(time.Now())
+		affected, err := q.DeleteCourse(ctx, repo.DeleteCourseParams{
+			CourseID:  course.CourseID,
+			DeletedAt: deleted.DeletedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, apperr.NotFound("Course not found")
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseDelete,
+			Resource:   constant.AuditCourse,
+			ResourceID: course.CourseID,
+			Before:     course,
+			After:      deleted,
+		}, nil
+	})
 	if err != nil {
-		log.Println("error deleting course from db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course"))
 		return
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagCourses)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Course deleted successfully"
 	resp.WriteResponse(w, r)
 }
 
-func (h *Handler) GetCourseByNew(w http.ResponseWriter, r *http.Request) {
-	// Use the existing connection pool instead of opening a new connection
-	db, err := sql.Open("postgres", "user=postgres password=25112004 dbname=postgres sslmode=disable")
+func (h *Handler) GetDeletedCourses(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetDeletedCourses(r.Context())
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		log.Println("Database connection error:", err)
+		logger.Err(r.Context(), err).Msg("error fetching deleted courses")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
-	defer db.Close()
 
-	// Query to fetch courses ordered by creation date (newest first)
-	query := `
-		SELECT course_id, category_id, course_name, course_description, price, thumbnail, created_at, deleted_at, updated_at 
-		FROM courses 
-		ORDER BY created_at DESC
-	`
-	rows, err := db.Query(query)
+	res := []Course{}
+	for _, c := range data {
+		res = append(res, Course{
+			CourseID:          c.CourseID,
+			CourseName:        c.CourseName,
+			CourseDescription: c.CourseDescription,
+			CategoryID:        c.CategoryID.Int32,
+			Price:             c.Price,
+			Thumbnail:         c.Thumbnail.String,
+			CreatedAt:         c.CreatedAt,
+			DeletedAt:         c.DeletedAt,
+			UpdatedAt:         c.UpdatedAt,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) RestoreCourse(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		http.Error(w, "Query execution error", http.StatusInternalServerError)
-		log.Println("Query execution error:", err)
-		return
-	}
-	defer rows.Close()
-
-	var courses []Course
-
-	// Iterate over the rows and add each course to the slice
-	for rows.Next() {
-		var course Course
-		if err := rows.Scan(
-			&course.CourseID,
-			&course.CategoryID,
-			&course.CourseName,
-			&course.CourseDescription,
-			&course.Price,
-			&course.Thumbnail,
-			&course.CreatedAt,
-			&course.DeletedAt,
-			&course.UpdatedAt,
-		); err != nil {
-			http.Error(w, "Error scanning course data", http.StatusInternalServerError)
-			log.Println("Error scanning course data:", err)
-			return
+		logger.Warn(r.Context(), err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		course, err := q.GetDeletedCourse(r.Context(), int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		restored := course
+		restored.DeletedAt, restored.UpdatedAt = sql.NullTime{}, util.SqlTime(time.Now())
+
+		affected, err := q.RestoreCourse(r.Context(), repo.RestoreCourseParams{
+			CourseID:  course.CourseID,
+			UpdatedAt: restored.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
 		}
-		courses = append(courses, course)
+		return audit.Entry{
+			Action:     constant.AuditCourseRestore,
+			Resource:   constant.AuditCourse,
+			ResourceID: course.CourseID,
+			Before:     course,
+			After:      restored,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted course"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCourses)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Course restored successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// PurgeCourse permanently remove a soft deleted course and its videos,
+// refused while any student is still subscribed to it
+func (h *Handler) PurgeCourse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
 	}
 
-	// Check for any errors that occurred during iteration
-	if err := rows.Err(); err != nil {
-		http.Error(w, "Rows iteration error", http.StatusInternalServerError)
-		log.Println("Rows iteration error:", err)
+	total, err := h.db.CountCourseSubscriptions(ctx, util.SqlInt32(int32(id)))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error counting course subscriptions")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if total > 0 {
+		apperr.WriteError(w, r, apperr.Conflict("Course has subscribed students and can only be archived"))
 		return
 	}
 
-	// Convert courses slice to JSON and send it as a response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(courses); err != nil {
-		http.Error(w, "Error encoding response to JSON", http.StatusInternalServerError)
-		log.Println("Error encoding response to JSON:", err)
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		course, err := q.GetDeletedCourse(ctx, int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		affected, err := q.PurgeCourse(ctx, course.CourseID)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCoursePurge,
+			Resource:   constant.AuditCourse,
+			ResourceID: course.CourseID,
+			Before:     course,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted course"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagCourses)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Course purged successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// GetCourseByNew list the published courses, newest first. The list is sent without the
+// response envelope
+func (h *Handler) GetCourseByNew(w http.ResponseWriter, r *http.Request) {
+	e, err := h.cache.Load(r.Context(), "public:home", []string{cache.TagCourses}, func(ctx context.Context) (any, error) {
+		data, err := h.db.GetCourseByNew(ctx, time.Now())
+		if err != nil {
+			return nil, apperr.Internal("Internal server error").Wrap(err)
+		}
+
+		var courses []Course
+		for _, c := range data {
+			courses = append(courses, Course{
+				CourseID:          c.CourseID,
+				CategoryID:        c.CategoryID.Int32,
+				CourseName:        c.CourseName,
+				CourseDescription: c.CourseDescription,
+				Price:             c.EffectivePrice,
+				Thumbnail:         c.Thumbnail.String,
+				CreatedAt:         c.CreatedAt,
+				DeletedAt:         c.DeletedAt,
+				UpdatedAt:         c.UpdatedAt,
+			})
+		}
+		return courses, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, err)
+		return
 	}
+
+	if h.cache.NotModified(w, r, e) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(e.Body)
 }
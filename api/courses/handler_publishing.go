@@ -0,0 +1,284 @@
+package courses
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// transition describe a single step of the publishing workflow
+type transition struct {
+	from      []string
+	to        string
+	ownerOnly bool // teachers may only move their own courses, admins may move any course
+	message   string
+	action    string // of the audit log
+}
+
+var (
+	submitTransition = transition{
+		from:      []string{constant.CourseDraft, constant.CourseRejected, constant.CourseUnpublished},
+		to:        constant.CourseInReview,
+		ownerOnly: true,
+		message:   "Course submitted for review",
+		action:    constant.AuditCourseSubmit,
+	}
+	approveTransition = transition{
+		from:    []string{constant.CourseInReview},
+		to:      constant.CoursePublished,
+		message: "Course published successfully",
+		action:  constant.AuditCourseApprove,
+	}
+	rejectTransition = transition{
+		from:    []string{constant.CourseInReview},
+		to:      constant.CourseRejected,
+		message: "Course rejected",
+		action:  constant.AuditCourseReject,
+	}
+	unpublishTransition = transition{
+		from:      []string{constant.CoursePublished},
+		to:        constant.CourseUnpublished,
+		ownerOnly: true,
+		message:   "Course unpublished successfully",
+		action:    constant.AuditCourseUnpublish,
+	}
+)
+
+// GetTeacherCourses list every course owned by the logged in teacher, whatever its status
+func (h *Handler) GetTeacherCourses(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(int32)
+
+	data, err := h.db.GetCoursesByOwner(r.Context(), util.SqlInt32(userID))
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching teacher courses")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toCourses(data)).WriteResponse(w, r)
+}
+
+// GetReviewQueue list courses waiting for an admin decision, oldest submission first
+func (h *Handler) GetReviewQueue(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetCoursesByStatus(r.Context(), constant.CourseInReview)
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching courses in review")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toCourses(data)).WriteResponse(w, r)
+}
+
+// GetPublicationLog return the review history of a course, including admin comments
+func (h *Handler) GetPublicationLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	course, err := h.db.GetCourseForUpdate(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	data, err := h.db.GetCoursePublicationLog(ctx, course.CourseID)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching course publication log")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []PublicationLog{}
+	for _, l := range data {
+		res = append(res, PublicationLog{
+			LogID:      l.LogID,
+			CourseID:   l.CourseID,
+			UserID:     l.UserID,
+			UserName:   l.Nama.String,
+			FromStatus: l.FromStatus,
+			ToStatus:   l.ToStatus,
+			Comment:    l.Comment.String,
+			CreatedAt:  l.CreatedAt,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) SubmitCourse(w http.ResponseWriter, r *http.Request) {
+	var req PublicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	h.changeStatus(w, r, submitTransition, req.Comment)
+}
+
+func (h *Handler) ApproveCourse(w http.ResponseWriter, r *http.Request) {
+	var req PublicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	h.changeStatus(w, r, approveTransition, req.Comment)
+}
+
+func (h *Handler) RejectCourse(w http.ResponseWriter, r *http.Request) {
+	var req RejectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	h.changeStatus(w, r, rejectTransition, req.Comment)
+}
+
+// UnpublishCourse hide a course from the catalog, subscribed students keep their access
+func (h *Handler) UnpublishCourse(w http.ResponseWriter, r *http.Request) {
+	var req PublicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	h.changeStatus(w, r, unpublishTransition, req.Comment)
+}
+
+func (h *Handler) changeStatus(w http.ResponseWriter, r *http.Request, t transition, comment string) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	course, err := h.db.GetCourseForUpdate(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if t.ownerOnly && !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		now := time.Now()
+		// the status is checked again by the update so two concurrent reviews can not both win
+		affected, err := q.UpdateCourseStatus(ctx, repo.UpdateCourseStatusParams{
+			ToStatus:   t.to,
+			UpdatedAt:  util.SqlTime(now),
+			CourseID:   course.CourseID,
+			FromStatus: t.from,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, apperr.Conflict("Course can not be moved from " + course.Status + " to " + t.to).WithCode("invalid_transition")
+		}
+
+		err = q.CreateCoursePublicationLog(ctx, repo.CreateCoursePublicationLogParams{
+			CourseID:   course.CourseID,
+			UserID:     userID,
+			FromStatus: course.Status,
+			ToStatus:   t.to,
+			Comment:    sql.NullString{String: comment, Valid: comment != ""},
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+
+		return audit.Entry{
+			Action:     t.action,
+			Resource:   constant.AuditCourse,
+			ResourceID: course.CourseID,
+			Before:     map[string]string{"status": course.Status},
+			After:      map[string]string{"status": t.to, "comment": comment},
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course"))
+		return
+	}
+
+	h.cache.Invalidate(ctx, cache.TagCourses)
+
+	res := map[string]interface{}{
+		"course_id": course.CourseID,
+		"status":    t.to,
+	}
+	util.NewResponse(http.StatusOK, http.StatusOK, t.message, res).WriteResponse(w, r)
+}
+
+// canManage report whether the logged in user owns the course or may manage every course
+func canManage(r *http.Request, course repo.Course) bool {
+	return auth.CanAccess(r.Context(), course.UserID.Int32, auth.PermCourseWriteAny)
+}
+
+func toCourses(data []repo.Course) []Course {
+	res := []Course{}
+	for _, c := range data {
+		res = append(res, Course{
+			CourseID:          c.CourseID,
+			CourseName:        c.CourseName,
+			CourseDescription: c.CourseDescription,
+			CategoryID:        c.CategoryID.Int32,
+			Price:             c.Price,
+			Thumbnail:         c.Thumbnail.String,
+			Status:            c.Status,
+			CreatedAt:         c.CreatedAt,
+			DeletedAt:         c.DeletedAt,
+			UpdatedAt:         c.UpdatedAt,
+		})
+	}
+	return res
+}
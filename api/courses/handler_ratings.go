@@ -0,0 +1,199 @@
+package courses
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// RateCourse store the rating of a subscribed student, rating again replaces the previous one
+func (h *Handler) RateCourse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	courseID, err := strconv.Atoi(chi.URLParam(r, "course_id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	var req RatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	// Validate request
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	// Only students who bought the course can rate it
+	_, err = h.db.GetMyCourse(ctx, repo.GetMyCourseParams{
+		CourseID: util.SqlInt32(int32(courseID)),
+		UserID:   util.SqlInt32(userID),
+	})
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching subscription")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	err = h.db.UpsertCourseRating(ctx, repo.UpsertCourseRatingParams{
+		CourseID:  int32(courseID),
+		UserID:    userID,
+		Rating:    req.Rating,
+		Review:    sql.NullString{String: req.Review, Valid: req.Review != ""},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error storing course rating")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Course rated successfully", struct{}{}).WriteResponse(w, r)
+}
+
+func (h *Handler) GetCourseRatings(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(chi.URLParam(r, "course_id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return
+	}
+
+	data, err := h.db.GetCourseRatings(r.Context(), int32(courseID))
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching course ratings")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []CourseRating{}
+	for _, d := range data {
+		rating := CourseRating{
+			RatingID:  d.RatingID,
+			UserID:    d.UserID,
+			UserName:  d.Nama,
+			UserPhoto: d.Photo.String,
+			Rating:    d.Rating,
+			Review:    d.Review.String,
+			Reply:     d.Reply.String,
+			CreatedAt: d.CreatedAt,
+			UpdatedAt: d.UpdatedAt,
+		}
+		if d.RepliedAt.Valid {
+			rating.RepliedAt = &d.RepliedAt.Time
+		}
+		res = append(res, rating)
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// ReplyCourseRating answer a review, only the course teacher or an admin can reply
+// and replying again replaces the previous answer
+func (h *Handler) ReplyCourseRating(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid rating ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid rating ID"))
+		return
+	}
+
+	var req ReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	// Validate request
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	rating, err := h.db.GetCourseRating(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Rating not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching course rating")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	course, err := h.db.GetCourseForUpdate(ctx, rating.CourseID)
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		replied, err := q.ReplyCourseRating(ctx, repo.ReplyCourseRatingParams{
+			RatingID:  rating.RatingID,
+			Reply:     util.SqlString(req.Reply),
+			RepliedAt: util.SqlTime(time.Now()),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseRatingReply,
+			Resource:   constant.AuditCourseRating,
+			ResourceID: rating.RatingID,
+			Before:     rating,
+			After:      replied,
+		}, nil
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error storing review reply")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	_, err = h.inbox.Notify(ctx, rating.UserID, inbox.Event{
+		Type:     constant.NotificationReviewReply,
+		CourseID: course.CourseID,
+		Title:    "Your review got a reply",
+		Message:  fmt.Sprintf("The teacher of %s replied to your review", course.CourseName),
+		Data:     map[string]any{"rating_id": rating.RatingID},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error notifying review reply")
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Reply saved successfully", struct{}{}).WriteResponse(w, r)
+}
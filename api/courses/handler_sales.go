@@ -0,0 +1,218 @@
+package courses
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// CreateCourseSale schedule a discounted price, the course is sold at its lowest running sale.
+// Wishlists are alerted right away when the sale already started and by the price-alerts job
+// otherwise, the cached catalog catches up with a scheduled start within its ttl
+func (h *Handler) CreateCourseSale(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	course, ok := h.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	var req SaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	// Validate request
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+	if req.SalePrice >= course.Price {
+		apperr.WriteError(w, r, apperr.Validation("Sale price must be lower than the course price"))
+		return
+	}
+
+	now := time.Now()
+	var d repo.CourseSale
+	err := h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		var err error
+		d, err = q.CreateCourseSale(ctx, repo.CreateCourseSaleParams{
+			CourseID:  course.CourseID,
+			SalePrice: req.SalePrice,
+			StartsAt:  req.StartsAt,
+			EndsAt:    req.EndsAt,
+			CreatedBy: userID,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseSaleCreate,
+			Resource:   constant.AuditCourseSale,
+			ResourceID: d.SaleID,
+			After:      d,
+		}, nil
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating course sale")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if !d.StartsAt.After(now) {
+		h.checkPrice(r, course.CourseID)
+	}
+
+	h.cache.Invalidate(ctx, cache.TagCourses)
+
+	util.NewResponse(http.StatusCreated, http.StatusCreated, "Sale created successfully", toSale(d)).WriteResponse(w, r)
+}
+
+func (h *Handler) GetCourseSales(w http.ResponseWriter, r *http.Request) {
+	course, ok := h.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := h.db.GetCourseSales(r.Context(), course.CourseID)
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching course sales")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []Sale{}
+	for _, d := range data {
+		res = append(res, toSale(d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// DeleteCourseSale cancel a sale, a running one restores the course price
+func (h *Handler) DeleteCourseSale(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid sale ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid sale ID"))
+		return
+	}
+
+	sale, err := h.db.GetCourseSale(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Sale not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching course sale")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	course, err := h.db.GetCourseForUpdate(ctx, sale.CourseID)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Err(ctx, err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if err == sql.ErrNoRows || !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		deleted := sale
+		deleted.DeletedAt = util.SqlTime(time.Now())
+		affected, err := q.DeleteCourseSale(ctx, repo.DeleteCourseSaleParams{
+			SaleID:    sale.SaleID,
+			DeletedAt: deleted.DeletedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseSaleDelete,
+			Resource:   constant.AuditCourseSale,
+			ResourceID: sale.SaleID,
+			Before:     sale,
+			After:      deleted,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Sale"))
+		return
+	}
+
+	h.checkPrice(r, sale.CourseID)
+
+	h.cache.Invalidate(ctx, cache.TagCourses)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Sale deleted successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// managedCourse load the {id} course and write the error response when the
+// user can not manage it
+func (h *Handler) managedCourse(w http.ResponseWriter, r *http.Request) (repo.Course, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid course ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course ID"))
+		return repo.Course{}, false
+	}
+
+	course, err := h.db.GetCourseForUpdate(r.Context(), int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Course not found"))
+		return repo.Course{}, false
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error getting course in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return repo.Course{}, false
+	}
+	if !canManage(r, course) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return repo.Course{}, false
+	}
+
+	return course, true
+}
+
+// checkPrice alert the wishlists when the effective price of the course dropped,
+// a failure is only logged since the price-alerts job picks the change up later
+func (h *Handler) checkPrice(r *http.Request, courseID int32) {
+	if _, err := h.alerter.Check(r.Context(), courseID); err != nil {
+		logger.Err(r.Context(), err).Msg("error checking course price")
+	}
+}
+
+func toSale(d repo.CourseSale) Sale {
+	return Sale{
+		SaleID:    d.SaleID,
+		CourseID:  d.CourseID,
+		SalePrice: d.SalePrice,
+		StartsAt:  d.StartsAt,
+		EndsAt:    d.EndsAt,
+		CreatedBy: d.CreatedBy,
+		CreatedAt: d.CreatedAt,
+	}
+}
@@ -3,13 +3,21 @@ package courses
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/pricealert"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	inbox    *inbox.Inbox
+	alerter  *pricealert.Alerter
+	cache    *cache.Cache
+	audit    *audit.Log
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, inbox *inbox.Inbox, alerter *pricealert.Alerter, cache *cache.Cache, audit *audit.Log) *Handler {
+	return &Handler{validate, db, inbox, alerter, cache, audit}
 }
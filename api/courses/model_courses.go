@@ -2,6 +2,7 @@ package courses
 
 import (
 	"database/sql"
+	"time"
 )
 
 type (
@@ -15,16 +16,18 @@ type (
 
 	// Course represents the structure of a course.
 	Course struct {
-		CourseID          int32        `json:"course_id"`          // Unique ID of the course
-		CourseName        string       `json:"course_name"`        // Name of the course
-		CourseDescription string       `json:"course_description"` // Description of the course
-		CategoryID        int32        `json:"category_id"`        // Category ID of the course
-		Price             int32        `json:"price"`              // Price of the course
-		Thumbnail         string       `json:"thumbnail"`          // Thumbnail URL for the course
-		Video             string       `json:"video"`
-		CreatedAt         sql.NullTime `json:"created_at"` // Timestamp of course creation
-		DeletedAt         sql.NullTime `json:"deleted_at"` // Timestamp of course deletion
-		UpdatedAt         sql.NullTime `json:"updated_at"` // Timestamp of the last course update
+		CourseID          int32          `json:"course_id"`          // Unique ID of the course
+		CourseName        string         `json:"course_name"`        // Name of the course
+		CourseDescription string         `json:"course_description"` // Description of the course
+		CategoryID        int32          `json:"category_id"`        // Category ID of the course
+		Price             int32          `json:"price"`              // Price of the course
+		Thumbnail         string         `json:"thumbnail"`          // Thumbnail URL for the course
+		Video             string         `json:"video"`
+		Status            string         `json:"status,omitempty"`
+		Teacher           *CourseTeacher `json:"teacher,omitempty"`
+		CreatedAt         sql.NullTime   `json:"created_at"` // Timestamp of course creation
+		DeletedAt         sql.NullTime   `json:"deleted_at"` // Timestamp of course deletion
+		UpdatedAt         sql.NullTime   `json:"updated_at"` // Timestamp of the last course update
 	}
 
 	// CourseRequest represents the structure for creating or updating a course.
@@ -47,6 +50,60 @@ type (
 		CourseDescription string  `json:"course_description"`
 		Price             float64 `json:"price"` // Adjust the type according to your actual price data type
 	}
+	// CourseTeacher links a course detail to the public teacher page
+	CourseTeacher struct {
+		TeacherID   int32  `json:"teacher_id"`
+		TeacherName string `json:"teacher_name"`
+		Headline    string `json:"headline"`
+		Avatar      string `json:"avatar"`
+	}
+
+	// RatingRequest is sent by a subscribed student rating a course
+	RatingRequest struct {
+		Rating int16  `json:"rating" validate:"required,min=1,max=5"`
+		Review string `json:"review" validate:"max=5000"`
+	}
+
+	CourseRating struct {
+		RatingID  int32      `json:"rating_id"`
+		UserID    int32      `json:"user_id"`
+		UserName  string     `json:"user_name"`
+		UserPhoto string     `json:"user_photo"`
+		Rating    int16      `json:"rating"`
+		Review    string     `json:"review"`
+		Reply     string     `json:"reply,omitempty"`
+		RepliedAt *time.Time `json:"replied_at,omitempty"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at"`
+	}
+
+	// ReplyRequest is the answer of the course teacher to a review
+	ReplyRequest struct {
+		Reply string `json:"reply" validate:"required,max=5000"`
+	}
+
+	// PublicationRequest carries the reviewer or teacher note attached to a status change
+	PublicationRequest struct {
+		Comment string `json:"comment"`
+	}
+
+	// RejectRequest is sent by an admin rejecting a course, the teacher needs to know why
+	RejectRequest struct {
+		Comment string `json:"comment" validate:"required"`
+	}
+
+	// PublicationLog is one entry of a course review history
+	PublicationLog struct {
+		LogID      int32     `json:"log_id"`
+		CourseID   int32     `json:"course_id"`
+		UserID     int32     `json:"user_id"`
+		UserName   string    `json:"user_name"`
+		FromStatus string    `json:"from_status"`
+		ToStatus   string    `json:"to_status"`
+		Comment    string    `json:"comment"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
 	MyCoursePageRow struct {
 		CourseID          int32  `json:"course_id"`
 		CourseName        string `json:"course_name"`
@@ -62,4 +119,21 @@ type (
 		Thumbnail         string `json:"thumbnail"`
 		Video             string `json:"video,omitempty"`
 	}
+
+	// SaleRequest schedules a discounted price for a period
+	SaleRequest struct {
+		SalePrice int32     `json:"sale_price" validate:"gte=0"`
+		StartsAt  time.Time `json:"starts_at" validate:"required"`
+		EndsAt    time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	}
+
+	Sale struct {
+		SaleID    int32     `json:"sale_id"`
+		CourseID  int32     `json:"course_id"`
+		SalePrice int32     `json:"sale_price"`
+		StartsAt  time.Time `json:"starts_at"`
+		EndsAt    time.Time `json:"ends_at"`
+		CreatedBy int32     `json:"created_by"`
+		CreatedAt time.Time `json:"created_at"`
+	}
 )
@@ -3,16 +3,25 @@ package coursesvideo
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path"
-	"log"
-	"net/http"
 	"strconv"
+	"time"
 
+	"context"
 	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/middleware/auth"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
@@ -21,8 +30,8 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form data
 	err := r.ParseMultipartForm(10 << 20) // 10MB limit
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
@@ -34,17 +43,22 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 	courseIDStr := r.FormValue("course_id")
 	courseID, err := strconv.Atoi(courseIDStr)
 	if err != nil {
-		log.Printf("error converting course_id to int32: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid course_id", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error converting course_id to int32")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course_id"))
 		return
 	}
 	req.CourseID = int32(courseID)
 
+	if err := h.canManageCourse(ctx, req.CourseID); err != nil {
+		apperr.WriteError(w, r, err)
+		return
+	}
+
 	// Retrieve the video file from form
 	file, handler, err := r.FormFile("path_video")
 	if err != nil {
-		log.Printf("error retrieving the file: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error retrieving the file", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the file"))
 		return
 	}
 	defer file.Close()
@@ -52,8 +66,8 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 	// Save the video file
 	basePath, err := os.Getwd()
 	if err != nil {
-		log.Printf("error getting current working directory: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error getting working directory", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error getting current working directory")
+		apperr.WriteError(w, r, apperr.Internal("Error getting working directory"))
 		return
 	}
 	publicPath := path.Join(basePath, "public", "videos")
@@ -61,15 +75,15 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 
 	dst, err := os.Create(videoPath)
 	if err != nil {
-		log.Printf("error creating video file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating video file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error creating video file")
+		apperr.WriteError(w, r, apperr.Internal("Error creating video file"))
 		return
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, file); err != nil {
-		log.Printf("error copying the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the video file", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error copying the file")
+		apperr.WriteError(w, r, apperr.Internal("Error copying the video file"))
 		return
 	}
 
@@ -78,24 +92,48 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Save course video data to the database
-	err = h.db.CreateCourseVideo(ctx, repo.CreateCourseVideoParams{
-		CourseID:        sql.NullInt32{Int32: req.CourseID, Valid: true},
-		CourseVideoName: req.CourseVideoName,
-		PathVideo:       req.PathVideo,
+	now := time.Now()
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		video, err := q.CreateCourseVideo(ctx, repo.CreateCourseVideoParams{
+			CourseID:        sql.NullInt32{Int32: req.CourseID, Valid: true},
+			CourseVideoName: req.CourseVideoName,
+			PathVideo:       req.PathVideo,
+			CreatedAt:       util.SqlTime(now),
+			UpdatedAt:       util.SqlTime(now),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseVideoCreate,
+			Resource:   constant.AuditCourseVideo,
+			ResourceID: video.CourseVideoID,
+			After:      video,
+		}, nil
 	})
-
 	if err != nil {
-		log.Println("error storing course video to db:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error storing course video", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error storing course video to db")
+		apperr.WriteError(w, r, apperr.Internal("Error storing course video"))
 		return
 	}
 
+	// the lesson shows up in the inbox of the enrolled students
+	_, err = h.inbox.NotifyCourse(ctx, req.CourseID, inbox.Event{
+		Type:     constant.NotificationNewLesson,
+		CourseID: req.CourseID,
+		Title:    "New lesson",
+		Message:  fmt.Sprintf("A new lesson %q was added to your course", req.CourseVideoName),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error notifying new lesson")
+	}
+
 	// Create a response with the course video data
 	responseData := map[string]interface{}{
 		"course_video_name": req.CourseVideoName,
@@ -103,6 +141,8 @@ func (h *Handler) CreateCourseVideo(w http.ResponseWriter, r *http.Request) {
 		"path_video":        req.PathVideo,
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagVideos)
+
 	util.NewResponse(http.StatusOK, http.StatusOK, "Course video created successfully", responseData).WriteResponse(w, r)
 }
 
@@ -110,8 +150,8 @@ func (h *Handler) GetCourseVideoHandler(w http.ResponseWriter, r *http.Request)
 	// Execute the query
 	data, err := h.db.GetCourseVideo(r.Context())
 	if err != nil {
-		log.Println("error fetching course videos:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching course videos")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -133,25 +173,31 @@ func (h *Handler) GetCourseVideoHandler(w http.ResponseWriter, r *http.Request)
 	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
 }
 
+// GetAllCourseVideos list the videos of the published courses
 func (h *Handler) GetAllCourseVideos(w http.ResponseWriter, r *http.Request) {
-	data, err := h.db.GetAllCourseVideos(r.Context())
+	e, err := h.cache.Load(r.Context(), "public:course_video", []string{cache.TagVideos, cache.TagCourses}, func(ctx context.Context) (any, error) {
+		data, err := h.db.GetAllCourseVideos(ctx)
+		if err != nil {
+			return nil, apperr.Internal("Internal server error").Wrap(err)
+		}
+
+		var res []CourseVideo
+		for _, d := range data {
+			res = append(res, CourseVideo{
+				CoursesVideoID:  d.CourseVideoID,
+				CourseID:        d.CourseID.Int32,
+				CourseVideoName: d.CourseVideoName,
+				PathVideo:       d.PathVideo,
+			})
+		}
+		return res, nil
+	})
 	if err != nil {
-		log.Println("error fetching all data item:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apperr.WriteError(w, r, err)
 		return
 	}
 
-	var res []CourseVideo
-	for _, d := range data {
-		res = append(res, CourseVideo{
-			CoursesVideoID:  d.CourseVideoID,
-			CourseID:        d.CourseID.Int32,
-			CourseVideoName: d.CourseVideoName,
-			PathVideo:       d.PathVideo,
-		})
-	}
-
-	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+	h.cache.Write(w, r, e)
 }
 
 func (h *Handler) GetCourseVideoByID(w http.ResponseWriter, r *http.Request) {
@@ -161,19 +207,19 @@ func (h *Handler) GetCourseVideoByID(w http.ResponseWriter, r *http.Request) {
 	// Parsing ID dari string ke int32
 	id, err := strconv.ParseInt(vars, 10, 32)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
 	// Mendapatkan data CourseVideo dari database berdasarkan ID
 	data, err := h.db.GetCourseVideoByID(r.Context(), int32(id))
 	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Invalid ID", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.NotFound("Course video not found"))
 		return
 	} else if err != nil {
-		log.Println("error fetching course video by ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching course video by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -196,52 +242,85 @@ func (h *Handler) UpdateCourseVideo(w http.ResponseWriter, r *http.Request) {
 	// Get the course video ID from the URL parameters
 	videoID := chi.URLParam(r, "id")
 	if videoID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Course video ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Course video ID is required"))
 		return
 	}
 
 	// Convert videoID to int32
 	id, err := strconv.Atoi(videoID)
 	if err != nil {
-		log.Println("invalid course video ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid course video ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid course video ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course video ID"))
 		return
 	}
 
 	var req CourseVideoRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validation request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
+	// the video may only be moved between courses the user manages
+	video, err := h.db.GetCourseVideoForUpdate(ctx, int32(id))
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course video"))
+		return
+	}
+	if err := h.canManageCourse(ctx, video.CourseID.Int32); err != nil {
+		apperr.WriteError(w, r, err)
+		return
+	}
+	if req.CourseID != video.CourseID.Int32 {
+		if err := h.canManageCourse(ctx, req.CourseID); err != nil {
+			apperr.WriteError(w, r, err)
+			return
+		}
+	}
+
+	updated := video
+	updated.CourseID = util.SqlInt32(req.CourseID)
+	updated.CourseVideoName = req.CourseVideoName
+	updated.PathVideo = req.PathVideo
+	updated.UpdatedAt = util.SqlTime(time.Now())
+
 	// Update the course video in the database
-	err = h.db.UpdateCourseVideo(ctx, repo.UpdateCourseVideoParams{
-		CourseVideoID:   int32(id),
-		CourseID:        util.SqlInt32(req.CourseID), // Ensure CourseID is included
-		CourseVideoName: req.CourseVideoName,
-		PathVideo:       req.PathVideo,
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		err := q.UpdateCourseVideo(ctx, repo.UpdateCourseVideoParams{
+			CourseVideoID:   updated.CourseVideoID,
+			CourseID:        updated.CourseID, // Ensure CourseID is included
+			CourseVideoName: updated.CourseVideoName,
+			PathVideo:       updated.PathVideo,
+			UpdatedAt:       updated.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseVideoUpdate,
+			Resource:   constant.AuditCourseVideo,
+			ResourceID: video.CourseVideoID,
+			Before:     video,
+			After:      updated,
+		}, nil
 	})
 
 	if err != nil {
-		log.Println("error updating course video in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error updating course video in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
+	h.cache.Invalidate(r.Context(), cache.TagVideos)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Course video updated successfully"
@@ -255,31 +334,177 @@ func (h *Handler) DeleteCourseVideo(w http.ResponseWriter, r *http.Request) {
 	// Mendapatkan ID CourseVideo dari parameter URL
 	videoID := chi.URLParam(r, "id")
 	if videoID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Course video ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Course video ID is required"))
 		return
 	}
 
 	// Mengonversi videoID ke int32
 	id, err := strconv.Atoi(videoID)
 	if err != nil {
-		log.Println("invalid course video ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid course video ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid course video ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course video ID"))
 		return
 	}
 
-	// Menghapus CourseVideo dari database
-	err = h.db.DeleteCourseVideo(ctx, int32(id))
+	video, err := h.db.GetCourseVideoForUpdate(ctx, int32(id))
 	if err != nil {
-		log.Println("error deleting course video from db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course video"))
+		return
+	}
+	if err := h.canManageCourse(ctx, video.CourseID.Int32); err != nil {
+		apperr.WriteError(w, r, err)
 		return
 	}
 
+	// Menandai CourseVideo sebagai terhapus (soft delete)
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		deleted := video
+		deleted.DeletedAt = util.SqlTime(time.Now())
+		affected, err := q.DeleteCourseVideo(ctx, repo.DeleteCourseVideoParams{
+			CourseVideoID: video.CourseVideoID,
+			DeletedAt:     deleted.DeletedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseVideoDelete,
+			Resource:   constant.AuditCourseVideo,
+			ResourceID: video.CourseVideoID,
+			Before:     video,
+			After:      deleted,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Course video"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagVideos)
+
 	resp.Status = http.StatusOK
 	resp.Code = http.StatusOK
 	resp.Message = "Course video deleted successfully"
 	resp.WriteResponse(w, r)
 }
+
+func (h *Handler) GetDeletedCourseVideos(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetDeletedCourseVideos(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching deleted course videos")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []CourseVideo{}
+	for _, d := range data {
+		res = append(res, CourseVideo{
+			CoursesVideoID:  d.CourseVideoID,
+			CourseID:        d.CourseID.Int32,
+			CourseVideoName: d.CourseVideoName,
+			PathVideo:       d.PathVideo,
+			CreatedAt:       d.CreatedAt.Time,
+			DeletedAt:       d.DeletedAt.Time,
+			UpdatedAt:       d.UpdatedAt.Time,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) RestoreCourseVideo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid course video ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course video ID"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		video, err := q.GetDeletedCourseVideo(r.Context(), int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		restored := video
+		restored.DeletedAt, restored.UpdatedAt = sql.NullTime{}, util.SqlTime(time.Now())
+
+		affected, err := q.RestoreCourseVideo(r.Context(), repo.RestoreCourseVideoParams{
+			CourseVideoID: video.CourseVideoID,
+			UpdatedAt:     restored.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseVideoRestore,
+			Resource:   constant.AuditCourseVideo,
+			ResourceID: video.CourseVideoID,
+			Before:     video,
+			After:      restored,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted course video"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagVideos)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Course video restored successfully", struct{}{}).WriteResponse(w, r)
+}
+
+func (h *Handler) PurgeCourseVideo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("invalid course video ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid course video ID"))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		video, err := q.GetDeletedCourseVideo(r.Context(), int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		affected, err := q.PurgeCourseVideo(r.Context(), video.CourseVideoID)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditCourseVideoPurge,
+			Resource:   constant.AuditCourseVideo,
+			ResourceID: video.CourseVideoID,
+			Before:     video,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Deleted course video"))
+		return
+	}
+
+	h.cache.Invalidate(r.Context(), cache.TagVideos)
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Course video purged successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// canManageCourse check that the logged in user owns the course, or may manage the videos of
+// every course
+func (h *Handler) canManageCourse(ctx context.Context, courseID int32) error {
+	course, err := h.db.GetCourseForUpdate(ctx, courseID)
+	if err != nil {
+		return apperr.FromDB(err, "Course")
+	}
+	if !auth.CanAccess(ctx, course.UserID.Int32, auth.PermVideoWriteAny) {
+		return apperr.Forbidden("Forbidden")
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package coursesvideo_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	coursesvideo "github.com/online-bnsp/backend/api/courses_video"
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+)
+
+type course struct {
+	userID int64
+	status string
+}
+
+type video struct {
+	courseID   int64
+	name, path string
+}
+
+// database answers the queries of the handlers by their sqlc name
+type database struct {
+	courses map[int64]course
+	videos  map[int64]video
+	audits  int
+}
+
+func (d *database) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	id := args[0].Value.(int64)
+	switch queryName(query) {
+	case "GetCourseForUpdate":
+		c, ok := d.courses[id]
+		if !ok {
+			return &rows{}, nil
+		}
+		return &rows{columns: 11, values: [][]driver.Value{{
+			id, "Go", "", nil, int64(0), nil, nil, nil, nil, c.status, c.userID,
+		}}}, nil
+	case "GetCourseVideoForUpdate":
+		v, ok := d.videos[id]
+		if !ok {
+			return &rows{}, nil
+		}
+		return &rows{columns: 7, values: [][]driver.Value{{id, v.courseID, v.name, v.path, nil, nil, nil}}}, nil
+	case "GetCourseVideoByID":
+		v, ok := d.videos[id]
+		if !ok || d.courses[v.courseID].status != "PUBLISHED" {
+			return &rows{}, nil
+		}
+		return &rows{columns: 7, values: [][]driver.Value{{id, v.courseID, v.name, v.path, nil, nil, nil}}}, nil
+	}
+	return nil, errors.New("unexpected query " + queryName(query))
+}
+
+func (d *database) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	switch queryName(query) {
+	case "UpdateCourseVideo":
+		id := args[4].Value.(int64)
+		d.videos[id] = video{courseID: args[0].Value.(int64), name: args[1].Value.(string), path: args[2].Value.(string)}
+		return driver.RowsAffected(1), nil
+	case "CreateAuditLog":
+		d.audits++
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected query " + queryName(query))
+}
+
+func queryName(query string) string {
+	name := strings.TrimPrefix(query, "-- name: ")
+	return name[:strings.IndexByte(name, ' ')]
+}
+
+type connector struct{ db *database }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn(c), nil }
+func (c connector) Driver() driver.Driver                        { return nil }
+
+type conn struct{ db *database }
+
+func (c conn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c conn) Close() error                        { return nil }
+func (c conn) Begin() (driver.Tx, error)           { return c, nil }
+func (c conn) Commit() error                       { return nil }
+func (c conn) Rollback() error                     { return nil }
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query, args)
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.db.exec(query, args)
+}
+
+type rows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return make([]string, r.columns) }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+type permissions map[int32][]string
+
+func (p permissions) GetUserPermissions(ctx context.Context, userID int32) ([]repo.GetUserPermissionsRow, error) {
+	rows := []repo.GetUserPermissionsRow{}
+	for _, perm := range p[userID] {
+		rows = append(rows, repo.GetUserPermissionsRow{Role: "teacher", Permission: sql.NullString{String: perm, Valid: true}})
+	}
+	return rows, nil
+}
+
+func TestUpdateCourseVideoOnDraftCourse(t *testing.T) {
+	db := &database{
+		courses: map[int64]course{1: {userID: 5, status: "DRAFT"}},
+		videos:  map[int64]video{10: {courseID: 1, name: "Intro", path: "intro.mp4"}},
+	}
+	conn := sql.OpenDB(connector{db})
+	defer conn.Close()
+
+	h := coursesvideo.NewHandler(validator.New(), repo.New(conn), nil, nil, audit.New(conn))
+	authz := auth.NewAuthorizer(permissions{5: {auth.PermVideoWrite}, 6: {auth.PermVideoWrite}})
+	router := chi.NewRouter()
+	router.With(authz.RequirePermission(auth.PermVideoWrite)).Put("/update-course_video/{id}", h.UpdateCourseVideo)
+
+	update := func(userID int32) int {
+		body := `{"course_id": 1, "course_video_name": "Introduction", "path_video": "intro-v2.mp4"}`
+		r := httptest.NewRequest(http.MethodPut, "/update-course_video/10", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// another teacher can not touch the video
+	if got := update(6); got != http.StatusForbidden {
+		t.Fatalf("other teacher: got %d, want %d", got, http.StatusForbidden)
+	}
+
+	// videos are built before the course is published
+	if got := update(5); got != http.StatusOK {
+		t.Fatalf("owner: got %d, want %d", got, http.StatusOK)
+	}
+	if v := db.videos[10]; v.name != "Introduction" || v.path != "intro-v2.mp4" {
+		t.Errorf("video was not updated: %+v", v)
+	}
+	if db.audits != 1 {
+		t.Errorf("recorded %d audit logs, want 1", db.audits)
+	}
+}
@@ -3,13 +3,19 @@ package coursesvideo
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/online-bnsp/backend/util/inbox"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	inbox    *inbox.Inbox
+	cache    *cache.Cache
+	audit    *audit.Log
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, inbox *inbox.Inbox, cache *cache.Cache, audit *audit.Log) *Handler {
+	return &Handler{validate, db, inbox, cache, audit}
 }
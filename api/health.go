@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/buckets"
+	"github.com/online-bnsp/backend/util/health"
+	queue "github.com/online-bnsp/backend/util/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	healthCacheTTL = 2 * time.Second
+	healthTimeout  = 2 * time.Second
+)
+
+// SetHealthConfig set how long a readiness report is reused and how long each dependency gets to answer
+func SetHealthConfig(cacheTTL, timeout time.Duration) {
+	if cacheTTL > 0 {
+		healthCacheTTL = cacheTTL
+	}
+	if timeout > 0 {
+		healthTimeout = timeout
+	}
+}
+
+func newHealthChecker(db *sql.DB, rdb *redis.Client, producer queue.Producer, bucket buckets.Bucket) *health.Checker {
+	c := health.New(healthCacheTTL, healthTimeout)
+	c.Add("postgres", db.PingContext)
+	c.Add("redis", func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+	c.Add("nsq", func(ctx context.Context) error {
+		if producer == nil {
+			return errors.New("producer not created")
+		}
+		return producer.Ping()
+	})
+	if b, ok := bucket.(buckets.Checker); ok {
+		c.Add("bucket", b.Check)
+	}
+	return c
+}
+
+// Healthz report that the process is alive, it does not look at the dependencies
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	util.NewResponse(http.StatusOK, http.StatusOK, "", map[string]string{"status": health.StatusUp}).WriteResponse(w, r)
+}
+
+// Readyz report whether the dependencies are reachable, it fails once the server is shutting down
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Check(r.Context())
+	if !report.Up() {
+		util.NewResponse(http.StatusServiceUnavailable, http.StatusServiceUnavailable, "Not ready", report).WriteResponse(w, r)
+		return
+	}
+	util.NewResponse(http.StatusOK, http.StatusOK, "", report).WriteResponse(w, r)
+}
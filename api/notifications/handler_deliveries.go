@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// GetDeliveries list the delivery log, newest first,
+// ?status=QUEUED|SENT|RETRYING|DEAD&channel=email|whatsapp&recipient=&page=&limit=
+func (h *Handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > maxDeliveryLimit {
+		limit = defaultDeliveryLimit
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	data, err := h.db.GetNotificationDeliveries(r.Context(), repo.GetNotificationDeliveriesParams{
+		Limit:     int32(limit),
+		Offset:    int32((page - 1) * limit),
+		Status:    nullString(query.Get("status")),
+		Channel:   nullString(query.Get("channel")),
+		Recipient: nullString(query.Get("recipient")),
+	})
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching notification deliveries")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []Delivery{}
+	for _, d := range data {
+		res = append(res, toDelivery(d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid delivery ID"))
+		return
+	}
+
+	d, err := h.db.GetNotificationDeliveryByID(r.Context(), int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Delivery not found"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching notification delivery")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toDelivery(d)).WriteResponse(w, r)
+}
+
+func toDelivery(d repo.NotificationDelivery) Delivery {
+	res := Delivery{
+		DeliveryID: d.DeliveryID,
+		MessageID:  d.MessageID,
+		Channel:    d.Channel,
+		Template:   d.Template.String,
+		Recipient:  d.Recipient,
+		Status:     d.Status,
+		Attempts:   d.Attempts,
+		LastError:  d.LastError.String,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+	if d.SentAt.Valid {
+		res.SentAt = &d.SentAt.Time
+	}
+	return res
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -1,181 +1,210 @@
 package notifications
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
+const (
+	defaultInboxLimit = 20
+	maxInboxLimit     = 100
+)
+
+// CreateNotification send an announcement to the inbox of a user
 func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 	var req NotificationRequest
 
 	// Decode JSON request body
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("error parsing request: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
-	// Store notification in the database
-	err = h.db.CreateNotification(r.Context(), repo.CreateNotificationParams{
-		UserID: sql.NullInt32{
-			Int32: req.UserID,
-			Valid: true,
-		},
-		CourseID: sql.NullInt32{
-			Int32: req.CourseID,
-			Valid: true,
-		},
-		Message: sql.NullString{
-			String: req.Message,
-			Valid:  true,
-		},
-		IsRead: req.IsRead,
+	// Store notification in the database and push it to the user
+	var res inbox.Notification
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		res, err = h.inbox.WithQueries(q).Notify(r.Context(), req.UserID, inbox.Event{
+			Type:     constant.NotificationGeneral,
+			CourseID: req.CourseID,
+			Title:    req.Title,
+			Message:  req.Message,
+		})
+		if err != nil || res.NotificationID == 0 {
+			// nothing was stored when the user turned announcements off
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditNotificationCreate,
+			Resource:   constant.AuditNotification,
+			ResourceID: res.NotificationID,
+			After:      res,
+		}, nil
 	})
-
 	if err != nil {
-		log.Printf("error creating notification: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating notification", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error creating notification")
+		apperr.WriteError(w, r, apperr.Internal("Error creating notification"))
 		return
 	}
 
-	util.NewResponse(http.StatusOK, http.StatusOK, "Notification created successfully", struct{}{}).WriteResponse(w, r)
+	util.NewResponse(http.StatusOK, http.StatusOK, "Notification created successfully", res).WriteResponse(w, r)
 }
 
-func (h *Handler) GetAllNotifications(w http.ResponseWriter, r *http.Request) {
-	data, err := h.db.GetAllNotifications(r.Context())
+// GetMyNotifications list the inbox of the logged in user, newest first, ?unread=true&page=&limit=
+func (h *Handler) GetMyNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > maxInboxLimit {
+		limit = defaultInboxLimit
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	unreadOnly, _ := strconv.ParseBool(query.Get("unread"))
+
+	data, err := h.db.GetUserNotifications(ctx, repo.GetUserNotificationsParams{
+		UserID:     util.SqlInt32(userID),
+		Limit:      int32(limit),
+		Offset:     int32((page - 1) * limit),
+		UnreadOnly: unreadOnly,
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching notifications")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	unread, err := h.db.CountUnreadNotifications(ctx, util.SqlInt32(userID))
 	if err != nil {
-		log.Println("error fetching all notifications:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(ctx, err).Msg("error counting unread notifications")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
-	var res []Notification
+	res := InboxPage{UnreadCount: unread, Notifications: []inbox.Notification{}}
 	for _, d := range data {
-		res = append(res, Notification{
-			NotificationID: d.NotificationID,
-			UserID:         d.UserID.Int32,   // Mengakses nilai Int32
-			CourseID:       d.CourseID.Int32, // Mengakses nilai Int32
-			Message:        d.Message.String, // Mengakses nilai String
-			IsRead:         d.IsRead,         // Mengakses nilai String
-		})
+		res.Notifications = append(res.Notifications, inbox.ToNotification(d))
 	}
 
 	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
 }
 
-func (h *Handler) UpdateNotification(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	resp := util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Bad request", map[string]interface{}{})
+	userID, _ := ctx.Value("user_id").(int32)
 
-	// Get the notification ID from the URL parameters
-	notificationID := chi.URLParam(r, "id")
-	if notificationID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Notification ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+	unread, err := h.db.CountUnreadNotifications(ctx, util.SqlInt32(userID))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error counting unread notifications")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
-	// Convert notificationID to int32
-	id, err := strconv.Atoi(notificationID)
+	util.NewResponse(http.StatusOK, http.StatusOK, "", map[string]int64{"unread_count": unread}).WriteResponse(w, r)
+}
+
+func (h *Handler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		log.Println("invalid notification ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid notification ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid notification ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid notification ID"))
 		return
 	}
 
-	var req NotificationRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
+	affected, err := h.db.MarkNotificationRead(ctx, repo.MarkNotificationReadParams{
+		NotificationID: int32(id),
+		UserID:         util.SqlInt32(userID),
+		ReadAt:         util.SqlTime(time.Now()),
+	})
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error marking notification as read")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
-	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			field := err.Field()
-			if field == "UserID" {
-				errMsg.WriteString("User ID is required; ")
-			} else if field == "Message" {
-				errMsg.WriteString("Message is required; ")
-			} else {
-				errMsg.WriteString(field + " is invalid; ")
-			}
-		}
-		log.Println("validation error:", errMsg.String())
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{})
-		resp.WriteResponse(w, r)
-		return
+	// other open tabs update their unread count
+	if affected > 0 {
+		h.inbox.Publish(ctx, userID, inbox.EventRead, map[string]int32{"notification_id": int32(id)})
 	}
 
-	// Update the notification in the database
-	err = h.db.UpdateNotification(ctx, repo.UpdateNotificationParams{
-		NotificationID: int32(id),
-		UserID:         sql.NullInt32{Int32: req.UserID, Valid: true},
-		CourseID:       sql.NullInt32{Int32: req.CourseID, Valid: true},
-		Message:        sql.NullString{String: req.Message, Valid: true},
-		IsRead:         req.IsRead,
-	})
+	util.NewResponse(http.StatusOK, http.StatusOK, "Notification marked as read", struct{}{}).WriteResponse(w, r)
+}
 
+func (h *Handler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	affected, err := h.db.MarkAllNotificationsRead(ctx, repo.MarkAllNotificationsReadParams{
+		UserID: util.SqlInt32(userID),
+		ReadAt: util.SqlTime(time.Now()),
+	})
 	if err != nil {
-		log.Println("error updating notification in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error marking notifications as read")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
-	resp.Status = http.StatusOK
-	resp.Code = http.StatusOK
-	resp.Message = "Notification updated successfully"
-	resp.WriteResponse(w, r)
+	// a notification_id of 0 is every notification
+	if affected > 0 {
+		h.inbox.Publish(ctx, userID, inbox.EventRead, map[string]int32{"notification_id": 0})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "All notifications marked as read", map[string]int64{"updated": affected}).WriteResponse(w, r)
 }
 
 func (h *Handler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
 	// Get the notification ID from the URL parameters
 	notificationID := chi.URLParam(r, "id")
 
 	// Convert notificationID to int32
 	id, err := strconv.ParseInt(notificationID, 10, 32)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
-	// Delete the notification from the database
-	err = h.db.DeleteNotification(r.Context(), int32(id))
+	// Delete the notification from the database, only from the inbox of its owner
+	affected, err := h.db.DeleteNotification(ctx, repo.DeleteNotificationParams{
+		NotificationID: int32(id),
+		UserID:         util.SqlInt32(userID),
+	})
 	if err != nil {
-		log.Println("error deleting notification:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error deleting notification", struct{}{}).WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error deleting notification")
+		apperr.WriteError(w, r, apperr.Internal("Error deleting notification"))
+		return
+	}
+	if affected == 0 {
+		apperr.WriteError(w, r, apperr.NotFound("Notification not found"))
 		return
 	}
 
@@ -0,0 +1,83 @@
+package notifications
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/preference"
+)
+
+// GetPreferences list every event × channel setting of the logged in user
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+	role, _ := ctx.Value("role").(string)
+
+	res, err := h.prefs.Get(ctx, userID, role)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching notification preferences")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+	role, _ := ctx.Value("role").(string)
+
+	var req PreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+	if err := preference.Validate(req.Preferences); err != nil {
+		apperr.WriteError(w, r, apperr.Validation(err.Error()))
+		return
+	}
+
+	if err := h.prefs.Set(ctx, userID, req.Preferences); err != nil {
+		logger.Err(ctx, err).Msg("error storing notification preferences")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	res, err := h.prefs.Get(ctx, userID, role)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching notification preferences")
+		util.NewResponse(http.StatusOK, http.StatusOK, "Preferences updated successfully", struct{}{}).WriteResponse(w, r)
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Preferences updated successfully", res).WriteResponse(w, r)
+}
+
+// Unsubscribe turn the emails of an event off from the signed link of a marketing email,
+// it works without login and also answers the one click POST of mail clients
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, event, err := preference.ParseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid or expired link"))
+		return
+	}
+
+	err = h.prefs.Set(r.Context(), userID, []preference.Setting{{Event: event, Channel: preference.ChannelEmail, Enabled: false}})
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error unsubscribing")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "You have been unsubscribed", map[string]string{"event": event}).WriteResponse(w, r)
+}
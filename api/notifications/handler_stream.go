@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// keeps proxies from closing an idle stream
+const heartbeatInterval = 25 * time.Second
+
+// Stream push the inbox updates of the logged in user as server-sent events,
+// an `unread` event with the current count is sent first
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apperr.WriteError(w, r, apperr.Internal("Streaming is not supported"))
+		return
+	}
+
+	sub := h.inbox.Subscribe(ctx, userID)
+	defer sub.Close()
+
+	// wait for the subscription so no update is missed after the unread count
+	if _, err := sub.Receive(ctx); err != nil {
+		logger.Err(ctx, err).Msg("error subscribing to notifications")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	unread, err := h.db.CountUnreadNotifications(ctx, util.SqlInt32(userID))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error counting unread notifications")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// the server write timeout would cut the stream, the heartbeat detects dead clients instead
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		logger.Err(ctx, err).Msg("error clearing stream write deadline")
+	}
+
+	count, _ := json.Marshal(map[string]int64{"unread_count": unread})
+	writeEvent(w, "unread", count)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	updates := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-h.closing:
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+
+		case msg, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			update := inbox.Update{}
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				logger.Warn(ctx, err).Msg("error decoding notification update")
+				continue
+			}
+			writeEvent(w, update.Event, update.Data)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data []byte) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -1,15 +1,32 @@
 package notifications
 
 import (
+	"sync"
+
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/preference"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	inbox    *inbox.Inbox
+	prefs    *preference.Preferences
+	audit    *audit.Log
+
+	// closed when the server shuts down, ends the open streams
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewHandler(validate *validator.Validate, db *repo.Queries, inbox *inbox.Inbox, audit *audit.Log) *Handler {
+	return &Handler{validate: validate, db: db, inbox: inbox, prefs: preference.New(db), audit: audit, closing: make(chan struct{})}
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+// CloseStreams end the open notification streams, clients reconnect to another replica
+func (h *Handler) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
 }
@@ -1,20 +1,44 @@
 package notifications
 
+import (
+	"time"
+
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/preference"
+)
+
 type (
-	// Model Notification yang sesuai dengan tabel notification
-	Notification struct {
-		NotificationID int32  `json:"notification_id"` // ID notifikasi
-		UserID         int32  `json:"user_id"`         // ID pengguna yang menerima notifikasi
-		CourseID       int32  `json:"course_id"`       // ID kursus terkait
-		Message        string `json:"message"`         // Pesan notifikasi
-		IsRead         string `json:"is_read"`         // Status baca notifikasi (misalnya 'true' atau 'false')
+	// InboxPage is a page of the notifications of the logged in user
+	InboxPage struct {
+		UnreadCount   int64                `json:"unread_count"`
+		Notifications []inbox.Notification `json:"notifications"`
 	}
 
 	// Model NotificationRequest untuk request input
 	NotificationRequest struct {
 		UserID   int32  `json:"user_id" validate:"required"` // ID pengguna (wajib diisi)
 		CourseID int32  `json:"course_id"`                   // ID kursus (opsional)
+		Title    string `json:"title" validate:"max=255"`
 		Message  string `json:"message" validate:"required"` // Pesan notifikasi (wajib diisi)
-		IsRead   string `json:"is_read" validate:"required"` // Status baca notifikasi (wajib diisi)
+	}
+
+	// PreferencesRequest only needs the settings that change
+	PreferencesRequest struct {
+		Preferences []preference.Setting `json:"preferences" validate:"required,min=1"`
 	}
 )
+
+// Delivery is an email or whatsapp message sent by the consumer
+type Delivery struct {
+	DeliveryID int32      `json:"delivery_id"`
+	MessageID  string     `json:"message_id"`
+	Channel    string     `json:"channel"`
+	Template   string     `json:"template,omitempty"`
+	Recipient  string     `json:"recipient"`
+	Status     string     `json:"status"`
+	Attempts   int32      `json:"attempts"`
+	LastError  string     `json:"last_error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+}
@@ -0,0 +1,310 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/ledger"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/metrics"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+// MarkPaymentPaid confirm a payment and issue its invoice, the receipt is then stored and emailed to the buyer
+func (h *Handler) MarkPaymentPaid(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid payment ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid payment ID"))
+		return
+	}
+
+	tx, err := h.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error starting transaction")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	defer tx.Rollback()
+
+	q := repo.New(tracing.WrapDB(tx))
+
+	payment, err := q.GetPaymentForInvoice(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Payment not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching payment")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	affected, err := q.MarkPaymentPaid(ctx, repo.MarkPaymentPaidParams{
+		PaymentID:  int32(id),
+		PaidStatus: util.SqlInt32(constant.PaymentPaid),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error marking payment paid")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if affected == 0 {
+		apperr.WriteError(w, r, apperr.Conflict("Payment is already paid"))
+		return
+	}
+
+	// every paid course needs its transaction history to reach the teacher revenue
+	_, err = q.CreateMissingTransactionHistory(ctx, repo.CreateMissingTransactionHistoryParams{
+		PaymentID: util.SqlInt32(int32(id)),
+		Now:       util.SqlTime(time.Now()),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating transaction history")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	items, err := q.GetPaymentItems(ctx, util.SqlInt32(int32(id)))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching payment items")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if len(items) == 0 {
+		apperr.WriteError(w, r, apperr.Validation("Payment has no purchased course yet"))
+		return
+	}
+
+	now := time.Now()
+	inv := repo.CreateInvoiceParams{
+		PaymentID:         int32(id),
+		UserID:            payment.UserID.Int32,
+		PaymentMethodName: payment.PaymentMethodName,
+		TaxRateBp:         int32(taxRateBP),
+		IssuedAt:          now,
+	}
+
+	lines := make([]repo.CreateInvoiceItemParams, 0, len(items))
+	for _, it := range items {
+		// the list price may be lower than what was paid when the course price went down since
+		gross := it.UnitPrice * int64(it.Quantity)
+		if gross < it.Amount {
+			gross = it.Amount
+		}
+
+		lines = append(lines, repo.CreateInvoiceItemParams{
+			CourseID:   it.CourseID,
+			CourseName: it.CourseName,
+			UnitPrice:  it.UnitPrice,
+			Quantity:   it.Quantity,
+			Discount:   gross - it.Amount,
+			Amount:     it.Amount,
+		})
+		inv.Subtotal += gross
+		inv.Discount += gross - it.Amount
+		inv.Total += it.Amount
+	}
+	inv.Tax = inv.Total * taxRateBP / (10000 + taxRateBP)
+
+	seq, err := q.NextInvoiceNumber(ctx, int32(now.Year()))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error getting invoice number")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	inv.InvoiceNumber = fmt.Sprintf("%s/%d/%06d", invoicePrefix, now.Year(), seq)
+
+	created, err := q.CreateInvoice(ctx, inv)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating invoice")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	for _, l := range lines {
+		l.InvoiceID = created.InvoiceID
+		if err := q.CreateInvoiceItem(ctx, l); err != nil {
+			logger.Err(ctx, err).Msg("error creating invoice item")
+			apperr.WriteError(w, r, apperr.Internal("Try again later"))
+			return
+		}
+	}
+
+	if err := ledger.PostSales(ctx, q, int32(id)); err != nil {
+		logger.Err(ctx, err).Msg("error posting ledger entries")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	err = audit.Record(r, q, audit.Entry{
+		Action:     constant.AuditPaymentPaid,
+		Resource:   constant.AuditPayment,
+		ResourceID: payment.PaymentID,
+		Before:     map[string]any{"payment_status_id": payment.PaymentStatusID},
+		After: map[string]any{
+			"payment_status_id": constant.PaymentPaid,
+			"invoice_number":    created.InvoiceNumber,
+			"total":             created.Total,
+		},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error recording audit log")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Err(ctx, err).Msg("error committing invoice")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	metrics.PaymentPaid(inv.Total)
+
+	// the payment is confirmed at this point, a failed upload or queued email is only logged
+	// and the receipt stays downloadable from the invoice endpoint
+	invoice, err := h.getInvoice(ctx, int32(id))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching invoice")
+		util.NewResponse(http.StatusOK, http.StatusOK, "Payment marked as paid", struct{}{}).WriteResponse(w, r)
+		return
+	}
+	h.deliverInvoice(ctx, &invoice)
+
+	_, err = h.inbox.Notify(ctx, invoice.UserID, inbox.Event{
+		Type:    constant.NotificationPurchase,
+		Title:   "Payment confirmed",
+		Message: fmt.Sprintf("Your payment of %s is confirmed, receipt %s", util.FormatRupiah(invoice.Total), invoice.InvoiceNumber),
+		Data:    map[string]any{"payment_id": invoice.PaymentID, "invoice_number": invoice.InvoiceNumber},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error notifying purchase")
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Payment marked as paid", invoice).WriteResponse(w, r)
+}
+
+// GetInvoice download the pdf receipt of a payment, only for the buyer and the users allowed to
+// read every payment
+func (h *Handler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid payment ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid payment ID"))
+		return
+	}
+
+	invoice, err := h.getInvoice(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Invoice not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching invoice")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	if !auth.CanAccess(ctx, invoice.UserID, auth.PermPaymentReadAny) {
+		apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, invoiceFilename(invoice)))
+	w.Write(renderInvoice(invoice))
+}
+
+func (h *Handler) getInvoice(ctx context.Context, paymentID int32) (Invoice, error) {
+	d, err := h.db.GetInvoiceByPaymentID(ctx, paymentID)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	items, err := h.db.GetInvoiceItems(ctx, d.InvoiceID)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	invoice := Invoice{
+		InvoiceID:     d.InvoiceID,
+		InvoiceNumber: d.InvoiceNumber,
+		PaymentID:     d.PaymentID,
+		UserID:        d.UserID,
+		BuyerName:     d.Nama.String,
+		BuyerEmail:    d.Email.String,
+		PaymentMethod: d.PaymentMethodName,
+		Items:         []InvoiceItem{},
+		Subtotal:      d.Subtotal,
+		Discount:      d.Discount,
+		Tax:           d.Tax,
+		TaxRate:       float64(d.TaxRateBp) / 100,
+		Total:         d.Total,
+		FileURL:       d.FileUrl.String,
+		IssuedAt:      d.IssuedAt,
+	}
+	for _, it := range items {
+		invoice.Items = append(invoice.Items, InvoiceItem{
+			CourseID:   it.CourseID.Int32,
+			CourseName: it.CourseName,
+			UnitPrice:  it.UnitPrice,
+			Quantity:   it.Quantity,
+			Discount:   it.Discount,
+			Amount:     it.Amount,
+		})
+	}
+
+	return invoice, nil
+}
+
+// deliverInvoice store the receipt in the bucket and queue the email to the buyer,
+// emailed_at is set by the consumer once the mail is sent
+func (h *Handler) deliverInvoice(ctx context.Context, invoice *Invoice) {
+	pdf := renderInvoice(*invoice)
+
+	url, err := h.bucket.Upload(fmt.Sprintf("invoice-%s.pdf", uuid.NewString()), bytes.NewReader(pdf))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error uploading invoice")
+	} else {
+		invoice.FileURL = url
+		if err := h.db.SetInvoiceFile(ctx, repo.SetInvoiceFileParams{InvoiceID: invoice.InvoiceID, FileUrl: util.SqlString(url)}); err != nil {
+			logger.Err(ctx, err).Msg("error storing invoice url")
+		}
+	}
+
+	if invoice.BuyerEmail == "" {
+		return
+	}
+	_, err = h.notify.Email(ctx, mailer.Recipient{Email: invoice.BuyerEmail, Name: invoice.BuyerName, UserID: invoice.UserID}, mailer.TemplateReceipt, mailer.ReceiptMail{
+		InvoiceNumber: invoice.InvoiceNumber,
+		Total:         invoice.Total,
+		IssuedAt:      invoice.IssuedAt,
+		Filename:      invoiceFilename(*invoice),
+		PDF:           pdf,
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error queueing invoice email")
+	}
+}
+
+func invoiceFilename(invoice Invoice) string {
+	return strings.ReplaceAll(invoice.InvoiceNumber, "/", "-") + ".pdf"
+}
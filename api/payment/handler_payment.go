@@ -2,26 +2,28 @@ package payment
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/metrics"
 )
 
 func (h *Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
@@ -30,60 +32,68 @@ func (h *Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	// Decode JSON request body
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("error parsing request: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Get course in cart
-	courses, err := h.db.GetCartByUserID(r.Context(), util.SqlInt32(userIDInt))
+	// priced at the payment date, the subscriptions are priced the same way
+	now := time.Now()
+	courses, err := h.db.GetCartByUserID(r.Context(), repo.GetCartByUserIDParams{PricedAt: now, UserID: util.SqlInt32(userIDInt)})
 	if err != nil {
-		log.Println("error in getting cart: ", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error in calculating total", struct{}{})
+		logger.Err(r.Context(), err).Msg("error in getting cart")
+		apperr.WriteError(w, r, apperr.Internal("Error in calculating total"))
 		return
 	}
 
 	var totalAmount int32
 	for _, c := range courses {
-		totalAmount += c.TotalAmount.Int32
+		totalAmount += c.TotalAmount
 	}
 
 	// Store payment in the database
-	err = h.db.CreatePayment(r.Context(), repo.CreatePaymentParams{
-		UserID:          util.SqlInt32(userIDInt),
-		PaymentMethodID: util.SqlInt32(req.PaymentMethodID),
-		PaymentStatusID: util.SqlInt32(1),
-		TotalAmount:     util.SqlInt32(totalAmount),
-		PaymentDate:     util.SqlTime(time.Now()),
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		payment, err := q.CreatePayment(r.Context(), repo.CreatePaymentParams{
+			UserID:          util.SqlInt32(userIDInt),
+			PaymentMethodID: util.SqlInt32(req.PaymentMethodID),
+			PaymentStatusID: util.SqlInt32(1),
+			TotalAmount:     util.SqlInt32(totalAmount),
+			PaymentDate:     util.SqlTime(now),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditPaymentCreate,
+			Resource:   constant.AuditPayment,
+			ResourceID: payment.PaymentID,
+			After:      payment,
+		}, nil
 	})
-
 	if err != nil {
-		log.Printf("error creating payment: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating payment", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error creating payment")
+		apperr.WriteError(w, r, apperr.Internal("Error creating payment"))
 		return
 	}
 
+	metrics.CheckedOut()
+
 	util.NewResponse(http.StatusOK, http.StatusOK, "Payment created successfully", struct{}{}).WriteResponse(w, r)
 }
 
 func (h *Handler) GetAllPayment(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllPayment(r.Context())
 	if err != nil {
-		log.Println("error fetching all payments:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all payments")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -108,8 +118,8 @@ func (h *Handler) GetPayment(w http.ResponseWriter, r *http.Request) {
 	// Execute the query to get payment details
 	data, err := h.db.GetPayment(ctx)
 	if err != nil {
-		log.Println("error fetching payment data:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(ctx, err).Msg("error fetching payment data")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -138,8 +148,8 @@ func (h *Handler) GetPaymentHistory(w http.ResponseWriter, r *http.Request) {
 	// Execute the query to get payment history details
 	data, err := h.db.GetPaymentHistory(ctx)
 	if err != nil {
-		log.Println("error fetching payment history data:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(ctx, err).Msg("error fetching payment history data")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -243,7 +253,7 @@ func (h *Handler) GetPaymentHistory(w http.ResponseWriter, r *http.Request) {
 // 	id, err := strconv.ParseInt(paymentID, 10, 32)
 // 	if err != nil {
 // 		log.Println("error parsing ID:", err)
-// 		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+// 		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 // 		return
 // 	}
 
@@ -251,7 +261,7 @@ func (h *Handler) GetPaymentHistory(w http.ResponseWriter, r *http.Request) {
 // 	err = h.db.DeletePayment(r.Context(), int32(id))
 // 	if err != nil {
 // 		log.Println("error deleting payment:", err)
-// 		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error deleting payment", struct{}{}).WriteResponse(w, r)
+// 		apperr.WriteError(w, r, apperr.Internal("Error deleting payment"))
 // 		return
 // 	}
 
@@ -1,15 +1,49 @@
 package payment
 
 import (
+	"database/sql"
+
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/buckets"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/notify"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+// invoice settings, prices are tax inclusive and taxRateBP is in basis points
+var (
+	taxRateBP      int64 = 0
+	invoicePrefix        = "INV"
+	invoiceIssuer        = "Online BNSP"
+	invoiceAddress       = ""
 )
 
+// SetInvoiceConfig set the tax rate in percent (0-100), the invoice number prefix and the issuer shown on receipts
+func SetInvoiceConfig(taxRate float64, prefix, issuer, address string) {
+	if taxRate >= 0 && taxRate <= 100 {
+		taxRateBP = int64(taxRate * 100)
+	}
+	if prefix != "" {
+		invoicePrefix = prefix
+	}
+	if issuer != "" {
+		invoiceIssuer = issuer
+	}
+	invoiceAddress = address
+}
+
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	conn     *sql.DB // invoices are numbered in a transaction
+	bucket   buckets.Bucket
+	notify   *notify.Publisher
+	inbox    *inbox.Inbox
+	audit    *audit.Log
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, conn *sql.DB, bucket buckets.Bucket, notify *notify.Publisher, inbox *inbox.Inbox, audit *audit.Log) *Handler {
+	return &Handler{validate, repo.New(tracing.WrapDB(conn)), conn, bucket, notify, inbox, audit}
 }
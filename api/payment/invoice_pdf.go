@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/pdf"
+)
+
+// column positions of the line items table
+const (
+	colCourse   = 40
+	colQuantity = 330
+	colPrice    = 420
+	colDiscount = 485
+	colAmount   = 555
+)
+
+// renderInvoice draw the receipt, the output only depends on the stored invoice
+// so a downloaded copy matches the emailed one
+func renderInvoice(inv Invoice) []byte {
+	d := pdf.New()
+
+	d.Text(colCourse, 60, 20, true, invoiceIssuer)
+	if invoiceAddress != "" {
+		d.Text(colCourse, 76, 9, false, invoiceAddress)
+	}
+	d.TextRight(colAmount, 60, 16, true, "RECEIPT")
+	d.TextRight(colAmount, 76, 10, false, inv.InvoiceNumber)
+
+	y := 120.0
+	d.Text(colCourse, y, 9, true, "BILLED TO")
+	d.Text(colCourse, y+14, 10, false, inv.BuyerName)
+	d.Text(colCourse, y+28, 10, false, inv.BuyerEmail)
+
+	d.TextRight(colAmount-110, y, 9, true, "Date")
+	d.TextRight(colAmount, y, 9, false, inv.IssuedAt.Format("02 Jan 2006"))
+	d.TextRight(colAmount-110, y+14, 9, true, "Payment ID")
+	d.TextRight(colAmount, y+14, 9, false, strconv.Itoa(int(inv.PaymentID)))
+	d.TextRight(colAmount-110, y+28, 9, true, "Payment method")
+	d.TextRight(colAmount, y+28, 9, false, inv.PaymentMethod)
+	d.TextRight(colAmount-110, y+42, 9, true, "Status")
+	d.TextRight(colAmount, y+42, 9, false, "PAID")
+
+	y = 200
+	d.Text(colCourse, y, 9, true, "Course")
+	d.TextRight(colQuantity, y, 9, true, "Qty")
+	d.TextRight(colPrice, y, 9, true, "Price")
+	d.TextRight(colDiscount, y, 9, true, "Discount")
+	d.TextRight(colAmount, y, 9, true, "Amount")
+	d.Line(colCourse, y+6, colAmount, y+6)
+
+	y += 22
+	for _, it := range inv.Items {
+		if y > pdf.PageHeight-120 {
+			d.AddPage()
+			y = 60
+		}
+		d.Text(colCourse, y, 10, false, truncate(it.CourseName, 48))
+		d.TextRight(colQuantity, y, 10, false, strconv.Itoa(int(it.Quantity)))
+		d.TextRight(colPrice, y, 10, false, util.FormatRupiah(it.UnitPrice))
+		d.TextRight(colDiscount, y, 10, false, util.FormatRupiah(-it.Discount))
+		d.TextRight(colAmount, y, 10, false, util.FormatRupiah(it.Amount))
+		y += 18
+	}
+	d.Line(colCourse, y-8, colAmount, y-8)
+
+	y += 8
+	totals := []struct {
+		label  string
+		amount int64
+	}{
+		{"Subtotal", inv.Subtotal},
+		{"Discount", -inv.Discount},
+		{fmt.Sprintf("Tax %s%% (included)", strconv.FormatFloat(inv.TaxRate, 'f', -1, 64)), inv.Tax},
+	}
+	for _, t := range totals {
+		d.TextRight(colDiscount, y, 10, false, t.label)
+		d.TextRight(colAmount, y, 10, false, util.FormatRupiah(t.amount))
+		y += 16
+	}
+	d.TextRight(colDiscount, y+4, 12, true, "Total")
+	d.TextRight(colAmount, y+4, 12, true, util.FormatRupiah(inv.Total))
+
+	d.Text(colCourse, pdf.PageHeight-50, 8, false, "This receipt is generated electronically and is valid without a signature.")
+
+	return d.Bytes()
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
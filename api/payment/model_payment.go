@@ -41,4 +41,31 @@ type (
 		PaymentStatusName     sql.NullString `json:"payment_status_name"`
 		SubcriptionsStartDate sql.NullTime   `json:"subcriptions_start_date"`
 	}
+
+	Invoice struct {
+		InvoiceID     int32         `json:"invoice_id"`
+		InvoiceNumber string        `json:"invoice_number"`
+		PaymentID     int32         `json:"payment_id"`
+		UserID        int32         `json:"user_id"`
+		BuyerName     string        `json:"buyer_name"`
+		BuyerEmail    string        `json:"buyer_email"`
+		PaymentMethod string        `json:"payment_method"`
+		Items         []InvoiceItem `json:"items"`
+		Subtotal      int64         `json:"subtotal"`
+		Discount      int64         `json:"discount"`
+		Tax           int64         `json:"tax"`
+		TaxRate       float64       `json:"tax_rate"` // percent, included in the total
+		Total         int64         `json:"total"`
+		FileURL       string        `json:"file_url"`
+		IssuedAt      time.Time     `json:"issued_at"`
+	}
+
+	InvoiceItem struct {
+		CourseID   int32  `json:"course_id"`
+		CourseName string `json:"course_name"`
+		UnitPrice  int64  `json:"unit_price"`
+		Quantity   int32  `json:"quantity"`
+		Discount   int64  `json:"discount"`
+		Amount     int64  `json:"amount"`
+	}
 )
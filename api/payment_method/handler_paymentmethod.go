@@ -3,16 +3,17 @@ package paymentmethod
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
@@ -21,33 +22,37 @@ func (h *Handler) CreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
 	// Decode JSON request body
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("error parsing request: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Store payment method in the database
-	err = h.db.CreatePaymentMethod(r.Context(), repo.CreatePaymentMethodParams{
-		PaymentMethodName: req.PaymentMethodName,
-		CreatedAt:         util.SqlTime(time.Now()),
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		created, err := q.CreatePaymentMethod(r.Context(), repo.CreatePaymentMethodParams{
+			PaymentMethodName: req.PaymentMethodName,
+			CreatedAt:         util.SqlTime(time.Now()),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditPaymentMethodCreate,
+			Resource:   constant.AuditPaymentMethod,
+			ResourceID: created.PaymentMethodID,
+			After:      created,
+		}, nil
 	})
-
 	if err != nil {
-		log.Printf("error creating payment method: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating payment method", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error creating payment method")
+		apperr.WriteError(w, r, apperr.Internal("Error creating payment method"))
 		return
 	}
 
@@ -58,8 +63,8 @@ func (h *Handler) GetAllPaymentMethod(w http.ResponseWriter, r *http.Request) {
 	// Fetch all payment methods from the database
 	paymentMethods, err := h.db.GetAllPaymentMethod(r.Context())
 	if err != nil {
-		log.Printf("error fetching payment methods: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error fetching payment methods", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching payment methods")
+		apperr.WriteError(w, r, apperr.Internal("Error fetching payment methods"))
 		return
 	}
 
@@ -79,8 +84,8 @@ func (h *Handler) GetPaymentMethodById(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Printf("invalid payment method ID: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid payment method ID", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("invalid payment method ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid payment method ID"))
 		return
 	}
 
@@ -88,10 +93,10 @@ func (h *Handler) GetPaymentMethodById(w http.ResponseWriter, r *http.Request) {
 	paymentMethod, err := h.db.GetPaymentMethodByID(r.Context(), int32(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			util.NewResponse(http.StatusNotFound, http.StatusNotFound, "Payment method not found", struct{}{}).WriteResponse(w, r)
+			apperr.WriteError(w, r, apperr.NotFound("Payment method not found"))
 		} else {
-			log.Printf("error fetching payment method: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error fetching payment method", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error fetching payment method")
+			apperr.WriteError(w, r, apperr.Internal("Error fetching payment method"))
 		}
 		return
 	}
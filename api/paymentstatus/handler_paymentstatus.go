@@ -3,16 +3,17 @@ package paymentstatus
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreatePaymentStatus(w http.ResponseWriter, r *http.Request) {
@@ -21,33 +22,37 @@ func (h *Handler) CreatePaymentStatus(w http.ResponseWriter, r *http.Request) {
 	// Decode JSON request body
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("error parsing request: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Store payment status in the database
-	err = h.db.CreatePaymentStatus(r.Context(), repo.CreatePaymentStatusParams{
-		PaymentStatusName: req.PaymentStatusName,
-		CreatedAt:         util.SqlTime(time.Now()),
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		created, err := q.CreatePaymentStatus(r.Context(), repo.CreatePaymentStatusParams{
+			PaymentStatusName: req.PaymentStatusName,
+			CreatedAt:         util.SqlTime(time.Now()),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditPaymentStatusCreate,
+			Resource:   constant.AuditPaymentStatus,
+			ResourceID: created.PaymentStatusID,
+			After:      created,
+		}, nil
 	})
-
 	if err != nil {
-		log.Printf("error creating payment status: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating payment status", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error creating payment status")
+		apperr.WriteError(w, r, apperr.Internal("Error creating payment status"))
 		return
 	}
 
@@ -58,8 +63,8 @@ func (h *Handler) GetAllPaymentStatus(w http.ResponseWriter, r *http.Request) {
 	// Fetch all payment statuses from the database
 	paymentStatuses, err := h.db.GetAllPaymentStatus(r.Context())
 	if err != nil {
-		log.Printf("error fetching payment statuses: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error fetching payment statuses", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching payment statuses")
+		apperr.WriteError(w, r, apperr.Internal("Error fetching payment statuses"))
 		return
 	}
 
@@ -71,8 +76,8 @@ func (h *Handler) GetPaymentStatusById(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Printf("invalid payment status ID: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid payment status ID", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("invalid payment status ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid payment status ID"))
 		return
 	}
 
@@ -80,10 +85,10 @@ func (h *Handler) GetPaymentStatusById(w http.ResponseWriter, r *http.Request) {
 	paymentStatus, err := h.db.GetPaymentStatusByID(r.Context(), int32(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			util.NewResponse(http.StatusNotFound, http.StatusNotFound, "Payment status not found", struct{}{}).WriteResponse(w, r)
+			apperr.WriteError(w, r, apperr.NotFound("Payment status not found"))
 		} else {
-			log.Printf("error fetching payment status: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error fetching payment status", struct{}{}).WriteResponse(w, r)
+			logger.Err(r.Context(), err).Msg("error fetching payment status")
+			apperr.WriteError(w, r, apperr.Internal("Error fetching payment status"))
 		}
 		return
 	}
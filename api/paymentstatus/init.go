@@ -3,13 +3,15 @@ package paymentstatus
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	audit    *audit.Log
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, audit *audit.Log) *Handler {
+	return &Handler{validate, db, audit}
 }
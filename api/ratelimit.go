@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net"
+
+	"github.com/online-bnsp/backend/util/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitConfig of the api, a policy without a limit is not enforced
+type RateLimitConfig struct {
+	Enabled   bool
+	Allowlist []string         // internal callers, ips or cidrs
+	Default   ratelimit.Policy // every route, per user or per address before the login
+	Auth      ratelimit.Policy // sign in and register, per address
+	Account   ratelimit.LockoutPolicy
+	Address   ratelimit.LockoutPolicy
+}
+
+var (
+	rateLimitConfig    RateLimitConfig
+	rateLimitAllowlist []*net.IPNet
+)
+
+// SetRateLimitConfig set the limits of the api, it fails on an invalid allowlist
+func SetRateLimitConfig(c RateLimitConfig) error {
+	allowlist, err := ratelimit.ParseAllowlist(c.Allowlist)
+	if err != nil {
+		return err
+	}
+	c.Default.Name, c.Auth.Name = "default", "auth"
+	rateLimitConfig, rateLimitAllowlist = c, allowlist
+	return nil
+}
+
+// newLimiter is nil when rate limiting is disabled, the middlewares and the login guard let
+// everything through then
+func newLimiter(rdb *redis.Client) *ratelimit.Limiter {
+	if !rateLimitConfig.Enabled || rdb == nil {
+		return nil
+	}
+	return ratelimit.New(rdb, rateLimitAllowlist)
+}
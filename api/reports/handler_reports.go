@@ -0,0 +1,186 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/export"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// Export streams the resource straight to the response, ?format=csv|xlsx
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	resource := chi.URLParam(r, "resource")
+	if !Supported(resource) {
+		apperr.WriteError(w, r, apperr.NotFound("Unknown export resource"))
+		return
+	}
+
+	format, f, ok := parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", resource, time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// the server write timeout would cut a large file short after the 200, the export stops
+	// with the request context when the client goes away
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		logger.Err(r.Context(), err).Msg("error clearing export write deadline")
+	}
+
+	ew, err := export.New(format, &flushWriter{w})
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error creating export writer")
+		return
+	}
+
+	// headers are already sent, a failure can only cut the file short
+	if _, err := Export(r.Context(), h.db, resource, f, ew); err != nil {
+		logger.Err(r.Context(), err).Str("resource", resource).Msg("error exporting")
+	}
+}
+
+// CreateExportJob queue the export to be generated by the consumer
+func (h *Handler) CreateExportJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	resource := chi.URLParam(r, "resource")
+	if !Supported(resource) {
+		apperr.WriteError(w, r, apperr.NotFound("Unknown export resource"))
+		return
+	}
+
+	format, f, ok := parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	filters, _ := json.Marshal(f)
+	job, err := h.db.CreateExportJob(ctx, repo.CreateExportJobParams{
+		Resource:    resource,
+		Format:      format,
+		Filters:     filters,
+		Status:      constant.ExportPending,
+		RequestedBy: userID,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating export job")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := h.producer.Publish(ctx, constant.ExportReport, ExportMessage{JobID: job.JobID}); err != nil {
+		logger.Err(ctx, err).Msg("error publishing export job")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusAccepted, http.StatusAccepted, "Export queued", toExportJob(ctx, job)).WriteResponse(w, r)
+}
+
+func (h *Handler) GetExportJobs(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetExportJobs(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching export jobs")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []ExportJob{}
+	for _, d := range data {
+		res = append(res, toExportJob(r.Context(), d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetExportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation("Invalid export job ID"))
+		return
+	}
+
+	job, err := h.db.GetExportJobByID(r.Context(), int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Export job not found"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching export job")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toExportJob(r.Context(), job)).WriteResponse(w, r)
+}
+
+func parseRequest(w http.ResponseWriter, r *http.Request) (string, Filter, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		apperr.WriteError(w, r, apperr.Validation("format must be one of csv or xlsx"))
+		return "", Filter{}, false
+	}
+
+	f, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation(err.Error()))
+		return "", Filter{}, false
+	}
+
+	return format, f, true
+}
+
+func toExportJob(ctx context.Context, d repo.ExportJob) ExportJob {
+	job := ExportJob{
+		JobID:       d.JobID,
+		Resource:    d.Resource,
+		Format:      d.Format,
+		Status:      d.Status,
+		FileURL:     d.FileUrl.String,
+		RowCount:    d.RowCount,
+		Error:       d.Error.String,
+		RequestedBy: d.RequestedBy,
+		CreatedAt:   d.CreatedAt,
+	}
+	if err := json.Unmarshal(d.Filters, &job.Filter); err != nil {
+		logger.Warn(ctx, err).Int32("job_id", d.JobID).Msg("error decoding export filters")
+	}
+	if d.StartedAt.Valid {
+		job.StartedAt = &d.StartedAt.Time
+	}
+	if d.FinishedAt.Valid {
+		job.FinishedAt = &d.FinishedAt.Time
+	}
+	return job
+}
+
+// flushWriter sends every flushed page to the client instead of buffering the whole response
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,17 @@
+package reports
+
+import (
+	"github.com/go-playground/validator/v10"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	queue "github.com/online-bnsp/backend/util/queue"
+)
+
+type Handler struct {
+	validate *validator.Validate
+	db       *repo.Queries
+	producer queue.Producer // async exports are generated by the consumer
+}
+
+func NewHandler(validate *validator.Validate, db *repo.Queries, producer queue.Producer) *Handler {
+	return &Handler{validate, db, producer}
+}
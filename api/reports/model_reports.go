@@ -0,0 +1,34 @@
+package reports
+
+import "time"
+
+type (
+	// Filter narrows an export, it is stored with async jobs so every field is plain json
+	Filter struct {
+		From     string `json:"from,omitempty"` // YYYY-MM-DD, inclusive
+		To       string `json:"to,omitempty"`   // YYYY-MM-DD, inclusive
+		Role     string `json:"role,omitempty"`
+		StatusID int32  `json:"status_id,omitempty"`
+		CourseID int32  `json:"course_id,omitempty"`
+	}
+
+	// ExportMessage is published to the export topic
+	ExportMessage struct {
+		JobID int32 `json:"job_id"`
+	}
+
+	ExportJob struct {
+		JobID       int32      `json:"job_id"`
+		Resource    string     `json:"resource"`
+		Format      string     `json:"format"`
+		Filter      Filter     `json:"filter"`
+		Status      string     `json:"status"`
+		FileURL     string     `json:"file_url,omitempty"`
+		RowCount    int64      `json:"row_count"`
+		Error       string     `json:"error,omitempty"`
+		RequestedBy int32      `json:"requested_by"`
+		CreatedAt   time.Time  `json:"created_at"`
+		StartedAt   *time.Time `json:"started_at"`
+		FinishedAt  *time.Time `json:"finished_at"`
+	}
+)
@@ -0,0 +1,237 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/export"
+)
+
+// rows fetched per query, the writer is flushed after every page
+const pageSize = 500
+
+const timeLayout = "2006-01-02 15:04:05"
+
+type resource struct {
+	header []string
+	// page returns the rows following the row with the id `after` and the id of its last row
+	page func(ctx context.Context, db *repo.Queries, f filter, after int32) ([][]string, int32, error)
+}
+
+// filter is the parsed Filter
+type filter struct {
+	Filter
+	from, to sql.NullTime
+}
+
+var resources = map[string]resource{
+	"payments": {
+		header: []string{"payment_id", "user_id", "name", "email", "payment_method", "payment_status", "total_amount", "payment_date"},
+		page: func(ctx context.Context, db *repo.Queries, f filter, after int32) ([][]string, int32, error) {
+			data, err := db.ExportPayments(ctx, repo.ExportPaymentsParams{
+				AfterID:  after,
+				FromDate: f.from,
+				ToDate:   f.to,
+				StatusID: f.StatusID,
+				PageSize: pageSize,
+			})
+			if err != nil || len(data) == 0 {
+				return nil, after, err
+			}
+
+			rows := make([][]string, 0, len(data))
+			for _, d := range data {
+				rows = append(rows, []string{
+					itoa(d.PaymentID),
+					nullInt(d.UserID),
+					d.Nama.String,
+					d.Email.String,
+					d.PaymentMethodName.String,
+					d.PaymentStatusName.String,
+					nullInt(d.TotalAmount),
+					nullTime(d.PaymentDate),
+				})
+			}
+			return rows, data[len(data)-1].PaymentID, nil
+		},
+	},
+	"subscriptions": {
+		header: []string{"subscription_id", "user_id", "name", "email", "course_id", "course_name", "payment_id", "is_correct", "created_at", "updated_at"},
+		page: func(ctx context.Context, db *repo.Queries, f filter, after int32) ([][]string, int32, error) {
+			data, err := db.ExportSubscriptions(ctx, repo.ExportSubscriptionsParams{
+				AfterID:  after,
+				FromDate: f.from,
+				ToDate:   f.to,
+				CourseID: f.CourseID,
+				PageSize: pageSize,
+			})
+			if err != nil || len(data) == 0 {
+				return nil, after, err
+			}
+
+			rows := make([][]string, 0, len(data))
+			for _, d := range data {
+				rows = append(rows, []string{
+					itoa(d.SubscriptionID),
+					nullInt(d.UserID),
+					d.Nama.String,
+					d.Email.String,
+					nullInt(d.CourseID),
+					d.CourseName.String,
+					nullInt(d.PaymentID),
+					d.IsCorrect,
+					nullTime(d.CreatedAt),
+					nullTime(d.UpdatedAt),
+				})
+			}
+			return rows, data[len(data)-1].SubscriptionID, nil
+		},
+	},
+	"users": {
+		header: []string{"user_id", "name", "email", "role", "created_at"},
+		page: func(ctx context.Context, db *repo.Queries, f filter, after int32) ([][]string, int32, error) {
+			data, err := db.ExportUsers(ctx, repo.ExportUsersParams{
+				AfterID:  after,
+				FromDate: f.from,
+				ToDate:   f.to,
+				Role:     f.Role,
+				PageSize: pageSize,
+			})
+			if err != nil || len(data) == 0 {
+				return nil, after, err
+			}
+
+			rows := make([][]string, 0, len(data))
+			for _, d := range data {
+				rows = append(rows, []string{
+					itoa(d.UserID),
+					d.Nama,
+					d.Email,
+					d.Role,
+					nullTime(d.CreatedAt),
+				})
+			}
+			return rows, data[len(data)-1].UserID, nil
+		},
+	},
+}
+
+// Supported reports whether the resource can be exported
+func Supported(resource string) bool {
+	_, ok := resources[resource]
+	return ok
+}
+
+// ParseFilter read the export filters from the query string
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		From: q.Get("from"),
+		To:   q.Get("to"),
+		Role: q.Get("role"),
+	}
+
+	for name, dst := range map[string]*int32{"status_id": &f.StatusID, "course_id": &f.CourseID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return f, errors.New("invalid " + name)
+			}
+			*dst = int32(id)
+		}
+	}
+
+	_, err := f.parse()
+	return f, err
+}
+
+func (f Filter) parse() (filter, error) {
+	res := filter{Filter: f}
+
+	if f.From != "" {
+		t, err := time.Parse(time.DateOnly, f.From)
+		if err != nil {
+			return res, errors.New("invalid from date, use YYYY-MM-DD")
+		}
+		res.from = util.SqlTime(t)
+	}
+	if f.To != "" {
+		t, err := time.Parse(time.DateOnly, f.To)
+		if err != nil {
+			return res, errors.New("invalid to date, use YYYY-MM-DD")
+		}
+		res.to = util.SqlTime(t.AddDate(0, 0, 1))
+	}
+	if res.from.Valid && res.to.Valid && !res.from.Time.Before(res.to.Time) {
+		return res, errors.New("from must be before to")
+	}
+
+	return res, nil
+}
+
+// Export writes the header and every matching row of the resource, returns the number of rows written
+func Export(ctx context.Context, db *repo.Queries, name string, f Filter, w export.Writer) (int64, error) {
+	res, ok := resources[name]
+	if !ok {
+		return 0, errors.New("unknown export resource " + name)
+	}
+
+	parsed, err := f.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.Write(res.header); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	var after int32
+	for {
+		rows, last, err := res.page(ctx, db, parsed, after)
+		if err != nil {
+			return count, err
+		}
+
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				return count, err
+			}
+		}
+		count += int64(len(rows))
+
+		if err := w.Flush(); err != nil {
+			return count, err
+		}
+
+		if len(rows) < pageSize {
+			break
+		}
+		after = last
+	}
+
+	return count, w.Close()
+}
+
+func itoa(i int32) string {
+	return strconv.Itoa(int(i))
+}
+
+func nullInt(i sql.NullInt32) string {
+	if !i.Valid {
+		return ""
+	}
+	return itoa(i.Int32)
+}
+
+func nullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(timeLayout)
+}
@@ -0,0 +1,505 @@
+package revenue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/ledger"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+// GetDashboard show the logged in teacher enrollments, revenue and refunds per course,
+// grouped in daily, weekly or monthly buckets between from and to (inclusive, YYYY-MM-DD)
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	period, err := util.ParseDateRange(r.URL.Query(), "day")
+	if err != nil {
+		apperr.WriteError(w, r, apperr.Validation(err.Error()))
+		return
+	}
+
+	rows, err := h.db.GetTeacherRevenue(ctx, repo.GetTeacherRevenueParams{
+		Bucket:   period.Interval,
+		UserID:   util.SqlInt32(userID),
+		FromDate: period.From,
+		ToDate:   period.End(),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching teacher revenue")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	balance, err := h.db.GetTeacherBalance(ctx, util.SqlInt32(userID))
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching teacher balance")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := Dashboard{
+		DateRange:     period,
+		PlatformShare: ledger.PlatformShare(),
+		Balance: Balance{
+			Earned:   balance.Earned,
+			Refunded: balance.Refunded,
+			PaidOut:  balance.PaidOut,
+			Balance:  balance.Balance,
+		},
+		Courses: []CourseRevenue{},
+	}
+
+	index := map[int32]int{}
+	for _, row := range rows {
+		t := Totals{
+			Enrollments:      row.Enrollments,
+			GrossRevenue:     row.GrossRevenue,
+			Earnings:         row.Earnings,
+			Refunds:          row.Refunds,
+			RefundedEarnings: row.RefundedEarnings,
+		}
+
+		i, ok := index[row.CourseID]
+		if !ok {
+			i = len(res.Courses)
+			index[row.CourseID] = i
+			res.Courses = append(res.Courses, CourseRevenue{CourseID: row.CourseID, CourseName: row.CourseName})
+		}
+
+		c := &res.Courses[i]
+		c.Buckets = append(c.Buckets, RevenueBucket{Bucket: row.Bucket, Totals: t})
+		c.Totals.add(t)
+		res.Summary.add(t)
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// GetMyPayouts list the payouts of the logged in teacher
+func (h *Handler) GetMyPayouts(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(int32)
+
+	data, err := h.db.GetPayoutsByUser(r.Context(), userID)
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching teacher payouts")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []Payout{}
+	for _, p := range data {
+		res = append(res, Payout{
+			PayoutID:  p.PayoutID,
+			BatchID:   p.BatchID,
+			Status:    p.Status,
+			Amount:    p.Amount,
+			CreatedAt: p.CreatedAt,
+			PaidAt:    nullTime(p.PaidAt),
+			Reference: p.Reference.String,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// RefundTransaction mark a paid transaction as refunded and reverse its ledger entries
+func (h *Handler) RefundTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid transaction ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid transaction ID"))
+		return
+	}
+
+	var refunded repo.RefundTransactionRow
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		refunded, err = q.RefundTransaction(ctx, repo.RefundTransactionParams{
+			TransactionHistoryID: int32(id),
+			UpdatedAt:            util.SqlTime(time.Now()),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+
+		// the sale has to be in the ledger before it can be reversed
+		if refunded.PaymentID.Valid {
+			if err := ledger.PostSales(ctx, q, refunded.PaymentID.Int32); err != nil {
+				return audit.Entry{}, err
+			}
+		}
+		if err := ledger.PostRefunds(ctx, q, refunded.TransactionHistoryID); err != nil {
+			return audit.Entry{}, err
+		}
+
+		return audit.Entry{
+			Action:     constant.AuditTransactionRefund,
+			Resource:   constant.AuditTransaction,
+			ResourceID: refunded.TransactionHistoryID,
+			Before:     map[string]any{"is_paid": constant.TransactionPaid},
+			After:      map[string]any{"is_paid": constant.TransactionRefunded, "total_amount": refunded.TotalAmount},
+		}, nil
+	})
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Paid transaction not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error refunding transaction")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if refunded.UserID.Valid {
+		_, err = h.inbox.Notify(ctx, refunded.UserID.Int32, inbox.Event{
+			Type:     constant.NotificationRefund,
+			CourseID: refunded.CourseID.Int32,
+			Title:    "Refund approved",
+			Message:  fmt.Sprintf("Your refund of %s has been approved", util.FormatRupiah(int64(refunded.TotalAmount))),
+			Data:     map[string]any{"transaction_history_id": refunded.TransactionHistoryID, "approved": true},
+		})
+		if err != nil {
+			logger.Err(ctx, err).Msg("error notifying refund")
+		}
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Transaction refunded successfully", struct{}{}).WriteResponse(w, r)
+}
+
+func (h *Handler) CreatePayoutBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	var req PayoutBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-payoutHold)
+	if req.CutoffAt != nil {
+		if req.CutoffAt.After(now) {
+			apperr.WriteError(w, r, apperr.Validation("cutoff_at can not be in the future"))
+			return
+		}
+		cutoff = *req.CutoffAt
+	}
+
+	tx, err := h.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error starting transaction")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	defer tx.Rollback()
+
+	q := repo.New(tracing.WrapDB(tx))
+	if err := q.LockPayouts(ctx); err != nil {
+		logger.Err(ctx, err).Msg("error locking payouts")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := h.postLedger(ctx, q); err != nil {
+		logger.Err(ctx, err).Msg("error posting ledger entries")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	batch, err := q.CreatePayoutBatch(ctx, repo.CreatePayoutBatchParams{
+		Status:    constant.PayoutPending,
+		CutoffAt:  cutoff,
+		CreatedBy: userID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating payout batch")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	payouts, err := q.CreateBatchPayouts(ctx, repo.CreateBatchPayoutsParams{
+		BatchID:   batch.BatchID,
+		CreatedAt: now,
+		CutoffAt:  cutoff,
+		MinAmount: minPayout,
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating payouts")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if len(payouts) == 0 {
+		apperr.WriteError(w, r, apperr.Conflict("No teacher balance to pay out"))
+		return
+	}
+
+	if _, err := q.PostPayoutEntries(ctx, batch.BatchID); err != nil {
+		logger.Err(ctx, err).Msg("error posting payout entries")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := q.UpdatePayoutBatchTotal(ctx, batch.BatchID); err != nil {
+		logger.Err(ctx, err).Msg("error updating payout batch total")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	var total int64
+	for _, p := range payouts {
+		total += p.Amount
+	}
+	err = audit.Record(r, q, audit.Entry{
+		Action:     constant.AuditPayoutBatchCreate,
+		Resource:   constant.AuditPayoutBatch,
+		ResourceID: batch.BatchID,
+		After: map[string]any{
+			"status":       batch.Status,
+			"cutoff_at":    batch.CutoffAt,
+			"payouts":      len(payouts),
+			"total_amount": total,
+		},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error recording audit log")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Err(ctx, err).Msg("error committing payout batch")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	res := toPayoutBatch(batch)
+	for _, p := range payouts {
+		res.TotalAmount += p.Amount
+		res.Payouts = append(res.Payouts, Payout{
+			PayoutID:  p.PayoutID,
+			BatchID:   p.BatchID,
+			UserID:    p.UserID,
+			Amount:    p.Amount,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Payout batch created successfully", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetPayoutBatches(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetPayoutBatches(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching payout batches")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []PayoutBatch{}
+	for _, b := range data {
+		res = append(res, toPayoutBatch(b))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) GetPayoutBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid batch ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid batch ID"))
+		return
+	}
+
+	batch, err := h.db.GetPayoutBatchByID(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Payout batch not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching payout batch")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	payouts, err := h.db.GetPayoutsByBatch(ctx, batch.BatchID)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching payouts")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := toPayoutBatch(batch)
+	for _, p := range payouts {
+		res.Payouts = append(res.Payouts, Payout{
+			PayoutID:  p.PayoutID,
+			BatchID:   p.BatchID,
+			UserID:    p.UserID,
+			UserName:  p.Nama.String,
+			Email:     p.Email.String,
+			Amount:    p.Amount,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// MarkPayoutBatchPaid record that the bank transfers of a batch went out
+func (h *Handler) MarkPayoutBatchPaid(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid batch ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid batch ID"))
+		return
+	}
+
+	var req MarkPaidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	tx, err := h.conn.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error starting transaction")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	defer tx.Rollback()
+
+	q := repo.New(tracing.WrapDB(tx))
+	now := time.Now()
+
+	affected, err := q.MarkPayoutBatchPaid(ctx, repo.MarkPayoutBatchPaidParams{
+		BatchID:   int32(id),
+		Reference: util.SqlString(req.Reference),
+		PaidBy:    util.SqlInt32(userID),
+		PaidAt:    util.SqlTime(now),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error marking payout batch paid")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+	if affected == 0 {
+		apperr.WriteError(w, r, apperr.NotFound("Pending payout batch not found"))
+		return
+	}
+
+	_, err = q.PostPayoutPaidEntries(ctx, repo.PostPayoutPaidEntriesParams{
+		PaidAt:  now,
+		BatchID: int32(id),
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error posting payout paid entries")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	err = audit.Record(r, q, audit.Entry{
+		Action:     constant.AuditPayoutBatchPaid,
+		Resource:   constant.AuditPayoutBatch,
+		ResourceID: id,
+		Before:     map[string]any{"status": constant.PayoutPending},
+		After:      map[string]any{"status": constant.PayoutPaid, "reference": req.Reference},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error recording audit log")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Err(ctx, err).Msg("error committing payout batch")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Payout batch marked as paid", struct{}{}).WriteResponse(w, r)
+}
+
+// GetTrialBalance list debit and credit totals per ledger account, both columns must add up to the same amount
+func (h *Handler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetLedgerTrialBalance(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching trial balance")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []LedgerAccount{}
+	for _, a := range data {
+		res = append(res, LedgerAccount{Account: a.Account, Debit: a.Debit, Credit: a.Credit})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// postLedger catch the ledger up with every paid and refunded transaction before a payout, the
+// journals are normally posted when a payment is marked paid or refunded
+func (h *Handler) postLedger(ctx context.Context, q *repo.Queries) error {
+	if err := ledger.PostSales(ctx, q, 0); err != nil {
+		return err
+	}
+	return ledger.PostRefunds(ctx, q, 0)
+}
+
+func (t *Totals) add(o Totals) {
+	t.Enrollments += o.Enrollments
+	t.GrossRevenue += o.GrossRevenue
+	t.Earnings += o.Earnings
+	t.Refunds += o.Refunds
+	t.RefundedEarnings += o.RefundedEarnings
+}
+
+func toPayoutBatch(b repo.PayoutBatch) PayoutBatch {
+	return PayoutBatch{
+		BatchID:     b.BatchID,
+		Status:      b.Status,
+		CutoffAt:    b.CutoffAt,
+		TotalAmount: b.TotalAmount,
+		Reference:   b.Reference.String,
+		CreatedBy:   b.CreatedBy,
+		CreatedAt:   b.CreatedAt,
+		PaidBy:      b.PaidBy.Int32,
+		PaidAt:      nullTime(b.PaidAt),
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
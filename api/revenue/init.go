@@ -0,0 +1,40 @@
+package revenue
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+// earnings younger than payoutHold are kept out of payout batches so refunds can still be taken back
+var payoutHold time.Duration = 14 * 24 * time.Hour
+
+// minimum balance for a teacher to be included in a payout batch
+var minPayout int64 = 1
+
+// SetConfig set the payout hold period and the minimum payout, the platform share is set on the ledger
+func SetConfig(hold time.Duration, minAmount int64) {
+	if hold > 0 {
+		payoutHold = hold
+	}
+	if minAmount > 0 {
+		minPayout = minAmount
+	}
+}
+
+type Handler struct {
+	validate *validator.Validate
+	db       *repo.Queries
+	conn     *sql.DB // payout batches touch several tables and need a transaction
+	inbox    *inbox.Inbox
+	audit    *audit.Log
+}
+
+func NewHandler(validate *validator.Validate, conn *sql.DB, inbox *inbox.Inbox, audit *audit.Log) *Handler {
+	return &Handler{validate, repo.New(tracing.WrapDB(conn)), conn, inbox, audit}
+}
@@ -0,0 +1,88 @@
+package revenue
+
+import (
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+)
+
+type (
+	// Totals is shared by the dashboard summary, every course and every bucket
+	Totals struct {
+		Enrollments      int64 `json:"enrollments"`
+		GrossRevenue     int64 `json:"gross_revenue"` // amount paid by students
+		Earnings         int64 `json:"earnings"`      // teacher share of the gross revenue
+		Refunds          int64 `json:"refunds"`
+		RefundedEarnings int64 `json:"refunded_earnings"`
+	}
+
+	RevenueBucket struct {
+		Bucket time.Time `json:"bucket"`
+		Totals
+	}
+
+	CourseRevenue struct {
+		CourseID   int32  `json:"course_id"`
+		CourseName string `json:"course_name"`
+		Totals
+		Buckets []RevenueBucket `json:"buckets"`
+	}
+
+	Balance struct {
+		Earned   int64 `json:"earned"`
+		Refunded int64 `json:"refunded"`
+		PaidOut  int64 `json:"paid_out"`
+		Balance  int64 `json:"balance"` // not paid out yet
+	}
+
+	// Dashboard is the teacher revenue dashboard
+	Dashboard struct {
+		util.DateRange                 // from, to and interval as the dashboard always returned them
+		PlatformShare  float64         `json:"platform_share"` // percent
+		Summary        Totals          `json:"summary"`
+		Balance        Balance         `json:"balance"`
+		Courses        []CourseRevenue `json:"courses"`
+	}
+
+	PayoutBatch struct {
+		BatchID     int32      `json:"batch_id"`
+		Status      string     `json:"status"`
+		CutoffAt    time.Time  `json:"cutoff_at"`
+		TotalAmount int64      `json:"total_amount"`
+		Reference   string     `json:"reference"`
+		CreatedBy   int32      `json:"created_by"`
+		CreatedAt   time.Time  `json:"created_at"`
+		PaidBy      int32      `json:"paid_by,omitempty"`
+		PaidAt      *time.Time `json:"paid_at,omitempty"`
+		Payouts     []Payout   `json:"payouts,omitempty"`
+	}
+
+	Payout struct {
+		PayoutID  int32      `json:"payout_id"`
+		BatchID   int32      `json:"batch_id"`
+		UserID    int32      `json:"user_id,omitempty"`
+		UserName  string     `json:"user_name,omitempty"`
+		Email     string     `json:"email,omitempty"`
+		Status    string     `json:"status,omitempty"`
+		Amount    int64      `json:"amount"`
+		CreatedAt time.Time  `json:"created_at"`
+		PaidAt    *time.Time `json:"paid_at,omitempty"`
+		Reference string     `json:"reference,omitempty"`
+	}
+
+	// PayoutBatchRequest create a batch paying every teacher balance earned before the cutoff,
+	// the cutoff defaults to now minus the payout hold period
+	PayoutBatchRequest struct {
+		CutoffAt *time.Time `json:"cutoff_at"`
+	}
+
+	MarkPaidRequest struct {
+		Reference string `json:"reference" validate:"required"` // bank transfer reference
+	}
+
+	LedgerAccount struct {
+		Account string `json:"account"`
+		Debit   int64  `json:"debit"`
+		Credit  int64  `json:"credit"`
+	}
+)
@@ -0,0 +1,343 @@
+package roles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// the code relies on these roles for registration and teacher profiles
+var builtinRoles = []string{constant.RoleAdmin, constant.RoleTeacher, constant.RoleStudent}
+
+// GetPermissions list the permissions a role can be granted
+func (h *Handler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	util.NewResponse(http.StatusOK, http.StatusOK, "", auth.Permissions).WriteResponse(w, r)
+}
+
+// GetRoles list the roles with their permissions and the number of accounts holding them
+func (h *Handler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	data, err := h.db.GetRoles(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching roles")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []Role{}
+	for _, d := range data {
+		res = append(res, toRole(d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+func (h *Handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+	if err := checkPermissions(req.Permissions); err != nil {
+		apperr.WriteError(w, r, err)
+		return
+	}
+
+	err := h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		now := time.Now()
+		err := q.CreateRole(ctx, repo.CreateRoleParams{
+			Role:        req.Role,
+			Description: req.Description,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		perms, err := setPermissions(ctx, q, req.Role, req.Permissions)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditRoleCreate,
+			Resource:   constant.AuditRole,
+			ResourceID: req.Role,
+			After:      Role{Role: req.Role, Description: req.Description, Permissions: perms, CreatedAt: now, UpdatedAt: now},
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Role"))
+		return
+	}
+
+	util.NewResponse(http.StatusCreated, http.StatusCreated, "Role created successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// UpdateRole replace the description and the permissions of a role, they apply to its users
+// without signing in again
+func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+	role := chi.URLParam(r, "role")
+
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+	if err := checkPermissions(req.Permissions); err != nil {
+		apperr.WriteError(w, r, err)
+		return
+	}
+
+	err := h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		before, err := lockRole(ctx, q, role)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+
+		// nobody could manage the roles anymore when the own role loses the permission
+		if !contains(req.Permissions, auth.PermRoleManage) {
+			user, err := q.GetUserByID(ctx, userID)
+			if err != nil {
+				return audit.Entry{}, err
+			}
+			if user.Role == role {
+				return audit.Entry{}, apperr.Conflict("The own role can not lose " + auth.PermRoleManage)
+			}
+		}
+
+		after := before
+		after.Description, after.UpdatedAt = req.Description, time.Now()
+		err = q.UpdateRole(ctx, repo.UpdateRoleParams{
+			Role:        role,
+			Description: after.Description,
+			UpdatedAt:   after.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if after.Permissions, err = setPermissions(ctx, q, role, req.Permissions); err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditRoleUpdate,
+			Resource:   constant.AuditRole,
+			ResourceID: role,
+			Before:     before,
+			After:      after,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Role"))
+		return
+	}
+	h.authz.Invalidate()
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Role updated successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// DeleteRole remove a role no account holds, the built-in roles are kept
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	role := chi.URLParam(r, "role")
+
+	if contains(builtinRoles, role) {
+		apperr.WriteError(w, r, apperr.Conflict("Built-in roles can not be deleted"))
+		return
+	}
+
+	err := h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		before, err := lockRole(ctx, q, role)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+
+		users, err := q.CountUsersByRole(ctx, role)
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if users > 0 {
+			return audit.Entry{}, apperr.Conflict(fmt.Sprintf("Role is assigned to %d users", users)).WithCode("role_in_use")
+		}
+
+		if _, err = q.DeleteRole(ctx, role); err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditRoleDelete,
+			Resource:   constant.AuditRole,
+			ResourceID: role,
+			Before:     before,
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Role"))
+		return
+	}
+	h.authz.Invalidate()
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Role deleted successfully", struct{}{}).WriteResponse(w, r)
+}
+
+// AssignRole change the role of a user, the new permissions apply without signing in again
+func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Warn(ctx, err).Msg("invalid user ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid user ID"))
+		return
+	}
+	if int32(id) == userID {
+		apperr.WriteError(w, r, apperr.Forbidden("The own role can not be changed"))
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		if _, err := q.GetRoleForUpdate(ctx, req.Role); err != nil {
+			return audit.Entry{}, apperr.FromDB(err, "Role")
+		}
+
+		user, err := q.GetUserByID(ctx, int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		affected, err := q.UpdateUserRole(ctx, repo.UpdateUserRoleParams{
+			UserID: user.UserID,
+			Role:   req.Role,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, apperr.NotFound("User not found")
+		}
+		return audit.Entry{
+			Action:     constant.AuditUserRole,
+			Resource:   constant.AuditUser,
+			ResourceID: user.UserID,
+			Before:     map[string]any{"role": user.Role},
+			After:      map[string]any{"role": req.Role},
+		}, nil
+	})
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "User"))
+		return
+	}
+	h.authz.Invalidate()
+
+	res := map[string]interface{}{
+		"user_id": id,
+		"role":    req.Role,
+	}
+	util.NewResponse(http.StatusOK, http.StatusOK, "Role assigned successfully", res).WriteResponse(w, r)
+}
+
+// checkPermissions reject the permissions missing from the catalog, a typo would grant nothing
+func checkPermissions(perms []string) *apperr.Error {
+	e := apperr.Validation("Unknown permissions")
+	for i, p := range perms {
+		if !auth.KnownPermission(p) {
+			e.Fields = append(e.Fields, apperr.FieldError{
+				Field:   fmt.Sprintf("permissions[%d]", i),
+				Rule:    "permission",
+				Message: p + " is not a permission",
+			})
+		}
+	}
+	if len(e.Fields) > 0 {
+		return e
+	}
+	return nil
+}
+
+// setPermissions replace the permissions of role and return them sorted, without duplicates
+func setPermissions(ctx context.Context, q *repo.Queries, role string, perms []string) ([]string, error) {
+	if err := q.DeleteRolePermissions(ctx, role); err != nil {
+		return nil, err
+	}
+	set := []string{}
+	for _, p := range perms {
+		if contains(set, p) {
+			continue
+		}
+		set = append(set, p)
+		if err := q.AddRolePermission(ctx, repo.AddRolePermissionParams{Role: role, Permission: p}); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(set)
+	return set, nil
+}
+
+// lockRole lock a role for the rest of the transaction and load it with its permissions
+func lockRole(ctx context.Context, q *repo.Queries, role string) (Role, error) {
+	d, err := q.GetRoleForUpdate(ctx, role)
+	if err != nil {
+		return Role{}, err
+	}
+	perms, err := q.GetRolePermissions(ctx, role)
+	if err != nil {
+		return Role{}, err
+	}
+	if perms == nil {
+		perms = []string{}
+	}
+	sort.Strings(perms) // the database collation may order them differently
+	return Role{
+		Role:        d.Role,
+		Description: d.Description,
+		Permissions: perms,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
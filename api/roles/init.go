@@ -0,0 +1,22 @@
+package roles
+
+import (
+	"database/sql"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+type Handler struct {
+	validate *validator.Validate
+	db       *repo.Queries
+	authz    *auth.Authorizer
+	audit    *audit.Log // a role and its permissions are replaced in one transaction
+}
+
+func NewHandler(validate *validator.Validate, conn *sql.DB, authz *auth.Authorizer, audit *audit.Log) *Handler {
+	return &Handler{validate, repo.New(tracing.WrapDB(conn)), authz, audit}
+}
@@ -0,0 +1,45 @@
+package roles
+
+import (
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+type (
+	Role struct {
+		Role        string    `json:"role"`
+		Description string    `json:"description"`
+		Permissions []string  `json:"permissions"`
+		Users       int64     `json:"users"` // accounts holding the role
+		CreatedAt   time.Time `json:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at"`
+	}
+
+	CreateRoleRequest struct {
+		Role        string   `json:"role" validate:"required,max=225,lowercase,excludesall= :/"`
+		Description string   `json:"description" validate:"max=255"`
+		Permissions []string `json:"permissions" validate:"dive,required"`
+	}
+
+	// UpdateRoleRequest replace the description and every permission of a role
+	UpdateRoleRequest struct {
+		Description string   `json:"description" validate:"max=255"`
+		Permissions []string `json:"permissions" validate:"dive,required"`
+	}
+
+	AssignRoleRequest struct {
+		Role string `json:"role" validate:"required"`
+	}
+)
+
+func toRole(d repo.GetRolesRow) Role {
+	return Role{
+		Role:        d.Role,
+		Description: d.Description,
+		Permissions: d.Permissions,
+		Users:       d.Users,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
@@ -1,79 +1,133 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"os"
 	"path"
 
 	"github.com/go-chi/chi/v5"
-	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
+	"github.com/online-bnsp/backend/api/analytics"
+	auditlog "github.com/online-bnsp/backend/api/audit_log"
 	"github.com/online-bnsp/backend/api/cart"
 	"github.com/online-bnsp/backend/api/categories"
 	"github.com/online-bnsp/backend/api/courses"
 	coursesvideo "github.com/online-bnsp/backend/api/courses_video"
+	"github.com/online-bnsp/backend/api/notifications"
 	"github.com/online-bnsp/backend/api/payment"
 	paymentmethod "github.com/online-bnsp/backend/api/payment_method"
 	"github.com/online-bnsp/backend/api/paymentstatus"
+	"github.com/online-bnsp/backend/api/reports"
+	"github.com/online-bnsp/backend/api/revenue"
+	"github.com/online-bnsp/backend/api/roles"
+	"github.com/online-bnsp/backend/api/scheduler"
 	"github.com/online-bnsp/backend/api/subscriptions"
+	"github.com/online-bnsp/backend/api/teachers"
 	"github.com/online-bnsp/backend/api/user"
 	"github.com/online-bnsp/backend/api/wishlist"
+	"github.com/online-bnsp/backend/jobs"
 	"github.com/online-bnsp/backend/middleware"
 	"github.com/online-bnsp/backend/middleware/auth"
 	repo "github.com/online-bnsp/backend/repo/generated"
-	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
 	"github.com/online-bnsp/backend/util/buckets"
-	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/buckets/local"
+	"github.com/online-bnsp/backend/util/cartreminder"
+	"github.com/online-bnsp/backend/util/health"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/metrics"
+	"github.com/online-bnsp/backend/util/notify"
+	"github.com/online-bnsp/backend/util/pricealert"
 	queue "github.com/online-bnsp/backend/util/queue"
+	"github.com/online-bnsp/backend/util/ratelimit"
+	"github.com/online-bnsp/backend/util/tracing"
 	"github.com/redis/go-redis/v9"
 )
 
 type Handler struct {
 	router *chi.Mux
+
+	health *health.Checker
+
+	// released on shutdown
+	producer      queue.Producer
+	runner        *jobs.Runner
+	notifications *notifications.Handler
 }
 
 type RoleMiddleware func(http.Handler) http.Handler
 
 var validate *validator.Validate
 
-func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, mail *mailer.Mailer, cors RoleMiddleware) *Handler {
+func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, cors RoleMiddleware) *Handler {
 	r := chi.NewMux()
-	r.Use(chiMiddleware.Logger)
 	r.Use(middleware.BirthTime)
+	r.Use(middleware.Tracing)
+	r.Use(middleware.Metrics)
+	r.Use(middleware.RequestLogger)
 	r.Use(auth.ExtractTokenClaims) // Extract JWT claims into context
 	r.Use(cors)                    // CORS Middleware, if needed
 
+	limiter := newLimiter(rdb)
+	r.Use(middleware.RateLimit(limiter, rateLimitConfig.Default, middleware.ByUser))
+	authLimit := middleware.RateLimit(limiter, rateLimitConfig.Auth, middleware.ByRoute(middleware.ByIP))
+
 	h := &Handler{
 		router: r,
 	}
 
 	validate = validator.New(validator.WithRequiredStructEnabled())
-	dbGenerated := repo.New(db)
+	validate.RegisterTagNameFunc(apperr.JSONFieldName) // the fields of validation errors are named as the client sent them
+	dbGenerated := repo.New(tracing.WrapDB(db))
 
 	r.Get("/ping", h.Ping)
+	r.Get("/healthz", h.Healthz)
+	r.Get("/readyz", h.Readyz)
+	r.Handle("/metrics", metrics.Handler())
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "404 Not found!", nil).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.NotFound("404 Not found!"))
 	})
 
+	// exports and notifications are handled by the consumer
+	producer, err := q.NewProducer(queue.NsqProducerArgs{})
+	if err != nil {
+		logger.Err(context.Background(), err).Msg("error creating queue producer")
+	}
+	publisher := notify.NewPublisher(producer)
+	h.health = newHealthChecker(db, rdb, producer, bucket)
+	notificationInbox := inbox.New(dbGenerated, rdb)
+	catalogCache := newCatalogCache(rdb)
+	authz := auth.NewAuthorizer(dbGenerated)
+	auditLog := audit.New(db)
+
 	//payment Handler
-	PaymentHandler := payment.NewHandler(validate, dbGenerated)
+	PaymentHandler := payment.NewHandler(validate, db, bucket, publisher, notificationInbox, auditLog)
 	// Routes for payment
 	r.Route("/payment", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
 
-		r.Post("/create-payment", PaymentHandler.CreatePayment)
-		r.Get("/get-payment", PaymentHandler.GetPayment)
-		r.Get("/get-paymenthistory", PaymentHandler.GetPaymentHistory)
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermPaymentCreate))
+
+			r.Post("/create-payment", PaymentHandler.CreatePayment)
+			r.Get("/get-payment", PaymentHandler.GetPayment)
+			r.Get("/get-paymenthistory", PaymentHandler.GetPaymentHistory)
+		})
+
+		// the buyer, or every invoice with payment:read:any
+		r.With(authz.RequirePermission(auth.PermPaymentRead, auth.PermPaymentReadAny)).Get("/{id}/invoice", PaymentHandler.GetInvoice)
 	})
 
 	//paymentmethod Handler
-	PaymentMethodHandler := paymentmethod.NewHandler(validate, dbGenerated)
+	PaymentMethodHandler := paymentmethod.NewHandler(validate, dbGenerated, auditLog)
 	// Routes for paymentmethod
 	r.Route("/payment-method", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
+		r.Use(authz.RequirePermission(auth.PermPaymentCreate))
 
 		r.Post("/create-paymentmethod", PaymentMethodHandler.CreatePaymentMethod)
 		r.Get("/get-paymentmethod", PaymentMethodHandler.GetAllPaymentMethod)
@@ -81,9 +135,10 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	})
 
 	//paymentstatus Handler
-	PaymentStatusHandler := paymentstatus.NewHandler(validate, dbGenerated)
+	PaymentStatusHandler := paymentstatus.NewHandler(validate, dbGenerated, auditLog)
 	r.Route("/paymentstatus", func(r chi.Router) {
-		r.Use(auth.RequireRole("student"))
+		r.Use(auth.AuthMiddleware)
+		r.Use(authz.RequirePermission(auth.PermPaymentCreate))
 
 		r.Post("/create-paymentsstatus", PaymentStatusHandler.CreatePaymentStatus)
 		r.Get("/get-paymentsstatus", PaymentStatusHandler.GetAllPaymentStatus)
@@ -92,10 +147,10 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	// Routes for paymentsstatus
 
 	//subscriptions Handler
-	SubscriptionHandler := subscriptions.NewHandler(validate, dbGenerated)
+	SubscriptionHandler := subscriptions.NewHandler(validate, dbGenerated, auditLog)
 	r.Route("/subscription", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
+		r.Use(authz.RequirePermission(auth.PermPaymentCreate))
 
 		// Routes for subscriptions
 		r.Post("/create-subscription", SubscriptionHandler.CreateSubscription)
@@ -103,30 +158,57 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	})
 
 	// Course Handler
-	CoursesHandler := courses.NewHandler(validate, dbGenerated)
+	CoursesHandler := courses.NewHandler(validate, dbGenerated, notificationInbox, pricealert.New(dbGenerated, notificationInbox, publisher), catalogCache, auditLog)
 	// Routes for courses
 
 	r.Route("/my-course", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
+		r.Use(authz.RequirePermission(auth.PermCourseStudy))
 
 		r.Get("/", CoursesHandler.GetMyCoursePage)
 		r.Get("/{course_id}", CoursesHandler.GetMyCourse)
+		r.Post("/{course_id}/rating", CoursesHandler.RateCourse)
 	})
+	// Teacher Handler
+	TeacherHandler := teachers.NewHandler(validate, dbGenerated, auditLog)
+	RevenueHandler := revenue.NewHandler(validate, db, notificationInbox, auditLog)
+
 	r.Route("/teacher", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("teacher", "admin"))
+		r.Use(authz.RequirePermission(auth.PermCourseWrite))
 
 		r.Post("/create-course", CoursesHandler.CreateCourses)
 		r.Put("/update-course/{id}", CoursesHandler.UpdateCourse)
 		r.Delete("/delete-course/{id}", CoursesHandler.DeleteCourse)
+
+		// publishing workflow
+		r.Get("/my-course-list", CoursesHandler.GetTeacherCourses)
+		r.Get("/course-review/{id}", CoursesHandler.GetPublicationLog)
+		r.Post("/submit-course/{id}", CoursesHandler.SubmitCourse)
+		r.Post("/unpublish-course/{id}", CoursesHandler.UnpublishCourse)
+		r.Post("/course-rating/{id}/reply", CoursesHandler.ReplyCourseRating)
+
+		// scheduled sales
+		r.Get("/course-sale/{id}", CoursesHandler.GetCourseSales)
+		r.Post("/course-sale/{id}", CoursesHandler.CreateCourseSale)
+		r.Delete("/delete-course-sale/{id}", CoursesHandler.DeleteCourseSale)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermTeacherProfile))
+
+			r.Get("/profile", TeacherHandler.GetProfile)
+			r.Put("/profile", TeacherHandler.UpdateProfile)
+			r.Get("/dashboard", RevenueHandler.GetDashboard)
+			r.Get("/payouts", RevenueHandler.GetMyPayouts)
+		})
 	})
 
 	// Cart Handler
-	CartHandler := cart.NewHandler(validate, dbGenerated)
+	CartHandler := cart.NewHandler(validate, dbGenerated, cartreminder.New(dbGenerated, publisher))
+	r.Get("/cart-return", CartHandler.ReturnToCart) // link of the abandoned cart reminders
 	r.Route("/cart", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
+		r.Use(authz.RequirePermission(auth.PermCartWrite))
 
 		r.Post("/create-cart", CartHandler.CreateCart)
 		r.Get("/getall-cart", CartHandler.GetAllCart)
@@ -135,13 +217,13 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	})
 
 	//course_video handler
-	coursesVideo := coursesvideo.NewHandler(validate, dbGenerated)
+	coursesVideo := coursesvideo.NewHandler(validate, dbGenerated, notificationInbox, catalogCache, auditLog)
 
 	r.Get("/course_video", coursesVideo.GetCourseVideoHandler)
 	// route course_video
 	r.Route("/course_video", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("teacher"))
+		r.Use(authz.RequirePermission(auth.PermVideoWrite))
 
 		r.Post("/create-course_video", coursesVideo.CreateCourseVideo)
 		r.Put("/update-course_video/{id}", coursesVideo.UpdateCourseVideo)
@@ -152,7 +234,7 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	WishlistHandler := wishlist.NewHandler(validate, dbGenerated)
 	r.Route("/wishlist", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
+		r.Use(authz.RequirePermission(auth.PermWishlistWrite))
 
 		r.Post("/create-wishlist", WishlistHandler.CreateWishlist)
 		r.Get("/wishlist", WishlistHandler.GetAllWishlist)
@@ -160,51 +242,192 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	})
 
 	// User Handler
-	userHandler := user.NewHandler(validate, dbGenerated)
+	userHandler := user.NewHandler(validate, dbGenerated, ratelimit.NewLoginGuard(limiter, rateLimitConfig.Account, rateLimitConfig.Address))
 	r.Route("/my-user", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("student"))
 
-		r.Put("/profile/{id}", userHandler.UpdateUser)
+		r.With(authz.RequirePermission(auth.PermProfileWrite)).Put("/profile/{id}", userHandler.UpdateUser)
 		r.Get("/list-teacher", userHandler.GetAllUserByTeacher)
 	})
 
 	//route user
 	r.Route("/user", func(r chi.Router) {
-		r.Get("/all-user", userHandler.GetAllUser)
 		r.Get("/list-teacher", userHandler.GetAllUserByTeacher)
-		r.Get("/list-student", userHandler.GetAllUserByStudent)
-		r.Get("/user/{id}", userHandler.GetUserByID)
-		r.Put("/profile/{id}", userHandler.UpdateUser)
 
-		r.Post("/register", userHandler.Register)
-		r.Post("/sign-in", userHandler.Login)
+		r.With(authLimit).Post("/register", userHandler.Register)
+		r.With(authLimit).Post("/sign-in", userHandler.Login)
 
 		r.Route("/", func(r chi.Router) {
 			r.Use(auth.AuthMiddleware)
 
-			r.Post("/profile", userHandler.UpdateUser)
 			r.Get("/user-info", userHandler.UserInfo)
 			r.Post("/sign-out", userHandler.Logout)
+
+			r.Group(func(r chi.Router) {
+				r.Use(authz.RequirePermission(auth.PermProfileWrite))
+
+				r.Post("/profile", userHandler.UpdateUser)
+				r.Put("/profile/{id}", userHandler.UpdateUser)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(authz.RequirePermission(auth.PermUserReadAny))
+
+				r.Get("/all-user", userHandler.GetAllUser)
+				r.Get("/list-student", userHandler.GetAllUserByStudent)
+				r.Get("/user/{id}", userHandler.GetUserByID)
+			})
 		})
 	})
 
+	// Category Handler
+	CategoryHandler := categories.NewHandler(validate, dbGenerated, catalogCache, auditLog)
+	AnalyticsHandler := analytics.NewHandler(validate, dbGenerated)
+
+	ReportsHandler := reports.NewHandler(validate, dbGenerated, producer)
+	NotificationHandler := notifications.NewHandler(validate, dbGenerated, notificationInbox, auditLog)
+	jobRunner := jobs.NewRunner(dbGenerated, rdb)
+	SchedulerHandler := scheduler.NewHandler(dbGenerated, jobs.New(db, rdb, producer), jobRunner)
+	RolesHandler := roles.NewHandler(validate, db, authz, auditLog)
+	AuditLogHandler := auditlog.NewHandler(dbGenerated)
+	h.producer, h.runner, h.notifications = producer, jobRunner, NotificationHandler
+
 	r.Route("/admin", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("admin"))
 
-		r.Get("/all-user", userHandler.GetAllUser)
-		r.Get("/list-teacher", userHandler.GetAllUserByTeacher)
-		r.Get("/list-student", userHandler.GetAllUserByStudent)
-		r.Get("/list-payment", PaymentHandler.GetAllPayment)
-		r.Get("/list-subscription", SubscriptionHandler.GetAllSubscriptions)
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermUserReadAny))
+
+			r.Get("/all-user", userHandler.GetAllUser)
+			r.Get("/list-teacher", userHandler.GetAllUserByTeacher)
+			r.Get("/list-student", userHandler.GetAllUserByStudent)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermPaymentReadAny))
+
+			r.Get("/list-payment", PaymentHandler.GetAllPayment)
+			r.Get("/list-subscription", SubscriptionHandler.GetAllSubscriptions)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermPaymentWriteAny))
+
+			r.Put("/payment/{id}/paid", PaymentHandler.MarkPaymentPaid)
+			r.Post("/refund-transaction/{id}", RevenueHandler.RefundTransaction)
+		})
+
+		// teacher profiles
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermTeacherWriteAny))
+
+			r.Get("/list-teacher-profile", TeacherHandler.GetAllTeachers)
+			r.Get("/teacher-profile/{id}", TeacherHandler.GetTeacherByID)
+			r.Post("/create-teacher", TeacherHandler.CreateTeacher)
+			r.Put("/update-teacher/{id}", TeacherHandler.UpdateTeacher)
+			r.Delete("/delete-teacher/{id}", TeacherHandler.DeleteTeacher)
+		})
+
+		// soft deleted items
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermTrashManage))
+
+			r.Get("/list-deleted-course", CoursesHandler.GetDeletedCourses)
+			r.Put("/restore-course/{id}", CoursesHandler.RestoreCourse)
+			r.Delete("/purge-course/{id}", CoursesHandler.PurgeCourse)
+			r.Get("/list-deleted-course_video", coursesVideo.GetDeletedCourseVideos)
+			r.Put("/restore-course_video/{id}", coursesVideo.RestoreCourseVideo)
+			r.Delete("/purge-course_video/{id}", coursesVideo.PurgeCourseVideo)
+			r.Get("/list-deleted-category", CategoryHandler.GetDeletedCategories)
+			r.Put("/restore-category/{id}", CategoryHandler.RestoreCategory)
+			r.Delete("/purge-category/{id}", CategoryHandler.PurgeCategory)
+		})
+
+		// analytics
+		r.Route("/analytics", func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermAnalyticsRead))
+
+			r.Get("/sales", AnalyticsHandler.GetSales)
+			r.Get("/payments", AnalyticsHandler.GetPayments)
+			r.Get("/users", AnalyticsHandler.GetUsers)
+			r.Get("/enrollments", AnalyticsHandler.GetEnrollments)
+			r.Get("/funnel", AnalyticsHandler.GetFunnel)
+			r.Get("/cart-recovery", AnalyticsHandler.GetCartRecovery)
+		})
+
+		// spreadsheet exports
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermReportExport))
+
+			r.Get("/export/{resource}", ReportsHandler.Export)
+			r.Post("/export/{resource}", ReportsHandler.CreateExportJob)
+			r.Get("/list-export-job", ReportsHandler.GetExportJobs)
+			r.Get("/export-job/{id}", ReportsHandler.GetExportJob)
+		})
+
+		// notifications and their email and whatsapp delivery log
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermNotificationWriteAny))
+
+			r.Post("/create-notification", NotificationHandler.CreateNotification)
+			r.Get("/list-notification-delivery", NotificationHandler.GetDeliveries)
+			r.Get("/notification-delivery/{id}", NotificationHandler.GetDelivery)
+		})
+
+		// who changed what
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermAuditRead))
+
+			r.Get("/list-audit-log", AuditLogHandler.GetAuditLogs)
+			r.Get("/audit-log/{id}", AuditLogHandler.GetAuditLog)
+		})
+
+		// background jobs
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermJobRun))
+
+			r.Get("/list-job", SchedulerHandler.GetJobs)
+			r.Get("/job/{name}/runs", SchedulerHandler.GetJobRuns)
+			r.Post("/job/{name}/run", SchedulerHandler.RunJob)
+		})
+
+		// payouts
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermRevenueManage))
+
+			r.Get("/list-payout-batch", RevenueHandler.GetPayoutBatches)
+			r.Get("/payout-batch/{id}", RevenueHandler.GetPayoutBatch)
+			r.Post("/create-payout-batch", RevenueHandler.CreatePayoutBatch)
+			r.Put("/payout-batch/{id}/paid", RevenueHandler.MarkPayoutBatchPaid)
+			r.Get("/ledger", RevenueHandler.GetTrialBalance)
+		})
+
+		// course review
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermCourseReview))
+
+			r.Get("/list-review-course", CoursesHandler.GetReviewQueue)
+			r.Post("/approve-course/{id}", CoursesHandler.ApproveCourse)
+			r.Post("/reject-course/{id}", CoursesHandler.RejectCourse)
+		})
+
+		// roles and their permissions
+		r.Group(func(r chi.Router) {
+			r.Use(authz.RequirePermission(auth.PermRoleManage))
+
+			r.Get("/list-permission", RolesHandler.GetPermissions)
+			r.Get("/list-role", RolesHandler.GetRoles)
+			r.Post("/create-role", RolesHandler.CreateRole)
+			r.Put("/update-role/{role}", RolesHandler.UpdateRole)
+			r.Delete("/delete-role/{role}", RolesHandler.DeleteRole)
+			r.Put("/user/{id}/role", RolesHandler.AssignRole)
+		})
 	})
-	// Category Handler
-	CategoryHandler := categories.NewHandler(validate, dbGenerated)
+
 	// Routes for categories
 	r.Route("/category", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Use(auth.RequireRole("teacher", "admin")) // Middleware to require 'teacher' role
+		r.Use(authz.RequirePermission(auth.PermCategoryWrite))
 
 		r.Post("/create-category", CategoryHandler.CreateCategory)
 		r.Put("/update-category/{id}", CategoryHandler.UpdateCategory)
@@ -212,7 +435,7 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 	})
 
 	workingDir, _ := os.Getwd()
-	fileServer := http.FileServer(http.Dir(path.Join(workingDir, "public")))
+	fileServer := http.FileServer(local.HideDotFiles(http.Dir(path.Join(workingDir, "public"))))
 	r.Route("/static", func(r chi.Router) {
 		r.Handle("/*", http.StripPrefix("/static/", fileServer))
 	})
@@ -229,8 +452,29 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 		r.Get("/popular", CoursesHandler.GetPopularCourses)
 		r.Get("/price", CoursesHandler.GetCoursePrice)
 		r.Get("/get-course/{course_id}", CoursesHandler.GetCourseByID)
+		r.Get("/course-rating/{course_id}", CoursesHandler.GetCourseRatings)
+		r.Get("/teachers", TeacherHandler.GetAllTeachers)
+		r.Get("/teachers/{id}", TeacherHandler.GetTeacherPage)
+	})
+
+	// inbox of the logged in user
+	r.Route("/notifications", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware)
+
+		r.Get("/", NotificationHandler.GetMyNotifications)
+		r.Get("/unread-count", NotificationHandler.GetUnreadCount)
+		r.Get("/preferences", NotificationHandler.GetPreferences)
+		r.Put("/preferences", NotificationHandler.UpdatePreferences)
+		r.Get("/stream", NotificationHandler.Stream)
+		r.Put("/read-all", NotificationHandler.MarkAllNotificationsRead)
+		r.Put("/{id}/read", NotificationHandler.MarkNotificationRead)
+		r.Delete("/{id}", NotificationHandler.DeleteNotification)
 	})
 
+	// signed links of marketing emails, no login needed
+	r.Get("/unsubscribe", NotificationHandler.Unsubscribe)
+	r.Post("/unsubscribe", NotificationHandler.Unsubscribe)
+
 	r.Route("/auth", func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
 
@@ -243,3 +487,22 @@ func New(db *sql.DB, rdb *redis.Client, q queue.Queuer, bucket buckets.Bucket, m
 func (h *Handler) Handler() http.Handler {
 	return h.router
 }
+
+// Draining fail the readiness probe so the load balancer stops sending traffic
+func (h *Handler) Draining() {
+	h.health.Shutdown()
+}
+
+// CloseStreams end the open notification streams, they would hold the shutdown until its timeout
+func (h *Handler) CloseStreams() {
+	h.notifications.CloseStreams()
+}
+
+// Close wait for the jobs started from the admin api until ctx is done, then flush the queue producer
+func (h *Handler) Close(ctx context.Context) error {
+	err := h.runner.Wait(ctx)
+	if h.producer != nil {
+		h.producer.Stop()
+	}
+	return err
+}
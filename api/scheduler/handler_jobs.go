@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/jobs"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// GetJobs list the registered jobs with the status of their last run
+func (h *Handler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	latest, err := h.db.GetLatestJobRuns(r.Context())
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching job runs")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	lastRuns := map[string]JobRun{}
+	for _, d := range latest {
+		lastRuns[d.JobName] = toJobRun(d)
+	}
+
+	res := []Job{}
+	for _, job := range h.jobs.List() {
+		j := Job{Name: job.Name, Description: job.Description, Schedule: job.Schedule}
+		if run, ok := lastRuns[job.Name]; ok {
+			j.LastRun = &run
+		}
+		res = append(res, j)
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// GetJobRuns return the latest runs of a job
+func (h *Handler) GetJobRuns(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Find(chi.URLParam(r, "name"))
+	if !ok {
+		apperr.WriteError(w, r, apperr.NotFound("Job not found"))
+		return
+	}
+
+	data, err := h.db.GetJobRuns(r.Context(), job.Name)
+	if err != nil {
+		logger.Err(r.Context(), err).Msg("error fetching job runs")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := []JobRun{}
+	for _, d := range data {
+		res = append(res, toJobRun(d))
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+// RunJob start a job in the background, the run can be followed with GetJobRuns
+func (h *Handler) RunJob(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(int32)
+
+	job, ok := h.jobs.Find(chi.URLParam(r, "name"))
+	if !ok {
+		apperr.WriteError(w, r, apperr.NotFound("Job not found"))
+		return
+	}
+
+	run, err := h.runner.Start(r.Context(), job, constant.JobTriggerManual, userID)
+	if err == jobs.ErrRunning {
+		apperr.WriteError(w, r, apperr.Conflict("Job is already running"))
+		return
+	} else if err != nil {
+		logger.Err(r.Context(), err).Msg("error starting job")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusAccepted, http.StatusAccepted, "Job started", toJobRun(run)).WriteResponse(w, r)
+}
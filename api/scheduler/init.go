@@ -0,0 +1,16 @@
+package scheduler
+
+import (
+	"github.com/online-bnsp/backend/jobs"
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+type Handler struct {
+	db     *repo.Queries
+	jobs   *jobs.Jobs
+	runner *jobs.Runner
+}
+
+func NewHandler(db *repo.Queries, jobs *jobs.Jobs, runner *jobs.Runner) *Handler {
+	return &Handler{db, jobs, runner}
+}
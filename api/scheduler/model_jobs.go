@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+type (
+	JobRun struct {
+		RunID       int32      `json:"run_id"`
+		JobName     string     `json:"job_name"`
+		Trigger     string     `json:"trigger"`
+		Status      string     `json:"status"`
+		Error       string     `json:"error,omitempty"`
+		TriggeredBy int32      `json:"triggered_by,omitempty"`
+		StartedAt   time.Time  `json:"started_at"`
+		FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	}
+
+	// Job is a registered job with its last run, LastRun is nil when it never ran
+	Job struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Schedule    string  `json:"schedule"` // cron expression, empty when only run manually
+		LastRun     *JobRun `json:"last_run"`
+	}
+)
+
+func toJobRun(d repo.JobRun) JobRun {
+	run := JobRun{
+		RunID:       d.RunID,
+		JobName:     d.JobName,
+		Trigger:     d.Trigger,
+		Status:      d.Status,
+		Error:       d.Error.String,
+		TriggeredBy: d.TriggeredBy.Int32,
+		StartedAt:   d.StartedAt,
+	}
+	if d.FinishedAt.Valid {
+		run.FinishedAt = &d.FinishedAt.Time
+	}
+	return run
+}
@@ -1,12 +1,16 @@
 package subscriptions
 
 import (
-	"log"
 	"net/http"
 	"time"
 
+	"fmt"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
@@ -16,85 +20,96 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	// Get user ID
 	userID := ctx.Value("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
 	// Get last payment ID
-	paymentID, err := h.db.GetLastPayment(ctx, util.SqlInt32(userIDInt))
+	payment, err := h.db.GetLastPayment(ctx, util.SqlInt32(userIDInt))
 	if err != nil {
-		http.Error(w, "cannot get last payment", http.StatusInternalServerError)
+		apperr.WriteError(w, r, apperr.Internal("cannot get last payment"))
 		return
 	}
 
 	// Get courses from cart
-	courses, err := h.db.GetCartByUserID(ctx, util.SqlInt32(userIDInt))
+	// the courses cost what they cost when the payment was made
+	courses, err := h.db.GetCartByUserID(ctx, repo.GetCartByUserIDParams{PricedAt: payment.PaymentDate.Time, UserID: util.SqlInt32(userIDInt)})
 	if err != nil {
-		http.Error(w, "cannot get courses", http.StatusInternalServerError)
+		apperr.WriteError(w, r, apperr.Internal("cannot get courses"))
 		return
 	}
 
 	now := time.Now()
 
-	for _, c := range courses {
-		// Insert to subscription
-		err = h.db.CreateSubscription(ctx, repo.CreateSubscriptionParams{
-			UserID:    util.SqlInt32(userIDInt),
-			CourseID:  util.SqlInt32(c.CourseID.Int32),
-			IsCorrect: "yes",
-			PaymentID: util.SqlInt32(paymentID),
-			CreatedAt: util.SqlTime(now),
-			UpdatedAt: util.SqlTime(now),
-		})
-
-		if err != nil {
-			log.Println("error storing subscription to db:", err)
-			resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-			resp.WriteResponse(w, r)
-			return
+	// the subscriptions, their transaction history and the emptied cart are committed together
+	err = h.audit.Tx(ctx, func(q *repo.Queries) error {
+		for _, c := range courses {
+			subscription, err := q.CreateSubscription(ctx, repo.CreateSubscriptionParams{
+				UserID:    util.SqlInt32(userIDInt),
+				CourseID:  util.SqlInt32(c.CourseID.Int32),
+				IsCorrect: "yes",
+				PaymentID: util.SqlInt32(payment.PaymentID),
+				CreatedAt: util.SqlTime(now),
+				UpdatedAt: util.SqlTime(now),
+			})
+			if err != nil {
+				return fmt.Errorf("store subscription: %w", err)
+			}
+
+			err = q.CreateTransactionHistory(ctx, repo.CreateTransactionHistoryParams{
+				SubscriptionID:        util.SqlInt32(subscription.SubscriptionID),
+				Quantity:              c.Quantity.Int32,
+				TotalAmount:           c.TotalAmount,
+				IsPaid:                "yes",
+				SubcriptionsStartDate: util.SqlTime(now),
+				Proof:                 util.SqlString("-"),
+				CreatedAt:             util.SqlTime(now),
+				UpdatedAt:             util.SqlTime(now),
+			})
+			if err != nil {
+				return fmt.Errorf("store transaction history: %w", err)
+			}
+
+			err = audit.Record(r, q, audit.Entry{
+				Action:     constant.AuditSubscriptionCreate,
+				Resource:   constant.AuditSubscription,
+				ResourceID: subscription.SubscriptionID,
+				After:      subscription,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
-		// Get the subscription id
-		subscriptionID, err := h.db.GetLastSubscription(ctx, util.SqlInt32(userIDInt))
-		if err != nil {
-			log.Println("error getting last subscription id:", err)
-			resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-			resp.WriteResponse(w, r)
-			return
-		}
-
-		// Insert to transaction history
-		err = h.db.CreateTransactionHistory(ctx, repo.CreateTransactionHistoryParams{
-			SubscriptionID:        util.SqlInt32(subscriptionID),
-			Quantity:              c.Quantity.Int32,
-			TotalAmount:           c.TotalAmount.Int32,
-			IsPaid:                "yes",
-			SubcriptionsStartDate: util.SqlTime(now),
-			Proof:                 util.SqlString("-"),
-			CreatedAt:             util.SqlTime(now),
-			UpdatedAt:             util.SqlTime(now),
+		// Empty the cart because we have processeed payment, rows are kept for conversion analytics
+		err := q.MarkCartPurchased(ctx, repo.MarkCartPurchasedParams{
+			UserID:      util.SqlInt32(userIDInt),
+			PurchasedAt: util.SqlTime(now),
 		})
 		if err != nil {
-			log.Println("error saving to transaction history:", err)
-			resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-			resp.WriteResponse(w, r)
-			return
+			return fmt.Errorf("empty cart: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error creating subscriptions")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
 	}
 
-	// Delete cart because we have processeed payment
-	err = h.db.DeleteCartByUserID(ctx, util.SqlInt32(userIDInt))
+	// A cart bought after a reminder counts as recovered
+	err = h.db.MarkCartRecovered(ctx, repo.MarkCartRecoveredParams{
+		UserID:      userIDInt,
+		RecoveredAt: util.SqlTime(now),
+	})
 	if err != nil {
-		log.Println("error deleting cart: ", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
-		return
+		logger.Err(ctx, err).Msg("error marking cart recovered")
 	}
 
 	resp.Status = http.StatusOK
@@ -106,8 +121,8 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetAllSubscriptions(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllSubscriptions(r.Context())
 	if err != nil {
-		log.Println("error fetching all subscriptions:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all subscriptions")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -0,0 +1,308 @@
+package teachers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// GetProfile return the profile of the logged in teacher, creating an empty one on first visit
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	teacher, err := h.ensureProfile(ctx, userID)
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Teacher profile"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toTeacher(teacher)).WriteResponse(w, r)
+}
+
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := ctx.Value("user_id").(int32)
+
+	// Parse form data
+	err := r.ParseMultipartForm(10 << 20) // 10MB limit
+	if err != nil {
+		logger.Warn(ctx, err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
+		return
+	}
+
+	current, err := h.ensureProfile(ctx, userID)
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "Teacher profile"))
+		return
+	}
+
+	req := ProfileRequest{
+		TeacherName: strings.TrimSpace(r.FormValue("teacher_name")),
+		Headline:    strings.TrimSpace(r.FormValue("headline")),
+		Bio:         strings.TrimSpace(r.FormValue("bio")),
+		Expertise:   []string{},
+		SocialLinks: map[string]string{},
+	}
+	if req.TeacherName == "" {
+		req.TeacherName = current.TeacherName
+	}
+
+	// expertise is sent as a comma separated list of tags
+	for _, tag := range strings.Split(r.FormValue("expertise"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			req.Expertise = append(req.Expertise, tag)
+		}
+	}
+
+	if links := r.FormValue("social_links"); links != "" {
+		if err := json.Unmarshal([]byte(links), &req.SocialLinks); err != nil {
+			logger.Warn(ctx, err).Msg("error parsing social links")
+			apperr.WriteError(w, r, apperr.Validation("Invalid social_links"))
+			return
+		}
+	}
+
+	// Validate request
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	// Keep the current avatar unless a new one is uploaded
+	avatar := current.Avatar
+	file, _, err := r.FormFile("avatar")
+	if err == nil {
+		defer file.Close()
+
+		// the name is generated, teachers uploading the same file name would overwrite each other
+		ext, err := avatarExtension(file)
+		if err != nil {
+			logger.Warn(ctx, err).Msg("error validating avatar")
+			apperr.WriteError(w, r, apperr.Validation("Avatar must be a png, jpeg, gif or webp image"))
+			return
+		}
+		filename := uuid.NewString() + ext
+
+		basePath, err := os.Getwd()
+		if err != nil {
+			logger.Err(ctx, err).Msg("error getting current working directory")
+			apperr.WriteError(w, r, apperr.Internal("Error getting working directory"))
+			return
+		}
+
+		publicPath := path.Join(basePath, "public", "teacher")
+		if err := os.MkdirAll(publicPath, 0755); err != nil {
+			logger.Err(ctx, err).Msg("error creating directory")
+			apperr.WriteError(w, r, apperr.Internal("Error creating file"))
+			return
+		}
+
+		dst, err := os.Create(path.Join(publicPath, filename))
+		if err != nil {
+			logger.Err(ctx, err).Msg("error creating file")
+			apperr.WriteError(w, r, apperr.Internal("Error creating file"))
+			return
+		}
+		defer dst.Close()
+
+		if _, err := io.Copy(dst, file); err != nil {
+			logger.Err(ctx, err).Msg("error copying the file")
+			apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
+			return
+		}
+
+		avatar = util.SqlString(path.Join("static", "teacher", filename))
+	} else if err != http.ErrMissingFile {
+		logger.Warn(ctx, err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the avatar"))
+		return
+	}
+
+	socialLinks, err := json.Marshal(req.SocialLinks)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error encoding social links")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	var teacher repo.Teacher
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		teacher, err = q.UpdateTeacherProfile(ctx, repo.UpdateTeacherProfileParams{
+			UserID:      util.SqlInt32(userID),
+			TeacherName: req.TeacherName,
+			Headline:    sql.NullString{String: req.Headline, Valid: req.Headline != ""},
+			Bio:         sql.NullString{String: req.Bio, Valid: req.Bio != ""},
+			Expertise:   req.Expertise,
+			SocialLinks: socialLinks,
+			Avatar:      avatar,
+			UpdatedAt:   util.SqlTime(time.Now()),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditTeacherUpdate,
+			Resource:   constant.AuditTeacher,
+			ResourceID: teacher.TeacherID,
+			Before:     current,
+			After:      teacher,
+		}, nil
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error updating teacher profile")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
+		return
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "Profile updated successfully", toTeacher(teacher)).WriteResponse(w, r)
+}
+
+// GetTeacherPage is the public instructor page with the teacher published courses
+func (h *Handler) GetTeacherPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		logger.Warn(ctx, err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
+		return
+	}
+
+	teacher, err := h.db.GetTeacherByID(ctx, int32(id))
+	if err == sql.ErrNoRows {
+		apperr.WriteError(w, r, apperr.NotFound("Teacher not found"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching teacher by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	stats, err := h.db.GetTeacherStats(ctx, teacher.UserID)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching teacher stats")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	courses, err := h.db.GetTeacherPublishedCourses(ctx, repo.GetTeacherPublishedCoursesParams{Now: time.Now(), UserID: teacher.UserID})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error fetching teacher courses")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
+		return
+	}
+
+	res := TeacherPage{
+		Teacher:       toTeacher(teacher),
+		StudentCount:  stats.StudentCount,
+		ReviewCount:   stats.ReviewCount,
+		AverageRating: stats.AverageRating,
+		Courses:       []TeacherCourse{},
+	}
+	for _, c := range courses {
+		res.Courses = append(res.Courses, TeacherCourse{
+			CourseID:          c.CourseID,
+			CourseName:        c.CourseName,
+			CourseDescription: c.CourseDescription,
+			Price:             c.Price,
+			Thumbnail:         c.Thumbnail.String,
+			StudentCount:      c.StudentCount,
+			ReviewCount:       c.ReviewCount,
+			AverageRating:     c.AverageRating,
+		})
+	}
+
+	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+}
+
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// avatarExtension sniff the content of the upload, the content type and name sent by the
+// client are not trusted
+func avatarExtension(file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := avatarTypes[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported avatar type %s", contentType)
+	}
+	return ext, nil
+}
+
+// ensureProfile create the profile of the teacher on first visit, a profile deleted by an admin
+// is not brought back and the teacher is refused
+func (h *Handler) ensureProfile(ctx context.Context, userID int32) (repo.Teacher, error) {
+	err := h.db.EnsureTeacherProfile(ctx, repo.EnsureTeacherProfileParams{
+		UserID:    userID,
+		CreatedAt: util.SqlTime(time.Now()),
+	})
+	if err != nil {
+		return repo.Teacher{}, err
+	}
+
+	teacher, err := h.db.GetTeacherByUserID(ctx, util.SqlInt32(userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return teacher, apperr.Forbidden("Teacher profile is disabled").WithCode("profile_disabled").Wrap(err)
+	}
+	return teacher, err
+}
+
+func toTeacher(t repo.Teacher) Teacher {
+	res := Teacher{
+		TeacherID:   t.TeacherID,
+		UserID:      t.UserID.Int32,
+		TeacherName: t.TeacherName,
+		Headline:    t.Headline.String,
+		Bio:         t.Bio.String,
+		Expertise:   t.Expertise,
+		SocialLinks: map[string]string{},
+		Avatar:      t.Avatar.String,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+	if res.Expertise == nil {
+		res.Expertise = []string{}
+	}
+	if len(t.SocialLinks) > 0 {
+		if err := json.Unmarshal(t.SocialLinks, &res.SocialLinks); err != nil {
+			logger.Warn(context.Background(), err).Int32("teacher_id", t.TeacherID).Msg("error decoding social links")
+		}
+	}
+
+	return res
+}
@@ -3,13 +3,17 @@ package teachers
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/constant"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/audit"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateTeacher(w http.ResponseWriter, r *http.Request) {
@@ -19,29 +23,51 @@ func (h *Handler) CreateTeacher(w http.ResponseWriter, r *http.Request) {
 	var req TeacherRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp := util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validation request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
+		return
+	}
+
+	// Only teacher accounts can have a teacher profile
+	user, err := h.db.GetUserByID(ctx, req.UserID)
+	if err == sql.ErrNoRows || (err == nil && user.Role != constant.RoleTeacher) {
+		apperr.WriteError(w, r, apperr.Validation("User is not a teacher"))
+		return
+	} else if err != nil {
+		logger.Err(ctx, err).Msg("error fetching user by ID")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
 	// Save Teacher to database
-	err = h.db.CreateTeacher(ctx, repo.CreateTeacherParams{
-		TeacherName: req.TeacherName,
+	now := time.Now()
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		teacher, err := q.CreateTeacher(ctx, repo.CreateTeacherParams{
+			UserID:      util.SqlInt32(req.UserID),
+			TeacherName: req.TeacherName,
+			CreatedAt:   util.SqlTime(now),
+			UpdatedAt:   util.SqlTime(now),
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		return audit.Entry{
+			Action:     constant.AuditTeacherCreate,
+			Resource:   constant.AuditTeacher,
+			ResourceID: teacher.TeacherID,
+			After:      teacher,
+		}, nil
 	})
-
 	if err != nil {
-		log.Println("error storing teacher to db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		// a taken teacher profile is a conflict, anything else is logged as an internal error
+		apperr.WriteError(w, r, apperr.FromDB(err, "Teacher profile"))
 		return
 	}
 
@@ -54,18 +80,14 @@ func (h *Handler) CreateTeacher(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetAllTeachers(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllTeacher(r.Context())
 	if err != nil {
-		log.Println("error fetching all teachers:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all teachers")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
-	var res []Teacher
+	res := []Teacher{}
 	for _, t := range data {
-		res = append(res, Teacher{
-			TeacherID:   t.TeacherID,
-			UserID:      t.UserID.Int32,
-			TeacherName: t.TeacherName,
-		})
+		res = append(res, toTeacher(t))
 	}
 
 	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
@@ -76,28 +98,22 @@ func (h *Handler) GetTeacherByID(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseInt(vars, 10, 32)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
 	data, err := h.db.GetTeacherByID(r.Context(), int32(id))
 	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "Teacher not found", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.NotFound("Teacher not found"))
 		return
 	} else if err != nil {
-		log.Println("error fetching teacher by ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching teacher by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
-	res := Teacher{
-		TeacherID:   data.TeacherID,
-		UserID:      data.UserID.Int32,
-		TeacherName: data.TeacherName,
-	}
-
-	util.NewResponse(http.StatusOK, http.StatusOK, "", res).WriteResponse(w, r)
+	util.NewResponse(http.StatusOK, http.StatusOK, "", toTeacher(data)).WriteResponse(w, r)
 }
 
 func (h *Handler) UpdateTeacher(w http.ResponseWriter, r *http.Request) {
@@ -107,47 +123,63 @@ func (h *Handler) UpdateTeacher(w http.ResponseWriter, r *http.Request) {
 	// Get the teacher ID from the URL parameters
 	teacherID := chi.URLParam(r, "id")
 	if teacherID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Teacher ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Teacher ID is required"))
 		return
 	}
 
 	// Convert teacherID to int32
 	id, err := strconv.Atoi(teacherID)
 	if err != nil {
-		log.Println("invalid teacher ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid teacher ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid teacher ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid teacher ID"))
 		return
 	}
 
-	var req TeacherRequest
+	var req UpdateTeacherRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validation request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Update the teacher in the database
-	err = h.db.UpdateTeacher(ctx, repo.UpdateTeacherParams{
-		TeacherID:   int32(id),
-		TeacherName: req.TeacherName,
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		teacher, err := q.GetTeacherByID(ctx, int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		updated := teacher
+		updated.TeacherName, updated.UpdatedAt = req.TeacherName, util.SqlTime(time.Now())
+
+		affected, err := q.UpdateTeacher(ctx, repo.UpdateTeacherParams{
+			TeacherID:   teacher.TeacherID,
+			TeacherName: updated.TeacherName,
+			UpdatedAt:   updated.UpdatedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditTeacherUpdate,
+			Resource:   constant.AuditTeacher,
+			ResourceID: teacher.TeacherID,
+			Before:     teacher,
+			After:      updated,
+		}, nil
 	})
-
 	if err != nil {
-		log.Println("error updating teacher in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Teacher"))
 		return
 	}
 
@@ -164,26 +196,47 @@ func (h *Handler) DeleteTeacher(w http.ResponseWriter, r *http.Request) {
 	// Get the teacher ID from the URL parameters
 	teacherID := chi.URLParam(r, "id")
 	if teacherID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Teacher ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Teacher ID is required"))
 		return
 	}
 
 	// Convert teacherID to int32
 	id, err := strconv.Atoi(teacherID)
 	if err != nil {
-		log.Println("invalid teacher ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid teacher ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid teacher ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid teacher ID"))
 		return
 	}
 
-	// Delete the teacher from the database
-	err = h.db.DeleteTeacher(ctx, int32(id))
+	// Soft delete the teacher profile, the public page disappears but courses stay
+	err = h.audit.Do(r, func(q *repo.Queries) (audit.Entry, error) {
+		teacher, err := q.GetTeacherByID(ctx, int32(id))
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		deleted := teacher
+		deleted.DeletedAt = util.SqlTime(time.Now())
+
+		affected, err := q.DeleteTeacher(ctx, repo.DeleteTeacherParams{
+			TeacherID: teacher.TeacherID,
+			DeletedAt: deleted.DeletedAt,
+		})
+		if err != nil {
+			return audit.Entry{}, err
+		}
+		if affected == 0 {
+			return audit.Entry{}, sql.ErrNoRows
+		}
+		return audit.Entry{
+			Action:     constant.AuditTeacherDelete,
+			Resource:   constant.AuditTeacher,
+			ResourceID: teacher.TeacherID,
+			Before:     teacher,
+			After:      deleted,
+		}, nil
+	})
 	if err != nil {
-		log.Println("error deleting teacher from db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Teacher"))
 		return
 	}
 
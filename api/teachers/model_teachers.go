@@ -1,22 +1,61 @@
 package teachers
 
 import (
-	"time"
+	"database/sql"
 )
 
 type (
 	// Model Teacher yang sesuai dengan tabel teachers
 	Teacher struct {
-		TeacherID   int32     `json:"teacher_id"` // Menggunakan int32 untuk mencocokkan tipe SERIAL
-		UserID      int32     `json:"user_id"`
-		TeacherName string    `json:"teachername`
-		CreatedAt   time.Time `json:"created_at"`
-		DeletedAt   time.Time `json:"deleted_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		TeacherID   int32             `json:"teacher_id"` // Menggunakan int32 untuk mencocokkan tipe SERIAL
+		UserID      int32             `json:"user_id"`
+		TeacherName string            `json:"teacher_name"`
+		Headline    string            `json:"headline"`
+		Bio         string            `json:"bio"`
+		Expertise   []string          `json:"expertise"`
+		SocialLinks map[string]string `json:"social_links"` // e.g. {"linkedin": "https://..."}
+		Avatar      string            `json:"avatar"`
+		CreatedAt   sql.NullTime      `json:"created_at"`
+		UpdatedAt   sql.NullTime      `json:"updated_at"`
 	}
 
 	// Model TeacherRequest untuk request input
 	TeacherRequest struct {
-		TeacherName string `json:"Nama" validate:"required"`
+		UserID      int32  `json:"user_id" validate:"required"`
+		TeacherName string `json:"teacher_name" validate:"required"`
+	}
+
+	UpdateTeacherRequest struct {
+		TeacherName string `json:"teacher_name" validate:"required"`
+	}
+
+	// ProfileRequest is filled from the multipart form a teacher sends to update their profile
+	ProfileRequest struct {
+		TeacherName string            `validate:"required,max=255"`
+		Headline    string            `validate:"max=255"`
+		Bio         string            `validate:"max=5000"`
+		Expertise   []string          `validate:"max=20,dive,required,max=50"`
+		SocialLinks map[string]string `validate:"max=10,dive,keys,required,max=30,endkeys,url"`
+	}
+
+	// TeacherCourse is a published course shown on the public teacher page
+	TeacherCourse struct {
+		CourseID          int32   `json:"course_id"`
+		CourseName        string  `json:"course_name"`
+		CourseDescription string  `json:"course_description"`
+		Price             int32   `json:"price"`
+		Thumbnail         string  `json:"thumbnail"`
+		StudentCount      int64   `json:"student_count"`
+		ReviewCount       int64   `json:"review_count"`
+		AverageRating     float64 `json:"average_rating"`
+	}
+
+	// TeacherPage is the public instructor page
+	TeacherPage struct {
+		Teacher
+		StudentCount  int64           `json:"student_count"`
+		ReviewCount   int64           `json:"review_count"`
+		AverageRating float64         `json:"average_rating"`
+		Courses       []TeacherCourse `json:"courses"`
 	}
 )
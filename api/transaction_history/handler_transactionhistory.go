@@ -2,13 +2,14 @@ package transactionhistory
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateTransactionHistory(w http.ResponseWriter, r *http.Request) {
@@ -18,17 +19,15 @@ func (h *Handler) CreateTransactionHistory(w http.ResponseWriter, r *http.Reques
 	var req TransactionHistoryRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
@@ -53,9 +52,8 @@ func (h *Handler) CreateTransactionHistory(w http.ResponseWriter, r *http.Reques
 	})
 
 	if err != nil {
-		log.Println("error storing transaction history to db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error storing transaction history to db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
@@ -68,8 +66,8 @@ func (h *Handler) CreateTransactionHistory(w http.ResponseWriter, r *http.Reques
 func (h *Handler) GetAllTransactionHistory(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllTransactionHistory(r.Context())
 	if err != nil {
-		log.Println("error fetching all transaction history:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all transaction history")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
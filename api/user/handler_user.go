@@ -5,20 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path"
 	"strconv"
-	"strings"
 	"time"
 
+	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+	"github.com/online-bnsp/backend/middleware"
 	"github.com/online-bnsp/backend/middleware/auth"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/metrics"
+	"github.com/online-bnsp/backend/util/ratelimit"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -27,25 +31,23 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp := util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validation request:", err)
-		resp := util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error validation request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("error hashing password: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error hashing password", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error hashing password")
+		apperr.WriteError(w, r, apperr.Internal("Error hashing password"))
 		return
 	}
 
@@ -58,40 +60,34 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		// Handle duplicate key error
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			log.Printf("duplicate key error: %v", err)
-			util.NewResponse(http.StatusConflict, http.StatusConflict, "Email already exists", struct{}{}).WriteResponse(w, r)
-			return
-		}
-
-		// Log and respond for other errors
-		log.Printf("error creating user: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating user", struct{}{}).WriteResponse(w, r)
+		// a taken email is a conflict, anything else is logged as an internal error
+		apperr.WriteError(w, r, fromCreateUser(err))
 		return
 	}
 
+	metrics.Registered(req.Role)
+
 	util.NewResponse(http.StatusOK, http.StatusOK, "User registered successfully", struct{}{}).WriteResponse(w, r)
 }
 
 func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	// Panggil metode yang mengeksekusi query GetCartByUserID
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
 	user, err := h.db.GetUserByID(r.Context(), userIDInt)
 	if err != nil {
-		log.Printf("error getting user info: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error getting user info", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error getting user info")
+		apperr.WriteError(w, r, apperr.Validation("Error getting user info"))
 		return
 	}
 
@@ -111,8 +107,8 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	err := r.ParseMultipartForm(10 << 20) // batasan ukuran file (10MB)
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
@@ -125,8 +121,8 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Ambil file photo dari form
 	file, handler, err := r.FormFile("photo")
 	if err != nil {
-		log.Printf("error retrieving the file: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error retrieving the file", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error retrieving the file")
+		apperr.WriteError(w, r, apperr.Validation("Error retrieving the file"))
 		return
 	}
 	defer file.Close()
@@ -139,36 +135,30 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	photoPath := path.Join(publicPath, handler.Filename)
 	dst, err := os.Create(photoPath)
 	if err != nil {
-		log.Printf("error saving the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error saving the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error saving the file")
+		apperr.WriteError(w, r, apperr.Internal("Error saving the file"))
 		return
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, file); err != nil {
-		log.Printf("error copying the file: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error copying the file")
+		apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 		return
 	}
 
 	// Validate request
-	validate := validator.New()
-	err = validate.Struct(req)
-	if err != nil {
-		var errMsg strings.Builder
-		for _, err := range err.(validator.ValidationErrors) {
-			errMsg.WriteString(err.Field() + " is invalid; ")
-		}
-		log.Printf("validation error: %v", errMsg.String())
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid input: "+errMsg.String(), struct{}{}).WriteResponse(w, r)
+	if err := h.validate.Struct(req); err != nil {
+		logger.Warn(r.Context(), err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("error hashing password: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error hashing password", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error hashing password")
+		apperr.WriteError(w, r, apperr.Internal("Error hashing password"))
 		return
 	}
 
@@ -182,16 +172,8 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		// Handle duplicate key error
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			log.Printf("duplicate key error: %v", err)
-			util.NewResponse(http.StatusConflict, http.StatusConflict, "Email already exists", struct{}{}).WriteResponse(w, r)
-			return
-		}
-
-		// Log and respond for other errors
-		log.Printf("error creating user: %v", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error creating user", struct{}{}).WriteResponse(w, r)
+		// a taken email is a conflict, anything else is logged as an internal error
+		apperr.WriteError(w, r, fromCreateUser(err))
 		return
 	}
 
@@ -207,8 +189,8 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetAllUser(w http.ResponseWriter, r *http.Request) {
 	data, err := h.db.GetAllUser(r.Context())
 	if err != nil {
-		log.Println("error fetching all data item:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all data item")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -231,8 +213,8 @@ func (h *Handler) GetAllUserByStudent(w http.ResponseWriter, r *http.Request) {
 	// Mengambil semua user dengan role "student" dari database
 	data, err := h.db.GetAllUserByStudent(r.Context(), "student")
 	if err != nil {
-		log.Println("error fetching student data:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching student data")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -256,8 +238,8 @@ func (h *Handler) GetAllUserByTeacher(w http.ResponseWriter, r *http.Request) {
 	// Mengambil semua user dengan role "teacher" dari database
 	data, err := h.db.GetAllUserByTeacher(r.Context(), "teacher")
 	if err != nil {
-		log.Println("error fetching teacher data:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching teacher data")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -284,19 +266,15 @@ func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	// Parsing ID dari string ke int32
 	id, err := strconv.ParseInt(vars, 10, 32)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
 	// Mendapatkan data UserInfo dari database berdasarkan ID
 	data, err := h.db.GetUserByID(r.Context(), int32(id))
-	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Invalid ID", struct{}{}).WriteResponse(w, r)
-		return
-	} else if err != nil {
-		log.Println("error fetching user info by ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+	if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "User"))
 		return
 	}
 
@@ -317,14 +295,14 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	userID := r.Context().Value("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	// Panggil metode yang mengeksekusi query GetCartByUserID
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
@@ -333,14 +311,15 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	err := r.ParseMultipartForm(10 << 20) // batasan ukuran file (10MB)
 	if err != nil {
-		log.Printf("error parsing form data: %v", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing form data", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing form data")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing form data"))
 		return
 	}
 
 	// Ambil data dari form
 	req.Nama = r.FormValue("nama")
 	req.Email = r.FormValue("email")
+	req.Phone = r.FormValue("phone")
 
 	// Ambil file photo dari form
 	file, handler, err := r.FormFile("photo")
@@ -356,15 +335,15 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		photoPath = path.Join(publicPath, handler.Filename)
 		dst, err := os.Create(photoPath)
 		if err != nil {
-			log.Printf("error saving the file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error saving the file", struct{}{}).WriteResponse(w, r)
+			logger.Err(ctx, err).Msg("error saving the file")
+			apperr.WriteError(w, r, apperr.Internal("Error saving the file"))
 			return
 		}
 		defer dst.Close()
 
 		if _, err := io.Copy(dst, file); err != nil {
-			log.Printf("error copying the file: %v", err)
-			util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Error copying the file", struct{}{}).WriteResponse(w, r)
+			logger.Err(ctx, err).Msg("error copying the file")
+			apperr.WriteError(w, r, apperr.Internal("Error copying the file"))
 			return
 		}
 
@@ -377,12 +356,11 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Nama:   req.Nama,
 		Email:  req.Email,
 		Photo:  util.SqlString(photoPath),
+		Phone:  sql.NullString{String: req.Phone, Valid: req.Phone != ""},
 	})
 
 	if err != nil {
-		log.Println("error updating user in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.FromDB(err, "Email"))
 		return
 	}
 
@@ -400,26 +378,42 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
+		return
+	}
+
+	// accounts and addresses that keep failing are locked out for a while
+	ip := ratelimit.ClientIP(r)
+	if wait, err := h.guard.Locked(ctx, req.Nama, ip); err != nil {
+		logger.Warn(ctx, err).Msg("error checking login lockout")
+	} else if wait > 0 {
+		middleware.TooManyRequests(w, r, wait)
 		return
 	}
 
+	// an unknown user and a wrong password get the same answer
 	data, err := h.db.Login(ctx, req.Nama)
-	if err != nil {
-		log.Println("error no user:", err)
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "User atau password salah", struct{}{}).WriteResponse(w, r)
+	if err == sql.ErrNoRows {
+		logger.Warn(ctx, err).Msg("error no user")
+		h.loginFailed(w, r, req.Nama, ip)
+		return
+	} else if err != nil {
+		apperr.WriteError(w, r, apperr.FromDB(err, "User"))
 		return
 	}
 
-	bcrypt.CompareHashAndPassword([]byte(data.Password), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(data.Password), []byte(req.Password))
 	if err != nil {
-		log.Println("error no user:", err)
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "User atau password salah", struct{}{}).WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error wrong password")
+		h.loginFailed(w, r, req.Nama, ip)
 		return
 	}
 
+	if err := h.guard.Succeeded(ctx, req.Nama); err != nil {
+		logger.Warn(ctx, err).Msg("error resetting login failures")
+	}
+
 	// Determine role from user data
 	role := data.Role
 
@@ -439,8 +433,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(jwtKey)
 	if err != nil {
-		log.Println("error creating token:", err)
-		util.NewResponse(http.StatusNotFound, http.StatusNotFound, "Sesi tidak dapat dibuat untuk user", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Internal("Sesi tidak dapat dibuat untuk user").Wrap(err))
 		return
 	}
 
@@ -459,6 +452,17 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	resp.WriteResponse(w, r)
 }
 
+// loginFailed count the failure against the account and the address of the caller
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, account, ip string) {
+	wait, err := h.guard.Failed(r.Context(), account, ip)
+	if err != nil {
+		logger.Warn(r.Context(), err).Msg("error counting login failure")
+	} else if wait > 0 {
+		logger.FromContext(r.Context()).Warn().Str("account", account).Str("ip", ip).Dur("locked_for", wait).Msg("login locked out")
+	}
+	apperr.WriteError(w, r, apperr.Unauthorized("User atau password salah").WithCode("invalid_credentials"))
+}
+
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
@@ -482,11 +486,11 @@ func (h *Handler) ReadCookieAndVerifyToken(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		if err == http.ErrNoCookie {
 			// Jika cookie salah
-			http.Error(w, "Cookie salah", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Cookie salah"))
 			return
 		}
 		// Jika terjadi error lain saat membaca cookie
-		http.Error(w, "Gagal membaca cookie", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Gagal membaca cookie"))
 		return
 	}
 
@@ -503,15 +507,15 @@ func (h *Handler) ReadCookieAndVerifyToken(w http.ResponseWriter, r *http.Reques
 
 	if err != nil {
 		if err == jwt.ErrSignatureInvalid {
-			http.Error(w, "Token tidak valid", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Token tidak valid"))
 			return
 		}
-		http.Error(w, "Gagal memverifikasi token", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Gagal memverifikasi token"))
 		return
 	}
 
 	if !token.Valid {
-		http.Error(w, "Token tidak valid", http.StatusUnauthorized)
+		apperr.WriteError(w, r, apperr.Unauthorized("Token tidak valid"))
 		return
 	}
 
@@ -526,7 +530,7 @@ func (h *Handler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	role := ctx.Value("role")
 
 	if (userID == nil) || (role == nil) {
-		util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Harap login terlebih dahulu", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Unauthorized("Harap login terlebih dahulu"))
 		return
 	}
 
@@ -539,3 +543,15 @@ func (h *Handler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 
 	util.NewResponse(http.StatusOK, http.StatusOK, "OK", res).WriteResponse(w, r)
 }
+
+// fromCreateUser report a role missing from the roles table on the role field, other errors are
+// reported on the email like FromDB does
+func fromCreateUser(err error) *apperr.Error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "users_role_fkey" {
+		e := apperr.Validation("Invalid role").Wrap(err)
+		e.Fields = []apperr.FieldError{{Field: "role", Rule: "role", Message: "role does not exist"}}
+		return e
+	}
+	return apperr.FromDB(err, "Email")
+}
@@ -3,13 +3,15 @@ package user
 import (
 	"github.com/go-playground/validator/v10"
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/ratelimit"
 )
 
 type Handler struct {
 	validate *validator.Validate
 	db       *repo.Queries
+	guard    *ratelimit.LoginGuard
 }
 
-func NewHandler(validate *validator.Validate, db *repo.Queries) *Handler {
-	return &Handler{validate, db}
+func NewHandler(validate *validator.Validate, db *repo.Queries, guard *ratelimit.LoginGuard) *Handler {
+	return &Handler{validate, db, guard}
 }
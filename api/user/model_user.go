@@ -16,7 +16,7 @@ type (
 		Nama     string `json:"Nama" validate:"required"`
 		Email    string `json:"Email" validate:"required"`
 		Password string `json:"password" validate:"required"`
-		Role     string `json:"role" validate:"required"`
+		Role     string `json:"role" validate:"required,oneof=student teacher"`
 		Photo    string `json:"photo"`
 	}
 
@@ -24,6 +24,7 @@ type (
 		Nama  string `json:"Nama" validate:"required"`
 		Email string `json:"Email" validate:"required"`
 		Photo string `json:"photo"`
+		Phone string `json:"phone"` // whatsapp number, left unchanged when empty
 	}
 
 	LoginRequest struct {
@@ -3,13 +3,15 @@ package wishlist
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	repo "github.com/online-bnsp/backend/repo/generated"
 	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 func (h *Handler) CreateWishlist(w http.ResponseWriter, r *http.Request) {
@@ -19,17 +21,15 @@ func (h *Handler) CreateWishlist(w http.ResponseWriter, r *http.Request) {
 	var req WishlistRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
@@ -43,12 +43,13 @@ func (h *Handler) CreateWishlist(w http.ResponseWriter, r *http.Request) {
 			Int32: req.CourseID,
 			Valid: true, // Menandakan bahwa nilai ini valid
 		},
+		CreatedAt: util.SqlTime(time.Now()),
+		UpdatedAt: util.SqlTime(time.Now()),
 	})
 
 	if err != nil {
-		log.Println("error storing wishlist to db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error storing wishlist to db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
@@ -60,10 +61,10 @@ func (h *Handler) CreateWishlist(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) GetAllWishlist(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(int32)
-	data, err := h.db.GetAllWishlists(r.Context(), util.SqlInt32(userID))
+	data, err := h.db.GetAllWishlists(r.Context(), repo.GetAllWishlistsParams{Now: time.Now(), UserID: util.SqlInt32(userID)})
 	if err != nil {
-		log.Println("error fetching all wishlist items:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.Err(r.Context(), err).Msg("error fetching all wishlist items")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -74,7 +75,7 @@ func (h *Handler) GetAllWishlist(w http.ResponseWriter, r *http.Request) {
 			CourseID:    d.CourseID.Int32,
 			CourseName:  d.CourseName.String,
 			CoursePhoto: d.Thumbnail.String,
-			CoursePrice: d.Price.Int32,
+			CoursePrice: d.EffectivePrice,
 		})
 	}
 
@@ -85,18 +86,18 @@ func (h *Handler) GetWishlistByID(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	user_id, err := strconv.ParseInt(idParam, 10, 32)
 	if err != nil {
-		log.Println("error parsing ID:", err)
-		util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid ID format", struct{}{}).WriteResponse(w, r)
+		logger.Warn(r.Context(), err).Msg("error parsing ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid ID format"))
 		return
 	}
 
 	data, err := h.db.GetWishlistByID(r.Context(), sql.NullInt32{Int32: int32(user_id), Valid: true})
 	if err == sql.ErrNoRows {
-		util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Invalid ID", struct{}{}).WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Unauthorized("Invalid ID"))
 		return
 	} else if err != nil {
-		log.Println("error fetching wishlist by ID:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error fetching wishlist by ID")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
@@ -115,33 +116,29 @@ func (h *Handler) UpdateWishlist(w http.ResponseWriter, r *http.Request) {
 
 	wishlistID := chi.URLParam(r, "id")
 	if wishlistID == "" {
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Wishlist ID is required", struct{}{})
-		resp.WriteResponse(w, r)
+		apperr.WriteError(w, r, apperr.Validation("Wishlist ID is required"))
 		return
 	}
 
 	id, err := strconv.Atoi(wishlistID)
 	if err != nil {
-		log.Println("invalid wishlist ID:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid wishlist ID", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("invalid wishlist ID")
+		apperr.WriteError(w, r, apperr.Validation("Invalid wishlist ID"))
 		return
 	}
 
 	var req WishlistRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Println("error parsing request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Error parsing request", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error parsing request")
+		apperr.WriteError(w, r, apperr.Validation("Error parsing request"))
 		return
 	}
 
 	// Validate request
 	if err := h.validate.Struct(req); err != nil {
-		log.Println("error validating request:", err)
-		resp = util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, err.Error(), struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Warn(ctx, err).Msg("error validating request")
+		apperr.WriteError(w, r, apperr.FromValidation(err))
 		return
 	}
 
@@ -159,9 +156,8 @@ func (h *Handler) UpdateWishlist(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Println("error updating wishlist in db:", err)
-		resp = util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Try again later", struct{}{})
-		resp.WriteResponse(w, r)
+		logger.Err(ctx, err).Msg("error updating wishlist in db")
+		apperr.WriteError(w, r, apperr.Internal("Try again later"))
 		return
 	}
 
@@ -175,14 +171,14 @@ func (h *Handler) DeleteWishlist(w http.ResponseWriter, r *http.Request) {
 	// Ambil user_id dari r.Context().Value
 	userID := r.Context().Value("user_id")
 	if userID == nil {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("user_id is required"))
 		return
 	}
 
 	// Cek apakah userID bisa di-cast ke tipe int32
 	userIDInt, ok := userID.(int32)
 	if !ok {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid user_id"))
 		return
 	}
 
@@ -190,7 +186,7 @@ func (h *Handler) DeleteWishlist(w http.ResponseWriter, r *http.Request) {
 	courseID := chi.URLParam(r, "course_id")
 	courseIDInt, err := strconv.Atoi(courseID)
 	if err != nil {
-		http.Error(w, "Invalid course id", http.StatusBadRequest)
+		apperr.WriteError(w, r, apperr.Validation("Invalid course id"))
 		return
 	}
 
@@ -201,8 +197,8 @@ func (h *Handler) DeleteWishlist(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Println("error deleting wishlist item:", err)
-		util.NewResponse(http.StatusInternalServerError, http.StatusInternalServerError, "Internal server error", struct{}{}).WriteResponse(w, r)
+		logger.Err(r.Context(), err).Msg("error deleting wishlist item")
+		apperr.WriteError(w, r, apperr.Internal("Internal server error"))
 		return
 	}
 
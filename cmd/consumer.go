@@ -6,7 +6,9 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/online-bnsp/backend/constant"
 	"github.com/online-bnsp/backend/consumer"
+	queue "github.com/online-bnsp/backend/util/queue"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func init() {
@@ -19,17 +21,24 @@ func init() {
 				log.Fatal("init server error:", err)
 			}
 
-			handlers := consumer.New(db)
+			handlers := consumer.New(db, di.GetBucket(), di.GetMailer(), di.Whatsapp())
 			// register all consumers below
 			mbi.Register("Calculate Coin Views", constant.SampleConsumer, "cerita_kaos", handlers.SampleConsumer) // sample
+			mbi.Register("Export Report", constant.ExportReport, "kaos_export", handlers.ExportReport)
+			mbi.Register("Send Notification", constant.Notification, "kaos_notification", handlers.SendNotification)
+			mbi.Register("Notification Dead Letter", constant.Notification+queue.DeadLetterSuffix, "kaos_notification", handlers.NotificationDeadLetter)
 
 			// run all consumers
 			mbi.Run()
+			di.ServeMetrics(viper.GetString("consumer_metrics_addr"))
 
 			log.Println("Consumer server started")
 
 			// wait
 			mbi.Wait()
+			if err := di.Close(); err != nil {
+				log.Println("error shutting down:", err)
+			}
 		},
 	}
 	rootCmd.AddCommand(cmd)
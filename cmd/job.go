@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/jobs"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	queue "github.com/online-bnsp/backend/util/queue"
+	"github.com/online-bnsp/backend/util/tracing"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "job [name]",
+		Short: "Run a background job once, list the jobs without a name",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			registry, runner := initJobs()
+			if len(args) == 0 {
+				for _, job := range registry.List() {
+					fmt.Printf("%-16s %-14s %s\n", job.Name, job.Schedule, job.Description)
+				}
+				return
+			}
+
+			job, ok := registry.Find(args[0])
+			if !ok {
+				log.Fatalf("unknown job %q", args[0])
+			}
+			_, err := runner.Run(context.Background(), job, constant.JobTriggerManual, 0)
+			if err := di.Close(); err != nil {
+				log.Println("error shutting down:", err)
+			}
+			if err != nil {
+				log.Fatal(job.Name, " error: ", err)
+			}
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
+
+// initJobs build the job registry and the runner recording the runs
+func initJobs() (*jobs.Jobs, *jobs.Runner) {
+	db, err := di.GetDatabase()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rdb, err := di.GetRedis()
+	if err != nil {
+		log.Fatal(err)
+	}
+	q, err := di.GetQueue()
+	if err != nil {
+		log.Fatal(err)
+	}
+	producer, err := q.NewProducer(queue.NsqProducerArgs{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return jobs.New(db, rdb, producer), jobs.NewRunner(repo.New(tracing.WrapDB(db)), rdb)
+}
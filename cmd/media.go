@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/buckets"
+	"github.com/online-bnsp/backend/util/buckets/gc"
+	"github.com/online-bnsp/backend/util/buckets/local"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	var dryRun, quarantine bool
+	var grace time.Duration
+	var publicDir string
+	var keep []string
+
+	mediaCmd := &cobra.Command{
+		Use:   "media",
+		Short: "Media files maintenance",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete or quarantine media files no longer referenced by the database",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, err := di.GetDatabase()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			rows, err := repo.New(db).GetMediaReferences(context.Background())
+			if err != nil {
+				log.Fatal("unable to get media references: ", err)
+			}
+
+			refs := make([]string, 0, len(rows))
+			for _, r := range rows {
+				if r.Valid {
+					refs = append(refs, r.String)
+				}
+			}
+
+			if publicDir == "" {
+				wd, err := os.Getwd()
+				if err != nil {
+					log.Fatal(err)
+				}
+				publicDir = path.Join(wd, "public")
+			}
+
+			// files uploaded by the handlers directly into public/, served under /static
+			stores := []gc.Store{{Name: "public", Bucket: local.New(publicDir, viper.GetString("server_addr"), "/static", nil)}}
+			if b, ok := di.GetBucket().(buckets.Lister); ok {
+				stores = append(stores, gc.Store{Name: viper.GetString("bucket.provider"), Bucket: b})
+			}
+
+			report, err := gc.Collect(stores, refs, gc.Config{
+				Grace:      grace,
+				DryRun:     dryRun,
+				Quarantine: quarantine,
+				Keep:       keep,
+			})
+			if err != nil {
+				log.Fatal("unable to collect media: ", err)
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "STORE\tKEY\tSIZE\tMODIFIED\tACTION\tERROR")
+			for _, it := range report.Items {
+				errMsg := ""
+				if it.Err != nil {
+					errMsg = it.Err.Error()
+				}
+				fmt.Fprintf(tw, "%v\t%v\t%d\t%v\t%v\t%v\n", it.Store, it.Key, it.Size, it.ModTime.Format(time.RFC3339), it.Action, errMsg)
+			}
+			tw.Flush()
+
+			fmt.Printf("\nscanned: %d, referenced: %d, within grace period: %d, unreferenced: %d, size: %d bytes\n",
+				report.Scanned, report.Referenced, report.Young, len(report.Items), report.Freed())
+		},
+	}
+
+	gcCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report unreferenced files, do not touch them")
+	gcCmd.Flags().BoolVar(&quarantine, "quarantine", false, "Move unreferenced files to "+gc.QuarantinePrefix+" instead of deleting them")
+	gcCmd.Flags().DurationVar(&grace, "grace", 72*time.Hour, "Skip files modified within this period")
+	gcCmd.Flags().StringVar(&publicDir, "public-dir", "", "Directory served under /static (default ./public)")
+	gcCmd.Flags().StringSliceVar(&keep, "keep", []string{"default.png"}, "Keys that are never collected")
+
+	mediaCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(mediaCmd)
+}
@@ -25,6 +25,11 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		// the spans of each command are told apart by service, e.g. kaos-consumer
+		if err := di.InitTracing("kaos-" + cmd.Name()); err != nil {
+			log.Fatal(err)
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/online-bnsp/backend/jobs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "scheduler",
+		Short: "Start the scheduler running the recurring jobs",
+		Run: func(cmd *cobra.Command, args []string) {
+			registry, runner := initJobs()
+			rdb, err := di.GetRedis()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			leader := jobs.NewLeader(rdb, viper.GetDuration("scheduler.leader_ttl"))
+			scheduler, err := jobs.NewScheduler(registry, runner, leader)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			di.ServeMetrics(viper.GetString("scheduler.metrics_addr"))
+			log.Println("Scheduler started")
+			scheduler.Run(ctx)
+			if err := di.Close(); err != nil {
+				log.Println("error shutting down:", err)
+			}
+			log.Println("Scheduler stopped")
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
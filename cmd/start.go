@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func init() {
@@ -16,11 +24,39 @@ func init() {
 			if err != nil {
 				log.Fatal("init server error:", err)
 			}
-			log.Println("Starting server at", srv.Addr)
-			err = srv.ListenAndServe()
-			if err != nil {
-				log.Fatal("unable to start server:", err)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			serveErr := make(chan error, 1)
+			go func() {
+				log.Println("Starting server at", srv.Addr)
+				serveErr <- srv.ListenAndServe()
+			}()
+
+			select {
+			case err := <-serveErr:
+				if !errors.Is(err, http.ErrServerClosed) {
+					log.Fatal("unable to start server:", err)
+				}
+			case <-ctx.Done():
+			}
+			// a second signal kills the process right away
+			stop()
+
+			timeout := viper.GetDuration("shutdown_timeout")
+			if timeout <= 0 {
+				timeout = 30 * time.Second
+			}
+			log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := di.Shutdown(shutdownCtx); err != nil {
+				log.Println("error shutting down:", err)
+				return
 			}
+			log.Println("Server stopped")
 		},
 	}
 	rootCmd.AddCommand(cmd)
@@ -0,0 +1,68 @@
+package constant
+
+// resources of the audit log
+const (
+	AuditCourse        = "course"
+	AuditCourseVideo   = "course_video"
+	AuditCourseSale    = "course_sale"
+	AuditCourseRating  = "course_rating"
+	AuditCategory      = "category"
+	AuditTeacher       = "teacher"
+	AuditPayment       = "payment"
+	AuditPaymentMethod = "payment_method"
+	AuditPaymentStatus = "payment_status"
+	AuditSubscription  = "subscription"
+	AuditTransaction   = "transaction"
+	AuditPayoutBatch   = "payout_batch"
+	AuditNotification  = "notification"
+	AuditRole          = "role"
+	AuditUser          = "user"
+)
+
+// actions of the audit log, named <resource>.<verb>
+const (
+	AuditCourseCreate      = "course.create"
+	AuditCourseUpdate      = "course.update"
+	AuditCourseDelete      = "course.delete"
+	AuditCourseRestore     = "course.restore"
+	AuditCoursePurge       = "course.purge"
+	AuditCourseSubmit      = "course.submit"
+	AuditCourseApprove     = "course.approve"
+	AuditCourseReject      = "course.reject"
+	AuditCourseUnpublish   = "course.unpublish"
+	AuditCourseSaleCreate  = "course_sale.create"
+	AuditCourseSaleDelete  = "course_sale.delete"
+	AuditCourseRatingReply = "course_rating.reply"
+
+	AuditCourseVideoCreate  = "course_video.create"
+	AuditCourseVideoUpdate  = "course_video.update"
+	AuditCourseVideoDelete  = "course_video.delete"
+	AuditCourseVideoRestore = "course_video.restore"
+	AuditCourseVideoPurge   = "course_video.purge"
+
+	AuditCategoryCreate  = "category.create"
+	AuditCategoryUpdate  = "category.update"
+	AuditCategoryDelete  = "category.delete"
+	AuditCategoryRestore = "category.restore"
+	AuditCategoryPurge   = "category.purge"
+
+	AuditTeacherCreate = "teacher.create"
+	AuditTeacherUpdate = "teacher.update"
+	AuditTeacherDelete = "teacher.delete"
+
+	AuditPaymentCreate       = "payment.create"
+	AuditPaymentPaid         = "payment.paid"
+	AuditPaymentMethodCreate = "payment_method.create"
+	AuditPaymentStatusCreate = "payment_status.create"
+	AuditSubscriptionCreate  = "subscription.create"
+	AuditTransactionRefund   = "transaction.refund"
+	AuditPayoutBatchCreate   = "payout_batch.create"
+	AuditPayoutBatchPaid     = "payout_batch.paid"
+
+	AuditNotificationCreate = "notification.create"
+
+	AuditRoleCreate = "role.create"
+	AuditRoleUpdate = "role.update"
+	AuditRoleDelete = "role.delete"
+	AuditUserRole   = "user.role"
+)
@@ -3,4 +3,6 @@ package constant
 // topic consumer
 const (
 	SampleConsumer = "sample_consumer"
+	ExportReport   = "export_report"
+	Notification   = "notification"
 )
@@ -10,3 +10,73 @@ const (
 	ActivationCodeGenerated string = "GENERATED"
 	ActivationCodeInActive  string = "INACTIVE"
 )
+
+// course publishing workflow
+const (
+	CourseDraft       string = "DRAFT"
+	CourseInReview    string = "IN_REVIEW"
+	CoursePublished   string = "PUBLISHED"
+	CourseRejected    string = "REJECTED"
+	CourseUnpublished string = "UNPUBLISHED"
+)
+
+// transaction_history.is_paid
+const (
+	TransactionPaid     string = "yes"
+	TransactionRefunded string = "refunded"
+)
+
+const (
+	PayoutPending string = "PENDING"
+	PayoutPaid    string = "PAID"
+)
+
+// payment.payment_status_id, seeded by the 0014 migration
+const (
+	PaymentPending int32 = 1
+	PaymentPaid    int32 = 2
+	PaymentFailed  int32 = 3
+)
+
+// export_jobs.status
+const (
+	ExportPending string = "PENDING"
+	ExportRunning string = "RUNNING"
+	ExportDone    string = "DONE"
+	ExportFailed  string = "FAILED"
+)
+
+// notification_deliveries.status
+const (
+	DeliveryQueued   string = "QUEUED"
+	DeliverySent     string = "SENT"
+	DeliveryRetrying string = "RETRYING"
+	DeliveryDead     string = "DEAD"
+	DeliverySkipped  string = "SKIPPED" // turned off in the recipient preferences
+)
+
+// notification.type, the domain event that created the inbox entry
+const (
+	NotificationGeneral      string = "general"
+	NotificationPurchase     string = "purchase_confirmed"
+	NotificationNewLesson    string = "new_lesson"
+	NotificationReviewReply  string = "review_reply"
+	NotificationRefund       string = "refund_decision"
+	NotificationCompletion   string = "course_completion"
+	NotificationMarketing    string = "marketing"
+	NotificationPriceAlert   string = "price_alert"
+	NotificationCartReminder string = "cart_reminder"
+)
+
+// job_runs.status
+const (
+	JobRunning string = "RUNNING"
+	JobSuccess string = "SUCCESS"
+	JobFailed  string = "FAILED"
+)
+
+// job_runs.trigger
+const (
+	JobTriggerSchedule string = "schedule"
+	JobTriggerManual   string = "manual"
+)
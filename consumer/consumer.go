@@ -4,15 +4,24 @@ import (
 	"database/sql"
 
 	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/buckets"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/otpsender/whatsapp"
+	"github.com/online-bnsp/backend/util/preference"
+	"github.com/online-bnsp/backend/util/tracing"
 )
 
 type Handler struct {
-	db    *sql.DB
-	model *repo.Queries
+	db       *sql.DB
+	model    *repo.Queries
+	bucket   buckets.Bucket
+	mail     *mailer.Mailer
+	whatsapp *whatsapp.Client
+	prefs    *preference.Preferences
 }
 
-func New(db *sql.DB) *Handler {
-	dbGenerated := repo.New(db)
+func New(db *sql.DB, bucket buckets.Bucket, mail *mailer.Mailer, wa *whatsapp.Client) *Handler {
+	dbGenerated := repo.New(tracing.WrapDB(db))
 
-	return &Handler{db, dbGenerated}
+	return &Handler{db, dbGenerated, bucket, mail, wa, preference.New(dbGenerated)}
 }
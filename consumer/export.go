@@ -0,0 +1,104 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsqio/go-nsq"
+	"github.com/online-bnsp/backend/api/reports"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/export"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// ExportReport generate a queued export and upload it to the bucket,
+// the file is streamed to the bucket while it is written
+func (d *Handler) ExportReport(ctx context.Context, m *nsq.Message) error {
+	payload := reports.ExportMessage{}
+
+	err := json.Unmarshal(m.Body, &payload)
+	if err != nil {
+		logger.Err(ctx, err).Send()
+		return err
+	}
+
+	// a job that is already running or finished is not picked up twice
+	n, err := d.model.StartExportJob(ctx, repo.StartExportJobParams{JobID: payload.JobID, StartedAt: util.SqlTime(time.Now())})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	job, err := d.model.GetExportJobByID(ctx, payload.JobID)
+	if err != nil {
+		return err
+	}
+
+	rows, url, err := d.export(ctx, job)
+	if err != nil {
+		logger.Err(ctx, err).Int32("job_id", job.JobID).Msg("export job failed")
+		return d.model.FailExportJob(ctx, repo.FailExportJobParams{
+			JobID:      job.JobID,
+			Error:      util.SqlString(err.Error()),
+			FinishedAt: util.SqlTime(time.Now()),
+		})
+	}
+
+	return d.model.FinishExportJob(ctx, repo.FinishExportJobParams{
+		JobID:      job.JobID,
+		FileUrl:    util.SqlString(url),
+		RowCount:   rows,
+		FinishedAt: util.SqlTime(time.Now()),
+	})
+}
+
+func (d *Handler) export(ctx context.Context, job repo.ExportJob) (int64, string, error) {
+	var f reports.Filter
+	if err := json.Unmarshal(job.Filters, &f); err != nil {
+		return 0, "", err
+	}
+
+	pr, pw := io.Pipe()
+
+	type result struct {
+		rows int64
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		w, err := export.New(job.Format, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			done <- result{err: err}
+			return
+		}
+
+		rows, err := reports.Export(ctx, d.model, job.Resource, f, w)
+		pw.CloseWithError(err)
+		done <- result{rows, err}
+	}()
+
+	// the file name is not guessable, exported files contain personal data
+	filename := fmt.Sprintf("export-%s-%s.%s", job.Resource, uuid.NewString(), job.Format)
+	url, err := d.bucket.Upload(filename, pr)
+
+	// unblock the writer when the upload stopped reading early
+	pr.CloseWithError(err)
+	res := <-done
+
+	if res.err != nil {
+		return 0, "", res.err
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return res.rows, url, nil
+}
@@ -0,0 +1,196 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/notify"
+	"github.com/online-bnsp/backend/util/preference"
+	queue "github.com/online-bnsp/backend/util/queue"
+)
+
+// SendNotification deliver a queued email or whatsapp message, a returned error
+// requeues the message with backoff until it is moved to the dead-letter topic
+func (d *Handler) SendNotification(ctx context.Context, m *nsq.Message) error {
+	msg := notify.Message{}
+
+	err := json.Unmarshal(m.Body, &msg)
+	if err != nil {
+		logger.Err(ctx, err).Send()
+		return err
+	}
+
+	recipient := msg.To.Email
+	if msg.Channel == notify.ChannelWhatsapp {
+		recipient = msg.Phone
+	}
+
+	delivery, err := d.model.TrackDeliveryAttempt(ctx, repo.TrackDeliveryAttemptParams{
+		MessageID: msg.ID,
+		Channel:   msg.Channel,
+		Template:  sql.NullString{String: msg.Template, Valid: msg.Template != ""},
+		Recipient: recipient,
+		Attempts:  int32(m.Attempts),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// nsq delivers at least once, do not send twice
+	if delivery.Status == constant.DeliverySent || delivery.Status == constant.DeliverySkipped {
+		return nil
+	}
+
+	allowed, err := d.prefs.Allowed(ctx, msg.To.UserID, msg.Event, msg.Channel)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return d.model.MarkDeliverySkipped(ctx, repo.MarkDeliverySkippedParams{MessageID: msg.ID, UpdatedAt: time.Now()})
+	}
+
+	if e, ok := preference.Find(msg.Event); ok && e.Marketing && msg.To.UserID != 0 {
+		msg.To.Unsubscribe = preference.UnsubscribeURL(msg.To.UserID, msg.Event)
+	}
+
+	err = d.deliver(ctx, msg)
+	if err != nil {
+		logger.Warn(ctx, err).Str("notification_id", msg.ID).Msg("notification attempt failed")
+		if err := d.model.MarkDeliveryRetrying(ctx, repo.MarkDeliveryRetryingParams{
+			MessageID: msg.ID,
+			LastError: util.SqlString(err.Error()),
+			UpdatedAt: time.Now(),
+		}); err != nil {
+			logger.Err(ctx, err).Msg("error storing notification delivery")
+		}
+		return err
+	}
+
+	now := time.Now()
+	if err := d.model.MarkDeliverySent(ctx, repo.MarkDeliverySentParams{MessageID: msg.ID, SentAt: util.SqlTime(now)}); err != nil {
+		logger.Err(ctx, err).Msg("error storing notification delivery")
+	}
+
+	if msg.Template == mailer.TemplateReceipt {
+		receipt := mailer.ReceiptMail{}
+		json.Unmarshal(msg.Data, &receipt)
+		if err := d.model.SetInvoiceEmailed(ctx, repo.SetInvoiceEmailedParams{InvoiceNumber: receipt.InvoiceNumber, EmailedAt: util.SqlTime(now)}); err != nil {
+			logger.Err(ctx, err).Msg("error storing invoice email time")
+		}
+	}
+
+	return nil
+}
+
+// NotificationDeadLetter mark the deliveries that ran out of attempts
+func (d *Handler) NotificationDeadLetter(ctx context.Context, m *nsq.Message) error {
+	dead := queue.DeadLetter{}
+
+	err := json.Unmarshal(m.Body, &dead)
+	if err != nil {
+		logger.Err(ctx, err).Send()
+		return err
+	}
+
+	msg := notify.Message{}
+	if err := json.Unmarshal(dead.Body, &msg); err != nil || msg.ID == "" {
+		logger.Warn(ctx, err).Str("dead_message_id", dead.MessageID).Msg("dropping undecodable dead notification")
+		return nil
+	}
+
+	logger.FromContext(ctx).Error().
+		Str("notification_id", msg.ID).
+		Uint16("attempts", dead.Attempts).
+		Str("cause", dead.Error).
+		Msg("notification is dead")
+
+	return d.model.MarkDeliveryDead(ctx, repo.MarkDeliveryDeadParams{
+		MessageID: msg.ID,
+		LastError: util.SqlString(dead.Error),
+		UpdatedAt: dead.FailedAt,
+	})
+}
+
+func (d *Handler) deliver(ctx context.Context, msg notify.Message) error {
+	switch msg.Channel {
+	case notify.ChannelWhatsapp:
+		return d.whatsapp.Send(ctx, msg.Phone, msg.Text)
+
+	case notify.ChannelEmail:
+		return d.sendMail(msg)
+	}
+
+	return fmt.Errorf("unknown notification channel %q", msg.Channel)
+}
+
+// sendMail decode the data of the template into its typed mail
+func (d *Handler) sendMail(msg notify.Message) error {
+	switch msg.Template {
+	case mailer.TemplateWelcome:
+		return d.mail.SendWelcome(msg.To)
+
+	case mailer.TemplateVerification, mailer.TemplateResetCode:
+		code := mailer.CodeMail{}
+		if err := json.Unmarshal(msg.Data, &code); err != nil {
+			return err
+		}
+		if msg.Template == mailer.TemplateVerification {
+			return d.mail.SendVerification(msg.To, code.Code, code.ValidUntil)
+		}
+		return d.mail.SendResetCode(msg.To, code.Code, code.ValidUntil)
+
+	case mailer.TemplateReceipt:
+		receipt := mailer.ReceiptMail{}
+		if err := json.Unmarshal(msg.Data, &receipt); err != nil {
+			return err
+		}
+		return d.mail.SendReceipt(msg.To, receipt)
+
+	case mailer.TemplateRefund:
+		refund := mailer.RefundMail{}
+		if err := json.Unmarshal(msg.Data, &refund); err != nil {
+			return err
+		}
+		return d.mail.SendRefund(msg.To, refund)
+
+	case mailer.TemplateCourseCompletion:
+		completion := mailer.CourseCompletionMail{}
+		if err := json.Unmarshal(msg.Data, &completion); err != nil {
+			return err
+		}
+		return d.mail.SendCourseCompletion(msg.To, completion)
+
+	case mailer.TemplatePriceDrop:
+		drop := mailer.PriceDropMail{}
+		if err := json.Unmarshal(msg.Data, &drop); err != nil {
+			return err
+		}
+		return d.mail.SendPriceDrop(msg.To, drop)
+
+	case mailer.TemplatePriceDigest:
+		digest := mailer.PriceDigestMail{}
+		if err := json.Unmarshal(msg.Data, &digest); err != nil {
+			return err
+		}
+		return d.mail.SendPriceDigest(msg.To, digest)
+
+	case mailer.TemplateCartReminder:
+		reminder := mailer.CartReminderMail{}
+		if err := json.Unmarshal(msg.Data, &reminder); err != nil {
+			return err
+		}
+		return d.mail.SendCartReminder(msg.To, reminder)
+	}
+
+	return fmt.Errorf("unknown mail template %q", msg.Template)
+}
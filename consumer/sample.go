@@ -3,9 +3,9 @@ package consumer
 import (
 	"context"
 	"encoding/json"
-	"log"
 
 	"github.com/nsqio/go-nsq"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 // ConsumerPayload consumer payload
@@ -19,11 +19,11 @@ func (d *Handler) SampleConsumer(ctx context.Context, m *nsq.Message) error {
 
 	err := json.Unmarshal(m.Body, &payload)
 	if err != nil {
-		log.Println(err)
+		logger.Err(ctx, err).Send()
 		return err
 	}
 
-	log.Printf("%+v\n", payload)
+	logger.FromContext(ctx).Info().RawJSON("payload", logger.RedactJSON(m.Body)).Msg("sample message")
 
 	return nil
 }
@@ -1,7 +1,10 @@
 package dep
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -12,16 +15,25 @@ import (
 	// _ "github.com/go-sql-driver/mysql"
 
 	"github.com/online-bnsp/backend/api"
+	"github.com/online-bnsp/backend/api/payment"
+	"github.com/online-bnsp/backend/api/revenue"
+	"github.com/online-bnsp/backend/jobs"
 	"github.com/online-bnsp/backend/middleware/auth"
 	"github.com/online-bnsp/backend/util/buckets"
 	"github.com/online-bnsp/backend/util/buckets/discard"
 	"github.com/online-bnsp/backend/util/buckets/local"
 	s3b "github.com/online-bnsp/backend/util/buckets/s3"
+	"github.com/online-bnsp/backend/util/cartreminder"
 	"github.com/online-bnsp/backend/util/http/httpclient"
+	"github.com/online-bnsp/backend/util/ledger"
 	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/metrics"
 	"github.com/online-bnsp/backend/util/otpsender/whatsapp"
+	"github.com/online-bnsp/backend/util/preference"
 	queue "github.com/online-bnsp/backend/util/queue"
+	"github.com/online-bnsp/backend/util/ratelimit"
 	"github.com/online-bnsp/backend/util/s3"
+	"github.com/online-bnsp/backend/util/tracing"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
@@ -29,7 +41,28 @@ import (
 type DI struct {
 	db         *sql.DB
 	redis      *redis.Client
+	api        *api.Handler
 	apiHandler http.Handler
+	apiServer  *http.Server
+
+	// flushes the pending spans
+	tracingShutdown func(context.Context) error
+}
+
+func rateLimitPolicy(key string) ratelimit.Policy {
+	return ratelimit.Policy{
+		Limit:  viper.GetInt(key + ".limit"),
+		Window: viper.GetDuration(key + ".window"),
+	}
+}
+
+func lockoutPolicy(key string) ratelimit.LockoutPolicy {
+	return ratelimit.LockoutPolicy{
+		MaxFailures: viper.GetInt(key + ".max_failures"),
+		Window:      viper.GetDuration(key + ".window"),
+		Base:        viper.GetDuration(key + ".base"),
+		Max:         viper.GetDuration(key + ".max"),
+	}
 }
 
 func InitDI(configFile string) (*DI, error) {
@@ -41,12 +74,92 @@ func InitDI(configFile string) (*DI, error) {
 
 	di := &DI{}
 
+	// every log line goes through it, including the standard log package
+	logger.SetDefault(logger.New(logger.Config{
+		Level:  viper.GetString("logger_level"),
+		Output: viper.GetString("logger_output"),
+	}))
+
 	// JWT Secret
 	auth.SetJWTConfig(viper.GetString("jwt.secret"), viper.GetDuration("jwt.ttl"), viper.GetDuration("jwt.refresh_ttl"))
+	auth.SetPermissionConfig(viper.GetDuration("auth.permission_cache_ttl"))
+
+	// Teacher revenue, a platform share of 0 is valid so only override it when configured
+	platformShare := -1.0
+	if viper.IsSet("revenue.platform_share") {
+		platformShare = viper.GetFloat64("revenue.platform_share")
+	}
+	ledger.SetConfig(platformShare)
+	revenue.SetConfig(viper.GetDuration("revenue.payout_hold"), viper.GetInt64("revenue.min_payout"))
+
+	// Invoices
+	payment.SetInvoiceConfig(viper.GetFloat64("invoice.tax_rate"), viper.GetString("invoice.prefix"), viper.GetString("invoice.issuer"), viper.GetString("invoice.address"))
+
+	// Unsubscribe links, signed with the jwt secret unless a dedicated one is set
+	unsubscribeSecret := viper.GetString("notification.unsubscribe_secret")
+	if unsubscribeSecret == "" {
+		unsubscribeSecret = viper.GetString("jwt.secret")
+	}
+	preference.SetUnsubscribeConfig(unsubscribeSecret, viper.GetString("notification.unsubscribe_url"))
+
+	// Abandoned cart reminders, the links are signed like the unsubscribe links
+	cartreminder.SetConfig(viper.GetDuration("cart.reminder_idle"), viper.GetInt("cart.reminder_max"), viper.GetString("cart.return_url"), viper.GetString("cart.checkout_url"), unsubscribeSecret)
+
+	// Readiness probe
+	api.SetHealthConfig(viper.GetDuration("health_cache_ttl"), viper.GetDuration("health_timeout"))
+
+	// Public catalog cache
+	catalogCacheEnabled := true
+	if viper.IsSet("catalog_cache.enabled") {
+		catalogCacheEnabled = viper.GetBool("catalog_cache.enabled")
+	}
+	catalogCacheMaxAge := time.Duration(-1)
+	if viper.IsSet("catalog_cache.max_age") {
+		catalogCacheMaxAge = viper.GetDuration("catalog_cache.max_age")
+	}
+	api.SetCatalogCacheConfig(catalogCacheEnabled, viper.GetDuration("catalog_cache.ttl"), catalogCacheMaxAge)
+
+	// Forwarding headers of the proxies in front of the api, the client ip is rate limited and audited
+	if err = ratelimit.SetTrustedProxies(viper.GetStringSlice("trusted_proxies")); err != nil {
+		return nil, err
+	}
+
+	// Rate limits and login lockout
+	err = api.SetRateLimitConfig(api.RateLimitConfig{
+		Enabled:   viper.GetBool("rate_limit.enabled"),
+		Allowlist: viper.GetStringSlice("rate_limit.allowlist"),
+		Default:   rateLimitPolicy("rate_limit.default"),
+		Auth:      rateLimitPolicy("rate_limit.auth"),
+		Account:   lockoutPolicy("rate_limit.lockout.account"),
+		Address:   lockoutPolicy("rate_limit.lockout.address"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Background jobs
+	jobs.SetConfig(viper.GetStringMapString("scheduler.schedules"), viper.GetDuration("payment.pending_ttl"), viper.GetDuration("cart.purge_after"), viper.GetDuration("export.stale_after"))
 
 	return di, nil
 }
 
+// InitTracing install the tracer provider of the process, service names its spans
+func (di *DI) InitTracing(service string) error {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Service:     service,
+		Exporter:    viper.GetString("tracing.exporter"),
+		Endpoint:    viper.GetString("tracing.endpoint"),
+		Insecure:    viper.GetBool("tracing.insecure"),
+		SampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+	})
+	if err != nil {
+		return err
+	}
+
+	di.tracingShutdown = shutdown
+	return nil
+}
+
 func (di *DI) GetDatabase() (*sql.DB, error) {
 	if di.db == nil {
 		db, err := sql.Open(viper.GetString("dbdriver"), viper.GetString("dsn"))
@@ -59,6 +172,10 @@ func (di *DI) GetDatabase() (*sql.DB, error) {
 			return nil, err
 		}
 
+		if err := metrics.RegisterDB(db, "postgres"); err != nil {
+			log.Println("error registering database metrics:", err)
+		}
+
 		di.db = db
 	}
 	return di.db, nil
@@ -139,7 +256,8 @@ func (di *DI) GetAPIHandler() (http.Handler, error) {
 			AllowCredentials: true,
 			// MaxAge:           300, // Maximum value not ignored by any of major browsers
 		})
-		di.apiHandler = api.New(db, rdb, q, bucket, di.GetMailer(), corsHandler).Handler()
+		di.api = api.New(db, rdb, q, bucket, corsHandler)
+		di.apiHandler = di.api.Handler()
 
 		// bucket local server
 		if v, ok := bucket.(*local.Bucket); ok {
@@ -152,10 +270,7 @@ func (di *DI) GetAPIHandler() (http.Handler, error) {
 }
 
 func (di *DI) Whatsapp() *whatsapp.Client {
-	zlogger := logger.New(logger.Config{
-		Level:  viper.GetString("logger_level"),
-		Output: viper.GetString("logger_output"),
-	}).With().Logger()
+	zlogger := logger.FromContext(context.Background()).With().Logger()
 
 	// GLOBAL HTTP CLIENT
 	cfgHTTPClient := httpclient.Config{
@@ -166,7 +281,7 @@ func (di *DI) Whatsapp() *whatsapp.Client {
 		MaxIdleConn:       1000,
 	}
 	loggedHTTPTransport := httpclient.NewLoggedTransport(zlogger, cfgHTTPClient.NewTransport())
-	httpClient := httpclient.New(cfgHTTPClient, httpclient.WithHTTPTransport(loggedHTTPTransport))
+	httpClient := httpclient.New(cfgHTTPClient, httpclient.WithHTTPTransport(loggedHTTPTransport), httpclient.WithTracing())
 
 	return whatsapp.New(viper.GetString("whatsapp.url"), viper.GetString("whatsapp.basic_auth"), httpClient)
 }
@@ -177,9 +292,89 @@ func (di *DI) GetAPIServer() (*http.Server, error) {
 		return nil, err
 	}
 
+	// a zero timeout means no timeout
 	srv := http.Server{
-		Addr:    viper.GetString("server_addr"),
-		Handler: h,
+		Addr:              viper.GetString("server_addr"),
+		Handler:           h,
+		ReadTimeout:       viper.GetDuration("server_read_timeout"),
+		ReadHeaderTimeout: viper.GetDuration("server_read_header_timeout"),
+		WriteTimeout:      viper.GetDuration("server_write_timeout"),
+		IdleTimeout:       viper.GetDuration("server_idle_timeout"),
 	}
+	srv.RegisterOnShutdown(di.api.CloseStreams)
+
+	di.apiServer = &srv
 	return &srv, nil
 }
+
+// ServeMetrics expose /metrics on addr for the processes without the api server,
+// an empty addr disables it
+func (di *DI) ServeMetrics(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			log.Println("error serving metrics:", err)
+		}
+	}()
+}
+
+// Shutdown fail the readiness probe, stop accepting connections and wait for the in-flight requests and the
+// jobs started from the api until ctx is done, then flush the queue producer and
+// close the connections
+func (di *DI) Shutdown(ctx context.Context) error {
+	var errs []error
+	if di.api != nil {
+		di.api.Draining()
+
+		// keep serving until the load balancer saw the failing readiness probe
+		select {
+		case <-time.After(viper.GetDuration("shutdown_delay")):
+		case <-ctx.Done():
+		}
+	}
+	if di.apiServer != nil {
+		if err := di.apiServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("draining requests: %w", err))
+		}
+	}
+	if di.api != nil {
+		if err := di.api.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("waiting for jobs: %w", err))
+		}
+	}
+	if err := di.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close the database and redis connections and flush the pending spans
+func (di *DI) Close() error {
+	var errs []error
+	if di.tracingShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := di.tracingShutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flushing spans: %w", err))
+		}
+	}
+	if di.db != nil {
+		if err := di.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing database: %w", err))
+		}
+	}
+	if di.redis != nil {
+		if err := di.redis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing redis: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
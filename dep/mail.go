@@ -1,6 +1,8 @@
 package dep
 
 import (
+	"log"
+
 	"github.com/go-mail/mail"
 	"github.com/online-bnsp/backend/util/mailer"
 	"github.com/spf13/viper"
@@ -10,8 +12,23 @@ var mailerObj *mailer.Mailer
 
 func (di *DI) GetMailer() *mailer.Mailer {
 	if mailerObj == nil {
+		templates, err := mailer.LoadTemplates(viper.GetString("mail_template_dir"), viper.GetString("mail_locale"))
+		if err != nil {
+			// a broken override should not stop the server, fall back to the embedded templates
+			log.Println("error loading mail templates, using the default ones:", err)
+			templates, err = mailer.LoadTemplates("", viper.GetString("mail_locale"))
+			if err != nil {
+				log.Fatal("error loading default mail templates:", err)
+			}
+		}
+
+		appName := viper.GetString("app_name")
+		if appName == "" {
+			appName = "Online BNSP"
+		}
+
 		d := mail.NewDialer(viper.GetString("smtp.host"), viper.GetInt("smtp.port"), viper.GetString("smtp.user"), viper.GetString("smtp.pass"))
-		mailerObj = mailer.New(d, viper.GetString("mail_sender"))
+		mailerObj = mailer.New(d, viper.GetString("mail_sender"), appName, templates)
 	}
 	return mailerObj
 }
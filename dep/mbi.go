@@ -1,24 +1,28 @@
 package dep
 
 import (
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	queue "github.com/online-bnsp/backend/util/queue"
 	"github.com/spf13/viper"
 )
 
 type MBI struct {
-	q         queue.Queuer
-	config    nsqConsumerConfig
-	consumers []queue.Consumer
+	q          queue.Queuer
+	config     nsqConsumerConfig
+	consumers  []queue.Consumer
+	deadLetter queue.Producer
 }
 
 // nsqConsumerConfig consumer config
 type nsqConsumerConfig struct {
 	prefix, nsqLookUpds               string
 	maxInFlight, maxAttempts, workers int
+	backoff, maxBackoff               time.Duration
 }
 
 func InitMBI(configFile string) (*MBI, error) {
@@ -37,13 +41,21 @@ func InitMBI(configFile string) (*MBI, error) {
 		q: q,
 		config: nsqConsumerConfig{
 			maxInFlight: viper.GetInt("nsq_max_inflight"),
-			maxAttempts: viper.GetInt("nsq_delay_time"),
+			maxAttempts: viper.GetInt("nsq_max_attempts"),
+			backoff:     viper.GetDuration("nsq_backoff"),
+			maxBackoff:  viper.GetDuration("nsq_max_backoff"),
 			nsqLookUpds: viper.GetString("nsqlookupd"),
 			workers:     viper.GetInt("nsq_workers"),
 		},
 		consumers: make([]queue.Consumer, 0, 10),
 	}
 
+	// messages failing nsq_max_attempts times are moved to `<topic>_dead`
+	consumer.deadLetter, err = q.NewProducer(queue.NsqProducerArgs{Prefix: consumer.config.prefix})
+	if err != nil {
+		return nil, err
+	}
+
 	return consumer, nil
 }
 
@@ -59,8 +71,12 @@ func (mbi *MBI) Register(name, topic, channel string, handlerFn queue.ConsumerPa
 		NsqLookUpds: mbi.config.nsqLookUpds,
 		Workers:     mbi.config.workers,
 		HandlerFn:   handlerFn,
+		Backoff:     mbi.config.backoff,
+		MaxBackoff:  mbi.config.maxBackoff,
+		DeadLetter:  mbi.deadLetter,
 	})
 	if err != nil {
+		log.Printf("error registering consumer %s: %v\n", name, err)
 		return
 	}
 
@@ -88,5 +104,8 @@ func (mbi *MBI) Wait() {
 		for _, c := range mbi.consumers {
 			c.Stop()
 		}
+		if mbi.deadLetter != nil {
+			mbi.deadLetter.Stop()
+		}
 	}
 }
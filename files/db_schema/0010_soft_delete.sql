@@ -0,0 +1,5 @@
+ALTER TABLE categories ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_courses_deleted_at ON courses (deleted_at);
+CREATE INDEX idx_courses_video_deleted_at ON courses_video (deleted_at);
+CREATE INDEX idx_categories_deleted_at ON categories (deleted_at);
@@ -0,0 +1,19 @@
+-- courses created before the publishing workflow are already live
+ALTER TABLE courses ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'PUBLISHED';
+ALTER TABLE courses ALTER COLUMN status SET DEFAULT 'DRAFT';
+ALTER TABLE courses ADD COLUMN user_id INTEGER;
+
+CREATE INDEX idx_courses_status ON courses (status);
+CREATE INDEX idx_courses_user_id ON courses (user_id);
+
+CREATE TABLE course_publication_log (
+  log_id SERIAL PRIMARY KEY,
+  course_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  from_status VARCHAR(20) NOT NULL,
+  to_status VARCHAR(20) NOT NULL,
+  comment TEXT,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_course_publication_log_course_id ON course_publication_log (course_id);
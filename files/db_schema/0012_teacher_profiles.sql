@@ -0,0 +1,36 @@
+ALTER TABLE teachers ALTER COLUMN user_id DROP DEFAULT;
+ALTER TABLE teachers ADD COLUMN headline VARCHAR(255);
+ALTER TABLE teachers ADD COLUMN bio TEXT;
+ALTER TABLE teachers ADD COLUMN expertise TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE teachers ADD COLUMN social_links JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE teachers ADD COLUMN avatar TEXT;
+
+-- user_id was never unique, a user with several live profiles keeps the lowest teacher_id
+UPDATE teachers t SET deleted_at = NOW()
+WHERE t.deleted_at IS NULL
+AND EXISTS (
+  SELECT 1 FROM teachers o
+  WHERE o.user_id = t.user_id AND o.deleted_at IS NULL AND o.teacher_id < t.teacher_id
+);
+
+-- one live profile per user, the profiles deleted by an admin stay as they are
+CREATE UNIQUE INDEX idx_teachers_user_id ON teachers (user_id) WHERE deleted_at IS NULL;
+
+-- every teacher account without a profile gets one
+INSERT INTO teachers (user_id, teacher_name, created_at, updated_at)
+SELECT u.user_id, u.nama, NOW(), NOW()
+FROM users u
+WHERE u.role = 'teacher'
+AND NOT EXISTS (SELECT 1 FROM teachers t WHERE t.user_id = u.user_id)
+ON CONFLICT (user_id) WHERE deleted_at IS NULL DO NOTHING;
+
+CREATE TABLE course_ratings (
+  rating_id SERIAL PRIMARY KEY,
+  course_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+  review TEXT,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  UNIQUE (course_id, user_id)
+);
@@ -0,0 +1,46 @@
+-- double-entry ledger, every journal balances: SUM(debit) = SUM(credit)
+--
+-- sale:<transaction_history_id>   debit cash, credit teacher_payable and platform_revenue
+-- refund:<transaction_history_id> reverse of the sale journal
+-- payout:<payout_id>              debit teacher_payable, credit payout_clearing
+-- payout-paid:<payout_id>         debit payout_clearing, credit cash
+CREATE TABLE ledger_entries (
+  entry_id BIGSERIAL PRIMARY KEY,
+  journal_id VARCHAR(64) NOT NULL,
+  account VARCHAR(30) NOT NULL,
+  user_id INTEGER,
+  course_id INTEGER,
+  transaction_history_id INTEGER,
+  payout_id INTEGER,
+  debit BIGINT NOT NULL DEFAULT 0,
+  credit BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL,
+  CHECK (debit >= 0 AND credit >= 0 AND (debit = 0 OR credit = 0)),
+  UNIQUE (journal_id, account)
+);
+
+CREATE INDEX idx_ledger_entries_user_id ON ledger_entries (user_id, account);
+CREATE INDEX idx_ledger_entries_course_id ON ledger_entries (course_id, created_at);
+
+CREATE TABLE payout_batches (
+  batch_id SERIAL PRIMARY KEY,
+  status VARCHAR(20) NOT NULL,
+  cutoff_at TIMESTAMP NOT NULL,
+  total_amount BIGINT NOT NULL DEFAULT 0,
+  reference TEXT,
+  created_by INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  paid_by INTEGER,
+  paid_at TIMESTAMP
+);
+
+CREATE TABLE payouts (
+  payout_id SERIAL PRIMARY KEY,
+  batch_id INTEGER NOT NULL REFERENCES payout_batches (batch_id),
+  user_id INTEGER NOT NULL,
+  amount BIGINT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_payouts_batch_id ON payouts (batch_id);
+CREATE INDEX idx_payouts_user_id ON payouts (user_id);
@@ -0,0 +1,22 @@
+-- existing rows keep a NULL timestamp, they are older than any report
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT NOW();
+
+-- checkout keeps the cart rows so cart to purchase conversion can be measured
+ALTER TABLE cart ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE cart ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE cart ADD COLUMN purchased_at TIMESTAMP;
+
+INSERT INTO payment_status (payment_status_id, payment_status_name, created_at) VALUES
+  (1, 'pending', NOW()),
+  (2, 'paid', NOW()),
+  (3, 'failed', NOW())
+ON CONFLICT (payment_status_id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('payment_status', 'payment_status_id'), GREATEST((SELECT MAX(payment_status_id) FROM payment_status), 3));
+
+CREATE INDEX idx_users_created_at ON users (created_at);
+CREATE INDEX idx_cart_created_at ON cart (created_at);
+CREATE INDEX idx_subscriptions_created_at ON subscriptions (created_at);
+CREATE INDEX idx_transaction_history_created_at ON transaction_history (created_at);
+CREATE INDEX idx_payment_payment_date ON payment (payment_date);
+CREATE INDEX idx_wishlist_created_at ON wishlist (created_at);
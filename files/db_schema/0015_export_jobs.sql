@@ -0,0 +1,17 @@
+-- large admin exports are generated by the consumer and stored in the bucket
+CREATE TABLE export_jobs (
+  job_id SERIAL PRIMARY KEY,
+  resource VARCHAR(30) NOT NULL,
+  format VARCHAR(10) NOT NULL,
+  filters JSONB NOT NULL DEFAULT '{}',
+  status VARCHAR(20) NOT NULL,
+  file_url TEXT,
+  row_count BIGINT NOT NULL DEFAULT 0,
+  error TEXT,
+  requested_by INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  started_at TIMESTAMP,
+  finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_export_jobs_requested_by ON export_jobs (requested_by, created_at);
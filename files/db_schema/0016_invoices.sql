@@ -0,0 +1,38 @@
+-- one counter row per year, incremented in the transaction that issues the invoice
+-- so a rolled back invoice never consumes a number
+CREATE TABLE invoice_sequences (
+  year INTEGER PRIMARY KEY,
+  last_number INTEGER NOT NULL
+);
+
+CREATE TABLE invoices (
+  invoice_id SERIAL PRIMARY KEY,
+  invoice_number VARCHAR(30) NOT NULL UNIQUE,
+  payment_id INTEGER NOT NULL UNIQUE,
+  user_id INTEGER NOT NULL,
+  payment_method_name VARCHAR(255) NOT NULL,
+  subtotal BIGINT NOT NULL,
+  discount BIGINT NOT NULL,
+  tax BIGINT NOT NULL,
+  tax_rate_bp INTEGER NOT NULL,
+  total BIGINT NOT NULL,
+  file_url TEXT,
+  emailed_at TIMESTAMP,
+  issued_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_invoices_user_id ON invoices (user_id);
+
+-- line items are copied when the invoice is issued, later price changes do not alter it
+CREATE TABLE invoice_items (
+  invoice_item_id SERIAL PRIMARY KEY,
+  invoice_id INTEGER NOT NULL REFERENCES invoices (invoice_id),
+  course_id INTEGER,
+  course_name VARCHAR(255) NOT NULL,
+  unit_price BIGINT NOT NULL,
+  quantity INTEGER NOT NULL,
+  discount BIGINT NOT NULL,
+  amount BIGINT NOT NULL
+);
+
+CREATE INDEX idx_invoice_items_invoice_id ON invoice_items (invoice_id);
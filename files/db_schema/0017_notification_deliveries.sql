@@ -0,0 +1,16 @@
+-- every email and whatsapp message sent by the consumer, one row per queued message
+CREATE TABLE notification_deliveries (
+  delivery_id SERIAL PRIMARY KEY,
+  message_id VARCHAR(36) NOT NULL UNIQUE,
+  channel VARCHAR(20) NOT NULL,
+  template VARCHAR(50),
+  recipient VARCHAR(255) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  sent_at TIMESTAMP
+);
+
+CREATE INDEX idx_notification_deliveries_status ON notification_deliveries (status, created_at);
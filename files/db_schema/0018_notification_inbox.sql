@@ -0,0 +1,18 @@
+-- per-user inbox, rows are created by domain events and pushed live over redis
+ALTER TABLE notification ALTER COLUMN is_read DROP DEFAULT;
+ALTER TABLE notification ALTER COLUMN is_read TYPE BOOLEAN USING LOWER(is_read) IN ('true', 't', '1', 'yes');
+ALTER TABLE notification ALTER COLUMN is_read SET DEFAULT FALSE;
+ALTER TABLE notification ADD COLUMN type VARCHAR(30) NOT NULL DEFAULT 'general';
+ALTER TABLE notification ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE notification ADD COLUMN data JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE notification ADD COLUMN read_at TIMESTAMP;
+
+UPDATE notification SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE notification ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_notification_user ON notification (user_id, created_at DESC);
+CREATE INDEX idx_notification_unread ON notification (user_id) WHERE NOT is_read;
+
+-- teachers answer the reviews of their courses
+ALTER TABLE course_ratings ADD COLUMN reply TEXT;
+ALTER TABLE course_ratings ADD COLUMN replied_at TIMESTAMP;
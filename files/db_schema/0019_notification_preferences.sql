@@ -0,0 +1,9 @@
+-- only the choices a user made are stored, missing rows use the defaults of the user role
+CREATE TABLE notification_preferences (
+  user_id INTEGER NOT NULL,
+  event_type VARCHAR(30) NOT NULL,
+  channel VARCHAR(20) NOT NULL,
+  enabled BOOLEAN NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, event_type, channel)
+);
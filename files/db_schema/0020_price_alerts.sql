@@ -0,0 +1,52 @@
+-- discounted prices for a period, the lowest running sale is the effective price of a course
+CREATE TABLE course_sales (
+  sale_id SERIAL PRIMARY KEY,
+  course_id INTEGER NOT NULL,
+  sale_price INTEGER NOT NULL CHECK (sale_price >= 0),
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  created_by INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP,
+  CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_course_sales_course ON course_sales (course_id, starts_at);
+
+-- last effective price seen by the price tracker
+CREATE TABLE course_effective_prices (
+  course_id INTEGER PRIMARY KEY,
+  price INTEGER NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO course_effective_prices (course_id, price, updated_at)
+SELECT course_id, price, NOW() FROM courses WHERE deleted_at IS NULL;
+
+CREATE TABLE course_price_changes (
+  change_id SERIAL PRIMARY KEY,
+  course_id INTEGER NOT NULL,
+  old_price INTEGER NOT NULL,
+  new_price INTEGER NOT NULL,
+  sale_id INTEGER,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_course_price_changes_course ON course_price_changes (course_id, created_at);
+
+-- a user is alerted at most once per price change, digest alerts wait for the daily email
+CREATE TABLE wishlist_price_alerts (
+  alert_id SERIAL PRIMARY KEY,
+  change_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  course_id INTEGER NOT NULL,
+  digest BOOLEAN NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  sent_at TIMESTAMP,
+  UNIQUE (change_id, user_id)
+);
+
+CREATE INDEX idx_wishlist_price_alerts_digest ON wishlist_price_alerts (user_id) WHERE digest AND sent_at IS NULL;
+
+-- `instant` or `daily`, only used by events that support a digest
+ALTER TABLE notification_preferences ADD COLUMN frequency VARCHAR(10) NOT NULL DEFAULT 'instant';
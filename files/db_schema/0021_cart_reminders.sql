@@ -0,0 +1,24 @@
+-- last change of a cart row, carts untouched for a while get a reminder
+ALTER TABLE cart ADD COLUMN updated_at TIMESTAMP;
+UPDATE cart SET updated_at = created_at;
+
+CREATE INDEX idx_cart_open ON cart (user_id) WHERE purchased_at IS NULL;
+
+-- reminders are also sent over whatsapp to users with a phone number
+ALTER TABLE users ADD COLUMN phone VARCHAR(20);
+
+-- a cart is identified by its user and the time its oldest open row was added,
+-- it is recovered when bought after a reminder
+CREATE TABLE cart_reminders (
+  reminder_id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  cart_started_at TIMESTAMP NOT NULL,
+  items INTEGER NOT NULL,
+  total_amount INTEGER NOT NULL,
+  sent_at TIMESTAMP NOT NULL,
+  clicked_at TIMESTAMP,
+  recovered_at TIMESTAMP
+);
+
+CREATE INDEX idx_cart_reminders_user ON cart_reminders (user_id, sent_at);
+CREATE INDEX idx_cart_reminders_sent_at ON cart_reminders (sent_at);
@@ -0,0 +1,13 @@
+-- history of the scheduled and manually triggered background jobs
+CREATE TABLE job_runs (
+  run_id SERIAL PRIMARY KEY,
+  job_name VARCHAR(64) NOT NULL,
+  trigger VARCHAR(16) NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  error TEXT,
+  triggered_by INTEGER,
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_job_runs_job_name ON job_runs (job_name, started_at DESC);
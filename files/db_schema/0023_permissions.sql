@@ -0,0 +1,57 @@
+-- roles and the permissions they grant, users.role names one of them
+CREATE TABLE roles (
+  role VARCHAR(225) PRIMARY KEY, -- as long as users.role
+  description TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE role_permissions (
+  role VARCHAR(225) NOT NULL REFERENCES roles (role) ON DELETE CASCADE,
+  permission VARCHAR(64) NOT NULL,
+  PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (role, description) VALUES
+  ('student', 'Buys and studies courses'),
+  ('teacher', 'Publishes and sells own courses'),
+  ('admin', 'Runs the platform');
+
+-- the permissions match what the hard-coded roles were allowed before
+INSERT INTO role_permissions (role, permission) VALUES
+  ('student', 'profile:write'),
+  ('student', 'course:study'),
+  ('student', 'cart:write'),
+  ('student', 'wishlist:write'),
+  ('student', 'payment:create'),
+  ('student', 'payment:read'),
+  ('teacher', 'profile:write'),
+  ('teacher', 'course:write'),
+  ('teacher', 'video:write'),
+  ('teacher', 'category:write'),
+  ('teacher', 'teacher:profile'),
+  ('admin', 'profile:write'),
+  ('admin', 'course:write'),
+  ('admin', 'course:write:any'),
+  ('admin', 'course:review'),
+  ('admin', 'video:write'),
+  ('admin', 'video:write:any'),
+  ('admin', 'category:write'),
+  ('admin', 'trash:manage'),
+  ('admin', 'user:read:any'),
+  ('admin', 'teacher:write:any'),
+  ('admin', 'payment:read:any'),
+  ('admin', 'payment:write:any'),
+  ('admin', 'revenue:manage'),
+  ('admin', 'analytics:read'),
+  ('admin', 'report:export'),
+  ('admin', 'notification:write:any'),
+  ('admin', 'job:run'),
+  ('admin', 'role:manage');
+
+-- accounts registered with any other role keep it, without permissions
+INSERT INTO roles (role)
+SELECT DISTINCT role FROM users
+ON CONFLICT (role) DO NOTHING;
+
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles (role);
@@ -0,0 +1,31 @@
+-- who changed what, written in the transaction of the change
+CREATE TABLE audit_logs (
+  audit_id BIGSERIAL PRIMARY KEY,
+  actor_id INTEGER,
+  actor_role VARCHAR(225) NOT NULL DEFAULT '',
+  action VARCHAR(64) NOT NULL,
+  resource_type VARCHAR(64) NOT NULL,
+  resource_id VARCHAR(64) NOT NULL DEFAULT '',
+  before JSONB NOT NULL DEFAULT '{}',
+  after JSONB NOT NULL DEFAULT '{}',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  request_id VARCHAR(64) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at DESC);
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs (actor_id, created_at DESC);
+CREATE INDEX idx_audit_logs_resource ON audit_logs (resource_type, resource_id, created_at DESC);
+
+-- the log is append-only, entries can not be changed or removed
+CREATE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_logs
+FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'audit:read');
@@ -0,0 +1,10 @@
+-- the price a course is sold at, its lowest sale running at `at` or its own price,
+-- every query showing or charging a course price goes through it
+CREATE FUNCTION course_effective_price(course INTEGER, at TIMESTAMP) RETURNS INTEGER AS $$
+  SELECT LEAST(c.price, COALESCE(MIN(s.sale_price), c.price))
+  FROM courses c
+  LEFT JOIN course_sales s ON s.course_id = c.course_id AND s.deleted_at IS NULL
+    AND s.starts_at <= at AND s.ends_at > at
+  WHERE c.course_id = course
+  GROUP BY c.course_id, c.price
+$$ LANGUAGE sql STABLE;
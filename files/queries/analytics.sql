@@ -0,0 +1,148 @@
+-- name: GetSalesSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, th.created_at)::TIMESTAMP AS bucket,
+    COUNT(*) AS transactions,
+    COALESCE(SUM(th.total_amount), 0)::BIGINT AS gross_revenue,
+    COUNT(*) FILTER (WHERE th.is_paid = 'refunded') AS refunds,
+    COALESCE(SUM(th.total_amount) FILTER (WHERE th.is_paid = 'refunded'), 0)::BIGINT AS refunded_amount
+FROM transaction_history th
+WHERE th.is_paid IN ('yes', 'refunded')
+AND th.deleted_at IS NULL
+AND th.created_at >= sqlc.arg(from_date)
+AND th.created_at < sqlc.arg(to_date)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetPaymentStatusSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, p.payment_date)::TIMESTAMP AS bucket,
+    COALESCE(ps.payment_status_name, 'unknown')::TEXT AS status,
+    COUNT(*) AS payments,
+    COALESCE(SUM(p.total_amount), 0)::BIGINT AS amount
+FROM payment p
+LEFT JOIN payment_status ps ON ps.payment_status_id = p.payment_status_id
+WHERE p.payment_date >= sqlc.arg(from_date)
+AND p.payment_date < sqlc.arg(to_date)
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: GetNewUsersSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, u.created_at)::TIMESTAMP AS bucket,
+    u.role,
+    COUNT(*) AS users
+FROM "users" u
+WHERE u.created_at >= sqlc.arg(from_date)
+AND u.created_at < sqlc.arg(to_date)
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: GetActiveStudentsSeries :many
+-- a student is active in a bucket when they enrolled in or rated a course
+SELECT a.bucket::TIMESTAMP AS bucket, COUNT(DISTINCT a.user_id) AS students
+FROM (
+    SELECT date_trunc(sqlc.arg(bucket)::TEXT, s.created_at) AS bucket, s.user_id
+    FROM subscriptions s
+    WHERE s.created_at >= sqlc.arg(from_date) AND s.created_at < sqlc.arg(to_date)
+    UNION ALL
+    SELECT date_trunc(sqlc.arg(bucket)::TEXT, r.updated_at) AS bucket, r.user_id
+    FROM course_ratings r
+    WHERE r.updated_at >= sqlc.arg(from_date) AND r.updated_at < sqlc.arg(to_date)
+) a
+GROUP BY 1
+ORDER BY 1;
+
+-- name: CountActiveStudents :one
+SELECT COUNT(DISTINCT a.user_id) AS students
+FROM (
+    SELECT s.user_id FROM subscriptions s
+    WHERE s.created_at >= sqlc.arg(from_date) AND s.created_at < sqlc.arg(to_date)
+    UNION ALL
+    SELECT r.user_id FROM course_ratings r
+    WHERE r.updated_at >= sqlc.arg(from_date) AND r.updated_at < sqlc.arg(to_date)
+) a;
+
+-- name: GetEnrollmentSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, s.created_at)::TIMESTAMP AS bucket,
+    COUNT(*) AS enrollments
+FROM subscriptions s
+WHERE s.created_at >= sqlc.arg(from_date)
+AND s.created_at < sqlc.arg(to_date)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetEnrollmentsByCourse :many
+SELECT
+    c.course_id,
+    c.course_name,
+    COALESCE(cat.category_name, '')::TEXT AS category_name,
+    COUNT(*) AS enrollments
+FROM subscriptions s
+JOIN courses c ON c.course_id = s.course_id
+LEFT JOIN categories cat ON cat.category_id = c.category_id
+WHERE s.created_at >= sqlc.arg(from_date)
+AND s.created_at < sqlc.arg(to_date)
+GROUP BY c.course_id, c.course_name, cat.category_name
+ORDER BY enrollments DESC, c.course_id;
+
+-- name: GetEnrollmentsByCategory :many
+SELECT
+    COALESCE(cat.category_id, 0)::INTEGER AS category_id,
+    COALESCE(cat.category_name, 'uncategorized')::TEXT AS category_name,
+    COUNT(*) AS enrollments
+FROM subscriptions s
+JOIN courses c ON c.course_id = s.course_id
+LEFT JOIN categories cat ON cat.category_id = c.category_id
+WHERE s.created_at >= sqlc.arg(from_date)
+AND s.created_at < sqlc.arg(to_date)
+GROUP BY 1, 2
+ORDER BY enrollments DESC, 1;
+
+-- name: GetCartFunnelSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, cr.created_at)::TIMESTAMP AS bucket,
+    COUNT(*) AS added,
+    COUNT(*) FILTER (WHERE cr.purchased_at IS NOT NULL) AS purchased
+FROM cart cr
+WHERE cr.created_at >= sqlc.arg(from_date)
+AND cr.created_at < sqlc.arg(to_date)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetWishlistFunnelSeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, w.created_at)::TIMESTAMP AS bucket,
+    COUNT(*) AS added,
+    COUNT(*) FILTER (WHERE EXISTS (
+        SELECT 1 FROM subscriptions s
+        WHERE s.user_id = w.user_id AND s.course_id = w.course_id AND s.created_at >= w.created_at
+    )) AS purchased
+FROM wishlist w
+WHERE w.created_at >= sqlc.arg(from_date)
+AND w.created_at < sqlc.arg(to_date)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetCartRecoveryTotals :one
+SELECT
+    COUNT(*) AS reminders,
+    COUNT(*) FILTER (WHERE r.clicked_at IS NOT NULL) AS clicked,
+    COUNT(DISTINCT (r.user_id, r.cart_started_at)) AS carts,
+    COUNT(DISTINCT (r.user_id, r.cart_started_at)) FILTER (WHERE r.recovered_at IS NOT NULL) AS recovered
+FROM cart_reminders r
+WHERE r.sent_at >= sqlc.arg(from_date)
+AND r.sent_at < sqlc.arg(to_date);
+
+-- name: GetCartRecoverySeries :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, r.sent_at)::TIMESTAMP AS bucket,
+    COUNT(*) AS reminders,
+    COUNT(*) FILTER (WHERE r.clicked_at IS NOT NULL) AS clicked,
+    COUNT(DISTINCT (r.user_id, r.cart_started_at)) AS carts,
+    COUNT(DISTINCT (r.user_id, r.cart_started_at)) FILTER (WHERE r.recovered_at IS NOT NULL) AS recovered
+FROM cart_reminders r
+WHERE r.sent_at >= sqlc.arg(from_date)
+AND r.sent_at < sqlc.arg(to_date)
+GROUP BY 1
+ORDER BY 1;
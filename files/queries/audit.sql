@@ -0,0 +1,29 @@
+-- name: CreateAuditLog :exec
+INSERT INTO audit_logs (
+    actor_id,
+    actor_role,
+    action,
+    resource_type,
+    resource_id,
+    before,
+    after,
+    ip,
+    request_id,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+);
+
+-- name: SearchAuditLogs :many
+SELECT * FROM audit_logs
+WHERE (sqlc.narg(actor_id)::INTEGER IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(resource_type)::VARCHAR IS NULL OR resource_type = sqlc.narg(resource_type))
+AND (sqlc.narg(resource_id)::VARCHAR IS NULL OR resource_id = sqlc.narg(resource_id))
+AND (sqlc.narg(action)::VARCHAR IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(from_time)::TIMESTAMP IS NULL OR created_at >= sqlc.narg(from_time))
+AND (sqlc.narg(to_time)::TIMESTAMP IS NULL OR created_at < sqlc.narg(to_time))
+ORDER BY created_at DESC, audit_id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: GetAuditLog :one
+SELECT * FROM audit_logs WHERE audit_id = $1;
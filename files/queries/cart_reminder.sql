@@ -0,0 +1,55 @@
+-- name: GetAbandonedCarts :many
+-- open carts untouched since idle_before whose last reminder is older than idle_before too,
+-- carts already reminded max_reminders times are left alone
+WITH carts AS (
+    SELECT
+        cr.user_id::INTEGER AS user_id,
+        MIN(cr.created_at)::TIMESTAMP AS started_at,
+        MAX(COALESCE(cr.updated_at, cr.created_at))::TIMESTAMP AS last_activity,
+        COUNT(*) AS items,
+        COALESCE(SUM(course_effective_price(cs.course_id, sqlc.arg(now)::TIMESTAMP) * COALESCE(cr.quantity, 1)), 0)::BIGINT AS total_amount
+    FROM cart cr
+    JOIN courses cs ON cs.course_id = cr.course_id AND cs.deleted_at IS NULL AND cs.status = 'PUBLISHED'
+    WHERE cr.purchased_at IS NULL AND cr.user_id IS NOT NULL AND cr.created_at IS NOT NULL
+    GROUP BY cr.user_id
+)
+SELECT c.user_id, c.started_at, c.items, c.total_amount, u.nama, u.email, u.phone, COUNT(r.reminder_id) AS reminders
+FROM carts c
+JOIN "users" u ON u.user_id = c.user_id
+LEFT JOIN cart_reminders r ON r.user_id = c.user_id AND r.sent_at >= c.started_at
+GROUP BY c.user_id, c.started_at, c.last_activity, c.items, c.total_amount, u.nama, u.email, u.phone
+HAVING COUNT(r.reminder_id) < sqlc.arg(max_reminders)::INTEGER
+AND GREATEST(c.last_activity, MAX(r.sent_at)) < sqlc.arg(idle_before)::TIMESTAMP
+ORDER BY c.user_id;
+
+-- name: CreateCartReminder :one
+INSERT INTO cart_reminders (
+    user_id,
+    cart_started_at,
+    items,
+    total_amount,
+    sent_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetCartReminder :one
+SELECT * FROM cart_reminders WHERE reminder_id = $1;
+
+-- name: MarkCartReminderClicked :exec
+UPDATE cart_reminders SET clicked_at = $2 WHERE reminder_id = $1 AND clicked_at IS NULL;
+
+-- name: MarkCartRecovered :exec
+-- the reminders of the cart being bought are recovered, must run before MarkCartPurchased
+UPDATE cart_reminders SET recovered_at = sqlc.arg(recovered_at)
+WHERE user_id = sqlc.arg(user_id)::INTEGER AND recovered_at IS NULL
+AND sent_at >= (
+    SELECT MIN(cr.created_at) FROM cart cr
+    WHERE cr.user_id = sqlc.arg(user_id)::INTEGER AND cr.purchased_at IS NULL
+);
+
+-- name: PurgeStaleCarts :execrows
+-- open cart rows untouched since idle_before are dropped, bought rows are kept for the analytics
+DELETE FROM cart
+WHERE purchased_at IS NULL AND COALESCE(updated_at, created_at) < sqlc.arg(idle_before);
@@ -0,0 +1,90 @@
+-- name: ExportPayments :many
+-- exports read the tables in keyset pages so a full table is never held in memory,
+-- every filter is optional
+SELECT
+    p.payment_id,
+    p.user_id,
+    u.nama,
+    u.email,
+    pm.payment_method_name,
+    ps.payment_status_name,
+    p.total_amount,
+    p.payment_date
+FROM payment p
+LEFT JOIN "users" u ON u.user_id = p.user_id
+LEFT JOIN payment_method pm ON pm.payment_method_id = p.payment_method_id
+LEFT JOIN payment_status ps ON ps.payment_status_id = p.payment_status_id
+WHERE p.payment_id > sqlc.arg(after_id)
+AND (sqlc.narg(from_date)::TIMESTAMP IS NULL OR p.payment_date >= sqlc.narg(from_date))
+AND (sqlc.narg(to_date)::TIMESTAMP IS NULL OR p.payment_date < sqlc.narg(to_date))
+AND (sqlc.arg(status_id)::INTEGER = 0 OR p.payment_status_id = sqlc.arg(status_id))
+ORDER BY p.payment_id
+LIMIT sqlc.arg(page_size);
+
+-- name: ExportSubscriptions :many
+SELECT
+    s.subscription_id,
+    s.user_id,
+    u.nama,
+    u.email,
+    s.course_id,
+    c.course_name,
+    s.payment_id,
+    s.is_correct,
+    s.created_at,
+    s.updated_at
+FROM subscriptions s
+LEFT JOIN "users" u ON u.user_id = s.user_id
+LEFT JOIN courses c ON c.course_id = s.course_id
+WHERE s.subscription_id > sqlc.arg(after_id)
+AND (sqlc.narg(from_date)::TIMESTAMP IS NULL OR s.created_at >= sqlc.narg(from_date))
+AND (sqlc.narg(to_date)::TIMESTAMP IS NULL OR s.created_at < sqlc.narg(to_date))
+AND (sqlc.arg(course_id)::INTEGER = 0 OR s.course_id = sqlc.arg(course_id))
+ORDER BY s.subscription_id
+LIMIT sqlc.arg(page_size);
+
+-- name: ExportUsers :many
+SELECT u.user_id, u.nama, u.email, u.role, u.created_at
+FROM "users" u
+WHERE u.user_id > sqlc.arg(after_id)
+AND (sqlc.narg(from_date)::TIMESTAMP IS NULL OR u.created_at >= sqlc.narg(from_date))
+AND (sqlc.narg(to_date)::TIMESTAMP IS NULL OR u.created_at < sqlc.narg(to_date))
+AND (sqlc.arg(role)::TEXT = '' OR u.role = sqlc.arg(role))
+ORDER BY u.user_id
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateExportJob :one
+INSERT INTO export_jobs (
+    resource,
+    format,
+    filters,
+    status,
+    requested_by,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: StartExportJob :execrows
+UPDATE export_jobs SET status = 'RUNNING', started_at = $2
+WHERE job_id = $1 AND status = 'PENDING';
+
+-- name: FinishExportJob :exec
+UPDATE export_jobs SET status = 'DONE', file_url = $2, row_count = $3, finished_at = $4
+WHERE job_id = $1;
+
+-- name: FailExportJob :exec
+UPDATE export_jobs SET status = 'FAILED', error = $2, finished_at = $3
+WHERE job_id = $1;
+
+-- name: FailStaleExportJobs :execrows
+-- a job still running since started_before lost its consumer (crash or deploy), nothing will finish it
+UPDATE export_jobs SET status = 'FAILED', error = sqlc.arg(error), finished_at = sqlc.arg(finished_at)
+WHERE status = 'RUNNING' AND started_at < sqlc.arg(started_before);
+
+-- name: GetExportJobByID :one
+SELECT * FROM export_jobs WHERE job_id = $1;
+
+-- name: GetExportJobs :many
+SELECT * FROM export_jobs ORDER BY created_at DESC LIMIT 100;
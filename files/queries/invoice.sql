@@ -0,0 +1,88 @@
+-- name: NextInvoiceNumber :one
+-- the counter row stays locked until the transaction ends, concurrent invoices wait for it
+INSERT INTO invoice_sequences (year, last_number) VALUES ($1, 1)
+ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+RETURNING last_number;
+
+-- name: MarkPaymentPaid :execrows
+UPDATE payment SET payment_status_id = sqlc.arg(paid_status)
+WHERE payment_id = sqlc.arg(payment_id) AND payment_status_id IS DISTINCT FROM sqlc.arg(paid_status);
+
+-- name: GetPaymentForInvoice :one
+SELECT p.payment_id, p.user_id, p.payment_status_id, p.total_amount, COALESCE(pm.payment_method_name, '')::TEXT AS payment_method_name
+FROM payment p
+LEFT JOIN payment_method pm ON pm.payment_method_id = p.payment_method_id
+WHERE p.payment_id = $1;
+
+-- name: CreateMissingTransactionHistory :execrows
+-- a paid course without transaction history would never reach the ledger, it is priced as it was
+-- when the payment was made
+INSERT INTO transaction_history (subscription_id, quantity, total_amount, is_paid, subcriptions_start_date, proof, created_at, updated_at)
+SELECT s.subscription_id, 1, COALESCE(course_effective_price(s.course_id, COALESCE(p.payment_date, sqlc.arg(now))), 0), 'yes', sqlc.arg(now), '-', sqlc.arg(now), sqlc.arg(now)
+FROM subscriptions s
+JOIN payment p ON p.payment_id = s.payment_id
+WHERE s.payment_id = sqlc.arg(payment_id)
+AND NOT EXISTS (SELECT 1 FROM transaction_history th WHERE th.subscription_id = s.subscription_id);
+
+-- name: GetPaymentItems :many
+SELECT
+    s.course_id,
+    COALESCE(c.course_name, '')::TEXT AS course_name,
+    COALESCE(c.price, 0)::BIGINT AS unit_price,
+    COALESCE(th.quantity, 1)::INTEGER AS quantity,
+    COALESCE(th.total_amount, c.price, 0)::BIGINT AS amount
+FROM subscriptions s
+LEFT JOIN courses c ON c.course_id = s.course_id
+LEFT JOIN transaction_history th ON th.subscription_id = s.subscription_id
+WHERE s.payment_id = $1
+ORDER BY s.subscription_id;
+
+-- name: CreateInvoice :one
+INSERT INTO invoices (
+    invoice_number,
+    payment_id,
+    user_id,
+    payment_method_name,
+    subtotal,
+    discount,
+    tax,
+    tax_rate_bp,
+    total,
+    issued_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING *;
+
+-- name: CreateInvoiceItem :exec
+INSERT INTO invoice_items (
+    invoice_id,
+    course_id,
+    course_name,
+    unit_price,
+    quantity,
+    discount,
+    amount
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: GetInvoiceByPaymentID :one
+SELECT i.*, u.nama, u.email
+FROM invoices i
+LEFT JOIN "users" u ON u.user_id = i.user_id
+WHERE i.payment_id = $1;
+
+-- name: GetInvoiceItems :many
+SELECT * FROM invoice_items WHERE invoice_id = $1 ORDER BY invoice_item_id;
+
+-- name: SetInvoiceFile :exec
+UPDATE invoices SET file_url = $2 WHERE invoice_id = $1;
+
+-- name: SetInvoiceEmailed :exec
+UPDATE invoices SET emailed_at = $2 WHERE invoice_number = $1;
+
+-- name: ExpirePendingPayments :execrows
+-- pending payments older than created_before are never going to be paid
+UPDATE payment SET payment_status_id = sqlc.arg(failed_status)
+WHERE payment_status_id = sqlc.arg(pending_status) AND payment_date < sqlc.arg(created_before);
@@ -0,0 +1,25 @@
+-- name: CreateJobRun :one
+INSERT INTO job_runs (
+    job_name,
+    trigger,
+    status,
+    triggered_by,
+    started_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: FinishJobRun :exec
+UPDATE job_runs SET status = $2, error = $3, finished_at = $4
+WHERE run_id = $1;
+
+-- name: GetLatestJobRuns :many
+SELECT DISTINCT ON (job_name) * FROM job_runs
+ORDER BY job_name, started_at DESC;
+
+-- name: GetJobRuns :many
+SELECT * FROM job_runs
+WHERE job_name = $1
+ORDER BY started_at DESC
+LIMIT 50;
@@ -0,0 +1,43 @@
+-- name: TrackDeliveryAttempt :one
+-- the row is created on the first attempt, a message that was already sent is left as is
+INSERT INTO notification_deliveries (
+    message_id,
+    channel,
+    template,
+    recipient,
+    status,
+    attempts,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, 'QUEUED', $5, $6, $6
+)
+ON CONFLICT (message_id) DO UPDATE SET attempts = EXCLUDED.attempts, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: MarkDeliverySent :exec
+UPDATE notification_deliveries SET status = 'SENT', last_error = NULL, sent_at = $2, updated_at = $2
+WHERE message_id = $1;
+
+-- name: MarkDeliveryRetrying :exec
+UPDATE notification_deliveries SET status = 'RETRYING', last_error = $2, updated_at = $3
+WHERE message_id = $1 AND status <> 'SENT';
+
+-- name: MarkDeliveryDead :exec
+UPDATE notification_deliveries SET status = 'DEAD', last_error = $2, updated_at = $3
+WHERE message_id = $1 AND status <> 'SENT';
+
+-- name: GetNotificationDeliveries :many
+SELECT * FROM notification_deliveries
+WHERE (sqlc.narg(status)::VARCHAR IS NULL OR status = sqlc.narg(status))
+AND (sqlc.narg(channel)::VARCHAR IS NULL OR channel = sqlc.narg(channel))
+AND (sqlc.narg(recipient)::VARCHAR IS NULL OR recipient = sqlc.narg(recipient))
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: GetNotificationDeliveryByID :one
+SELECT * FROM notification_deliveries WHERE delivery_id = $1;
+
+-- name: MarkDeliverySkipped :exec
+UPDATE notification_deliveries SET status = 'SKIPPED', updated_at = $2
+WHERE message_id = $1;
@@ -0,0 +1,25 @@
+-- name: GetNotificationPreference :one
+SELECT u.role, p.enabled
+FROM "users" u
+LEFT JOIN notification_preferences p ON p.user_id = u.user_id AND p.event_type = $2 AND p.channel = $3
+WHERE u.user_id = $1;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+-- a null frequency keeps the stored one
+INSERT INTO notification_preferences (
+    user_id,
+    event_type,
+    channel,
+    enabled,
+    frequency,
+    updated_at
+) VALUES (
+    sqlc.arg(user_id), sqlc.arg(event_type), sqlc.arg(channel), sqlc.arg(enabled), COALESCE(sqlc.narg(frequency)::VARCHAR, 'instant'), sqlc.arg(updated_at)
+)
+ON CONFLICT (user_id, event_type, channel) DO UPDATE SET
+    enabled = EXCLUDED.enabled,
+    frequency = COALESCE(sqlc.narg(frequency)::VARCHAR, notification_preferences.frequency),
+    updated_at = EXCLUDED.updated_at;
@@ -0,0 +1,40 @@
+-- name: GetUserPermissions :many
+SELECT u.role, rp.permission FROM users u
+LEFT JOIN role_permissions rp ON rp.role = u.role
+WHERE u.user_id = $1;
+
+-- name: GetRoles :many
+SELECT r.*, COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')::TEXT[] AS permissions,
+       (SELECT COUNT(*) FROM users u WHERE u.role = r.role) AS users
+FROM roles r
+LEFT JOIN role_permissions rp ON rp.role = r.role
+GROUP BY r.role
+ORDER BY r.role;
+
+-- name: GetRoleForUpdate :one
+SELECT * FROM roles WHERE role = $1 FOR UPDATE;
+
+-- name: GetRolePermissions :many
+SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission;
+
+-- name: CreateRole :exec
+INSERT INTO roles (role, description, created_at, updated_at)
+VALUES ($1, $2, $3, $3);
+
+-- name: UpdateRole :exec
+UPDATE roles SET description = $2, updated_at = $3 WHERE role = $1;
+
+-- name: DeleteRole :execrows
+DELETE FROM roles WHERE role = $1;
+
+-- name: DeleteRolePermissions :exec
+DELETE FROM role_permissions WHERE role = $1;
+
+-- name: AddRolePermission :exec
+INSERT INTO role_permissions (role, permission) VALUES ($1, $2);
+
+-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users WHERE role = $1;
+
+-- name: UpdateUserRole :execrows
+UPDATE users SET role = $2 WHERE user_id = $1;
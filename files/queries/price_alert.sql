@@ -0,0 +1,80 @@
+-- name: CreateCourseSale :one
+INSERT INTO course_sales (
+    course_id,
+    sale_price,
+    starts_at,
+    ends_at,
+    created_by,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetCourseSales :many
+SELECT * FROM course_sales
+WHERE course_id = $1 AND deleted_at IS NULL
+ORDER BY starts_at DESC;
+
+-- name: GetCourseSale :one
+SELECT * FROM course_sales WHERE sale_id = $1 AND deleted_at IS NULL;
+
+-- name: DeleteCourseSale :execrows
+UPDATE course_sales SET deleted_at = $2 WHERE sale_id = $1 AND deleted_at IS NULL;
+
+-- name: RecordPriceChanges :many
+-- compare the effective price of the courses (all when course_id is null) with the tracked one,
+-- the tracked price is only updated when it differs so concurrent runs record a change once
+WITH current_prices AS (
+    SELECT c.course_id, ep.price AS old_price, course_effective_price(c.course_id, sqlc.arg(now)::TIMESTAMP) AS new_price, s.sale_id
+    FROM courses c
+    LEFT JOIN course_effective_prices ep ON ep.course_id = c.course_id
+    LEFT JOIN LATERAL (
+        SELECT cs.sale_id, cs.sale_price FROM course_sales cs
+        WHERE cs.course_id = c.course_id AND cs.deleted_at IS NULL
+        AND cs.starts_at <= sqlc.arg(now)::TIMESTAMP AND cs.ends_at > sqlc.arg(now)::TIMESTAMP
+        ORDER BY cs.sale_price
+        LIMIT 1
+    ) s ON TRUE
+    WHERE c.deleted_at IS NULL
+    AND (sqlc.narg(course_id)::INTEGER IS NULL OR c.course_id = sqlc.narg(course_id))
+), tracked AS (
+    INSERT INTO course_effective_prices (course_id, price, updated_at)
+    SELECT cp.course_id, cp.new_price, sqlc.arg(now)::TIMESTAMP FROM current_prices cp
+    WHERE cp.old_price IS DISTINCT FROM cp.new_price
+    ON CONFLICT (course_id) DO UPDATE SET price = EXCLUDED.price, updated_at = EXCLUDED.updated_at
+    WHERE course_effective_prices.price <> EXCLUDED.price
+    RETURNING course_id
+)
+INSERT INTO course_price_changes (course_id, old_price, new_price, sale_id, created_at)
+SELECT cp.course_id, cp.old_price, cp.new_price, cp.sale_id, sqlc.arg(now)::TIMESTAMP
+FROM current_prices cp
+JOIN tracked t ON t.course_id = cp.course_id
+WHERE cp.old_price IS NOT NULL
+RETURNING *;
+
+-- name: CreatePriceDropAlerts :many
+-- one alert for every user with the course in the wishlist, when the price went down
+INSERT INTO wishlist_price_alerts (change_id, user_id, course_id, digest, created_at)
+SELECT DISTINCT ch.change_id, w.user_id, ch.course_id, COALESCE(p.frequency = 'daily', FALSE), sqlc.arg(created_at)::TIMESTAMP
+FROM course_price_changes ch
+JOIN wishlist w ON w.course_id = ch.course_id AND w.deleted_at IS NULL AND w.user_id IS NOT NULL
+LEFT JOIN notification_preferences p ON p.user_id = w.user_id AND p.event_type = 'price_alert' AND p.channel = 'email'
+WHERE ch.change_id = sqlc.arg(change_id) AND ch.new_price < ch.old_price
+ON CONFLICT (change_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetPendingPriceAlerts :many
+SELECT a.alert_id, a.user_id, a.course_id, a.digest, a.created_at, u.nama, u.email, c.course_name, ch.old_price, ch.new_price, s.ends_at AS sale_ends_at
+FROM wishlist_price_alerts a
+JOIN "users" u ON u.user_id = a.user_id
+JOIN courses c ON c.course_id = a.course_id
+JOIN course_price_changes ch ON ch.change_id = a.change_id
+LEFT JOIN course_sales s ON s.sale_id = ch.sale_id
+WHERE a.sent_at IS NULL AND a.digest = sqlc.arg(digest)
+AND (sqlc.narg(change_id)::INTEGER IS NULL OR a.change_id = sqlc.narg(change_id))
+ORDER BY a.user_id, a.created_at;
+
+-- name: MarkPriceAlertsSent :exec
+UPDATE wishlist_price_alerts SET sent_at = sqlc.arg(sent_at)
+WHERE alert_id = ANY(sqlc.arg(alert_ids)::INTEGER[]) AND sent_at IS NULL;
@@ -23,7 +23,7 @@ SELECT * FROM "users" WHERE role = $1;
 SELECT * FROM "users" WHERE role = $1;
 
 -- name: UpdateUser :exec
-UPDATE "users" SET nama = $1, email = $2, photo = $3 WHERE user_id = $4;
+UPDATE "users" SET nama = $1, email = $2, photo = $3, phone = COALESCE($5, phone) WHERE user_id = $4;
 
 -- name: DeleteUser :exec
 DELETE FROM "users" WHERE user_id = $1;
@@ -31,29 +31,110 @@ DELETE FROM "users" WHERE user_id = $1;
 -- name: Login :one
 SELECT * FROM "users" WHERE nama = $1;
 
--- name: CreateTeacher :exec
+-- name: CreateTeacher :one
 INSERT INTO teachers (
-    teacher_id,
-    user_id,    
+    user_id,
     teacher_name,
     created_at,
     updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5
-);
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: EnsureTeacherProfile :exec
+-- a profile deleted by an admin is not created again
+INSERT INTO teachers (user_id, teacher_name, created_at, updated_at)
+SELECT u.user_id, u.nama, $2, $2 FROM "users" u
+WHERE u.user_id = $1 AND NOT EXISTS (SELECT 1 FROM teachers t WHERE t.user_id = u.user_id)
+ON CONFLICT (user_id) WHERE deleted_at IS NULL DO NOTHING;
 
 -- name: GetAllTeacher :many
-SELECT * FROM teachers;
+SELECT * FROM teachers WHERE deleted_at IS NULL ORDER BY teacher_name;
 
 -- name: GetTeacherByID :one
-SELECT * FROM teachers WHERE teacher_id = $1;
+SELECT * FROM teachers WHERE teacher_id = $1 AND deleted_at IS NULL;
+
+-- name: GetTeacherByUserID :one
+SELECT * FROM teachers WHERE user_id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateTeacher :execrows
+UPDATE teachers SET teacher_name = $1, updated_at = $2 WHERE teacher_id = $3 AND deleted_at IS NULL;
+
+-- name: UpdateTeacherProfile :one
+UPDATE teachers SET
+    teacher_name = $2,
+    headline = $3,
+    bio = $4,
+    expertise = $5,
+    social_links = $6,
+    avatar = $7,
+    updated_at = $8
+WHERE user_id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteTeacher :execrows
+UPDATE teachers SET deleted_at = $2 WHERE teacher_id = $1 AND deleted_at IS NULL;
+
+-- name: GetTeacherStats :one
+SELECT
+    (SELECT COUNT(DISTINCT s.user_id) FROM subscriptions s
+        JOIN courses c ON c.course_id = s.course_id
+        WHERE c.user_id = $1 AND c.deleted_at IS NULL) AS student_count,
+    (SELECT COUNT(*) FROM course_ratings r
+        JOIN courses c ON c.course_id = r.course_id
+        WHERE c.user_id = $1 AND c.deleted_at IS NULL) AS review_count,
+    (SELECT COALESCE(AVG(r.rating), 0)::FLOAT8 FROM course_ratings r
+        JOIN courses c ON c.course_id = r.course_id
+        WHERE c.user_id = $1 AND c.deleted_at IS NULL) AS average_rating;
+
+-- name: GetTeacherPublishedCourses :many
+SELECT
+    c.course_id,
+    c.course_name,
+    c.course_description,
+    course_effective_price(c.course_id, sqlc.arg(now)::TIMESTAMP) AS price,
+    c.thumbnail,
+    (SELECT COUNT(DISTINCT s.user_id) FROM subscriptions s WHERE s.course_id = c.course_id) AS student_count,
+    (SELECT COUNT(*) FROM course_ratings r WHERE r.course_id = c.course_id) AS review_count,
+    (SELECT COALESCE(AVG(r.rating), 0)::FLOAT8 FROM course_ratings r WHERE r.course_id = c.course_id) AS average_rating
+FROM courses c
+WHERE c.user_id = sqlc.arg(user_id) AND c.status = 'PUBLISHED' AND c.deleted_at IS NULL
+ORDER BY c.created_at DESC;
+
+-- name: GetCourseTeacher :one
+SELECT t.teacher_id, t.teacher_name, t.headline, t.avatar
+FROM courses c
+JOIN teachers t ON t.user_id = c.user_id AND t.deleted_at IS NULL
+WHERE c.course_id = $1;
+
+-- name: UpsertCourseRating :exec
+INSERT INTO course_ratings (
+    course_id,
+    user_id,
+    rating,
+    review,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, $5, $5
+)
+ON CONFLICT (course_id, user_id) DO UPDATE SET rating = EXCLUDED.rating, review = EXCLUDED.review, updated_at = EXCLUDED.updated_at;
 
--- name: UpdateTeacher :exec
-UPDATE teachers SET teacher_id = $1, user_id = $2, teacher_name = $3 WHERE teacher_id = $4;
+-- name: GetCourseRatings :many
+SELECT r.rating_id, r.user_id, u.nama, u.photo, r.rating, r.review, r.reply, r.replied_at, r.created_at, r.updated_at
+FROM course_ratings r
+JOIN "users" u ON u.user_id = r.user_id
+WHERE r.course_id = $1
+ORDER BY r.updated_at DESC;
 
--- name: DeleteTeacher :exec
-DELETE FROM teachers WHERE teacher_id = $1;
+-- name: ReplyCourseRating :one
+UPDATE course_ratings SET reply = $2, replied_at = $3
+WHERE rating_id = $1
+RETURNING *;
 
+-- name: GetCourseRating :one
+SELECT * FROM course_ratings WHERE rating_id = $1;
 
 -- name: CreateCourse :exec
 INSERT INTO courses (
@@ -64,31 +145,34 @@ INSERT INTO courses (
   thumbnail,
   created_at,
   deleted_at,
-  updated_at
+  updated_at,
+  user_id,
+  status
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 );
 
 -- name: GetCoursePrice :many
-SELECT course_id, course_name, course_description , price, thumbnail
+SELECT course_id, course_name, course_description, course_effective_price(course_id, sqlc.arg(now)::TIMESTAMP) AS price, thumbnail
 FROM courses
+WHERE deleted_at IS NULL AND status = 'PUBLISHED'
 ORDER BY price DESC;
 
 
 -- name: GetMyCoursePage :many 
 SELECT c.course_id, c.course_name, c.course_description
 FROM subscriptions s
-LEFT JOIN  courses c  ON c.course_id = s.course_id WHERE user_id = $1;
+LEFT JOIN  courses c  ON c.course_id = s.course_id WHERE s.user_id = $1;
 
 
 -- name: GetAllCourse :many
-SELECT * FROM courses;
+SELECT *, course_effective_price(course_id, sqlc.arg(now)::TIMESTAMP) AS effective_price FROM courses WHERE deleted_at IS NULL AND status = 'PUBLISHED';
 
 -- name: GetMyCourse :one
 SELECT * 
 FROM subscriptions 
 LEFT JOIN courses ON subscriptions.course_id = courses.course_id
-LEFT JOIN courses_video ON courses.course_id = courses_video.course_id
+LEFT JOIN courses_video ON courses.course_id = courses_video.course_id AND courses_video.deleted_at IS NULL
 WHERE subscriptions.course_id = $1 
 AND subscriptions.user_id = $2;
 
@@ -96,25 +180,79 @@ AND subscriptions.user_id = $2;
 SELECT * 
 FROM subscriptions 
 LEFT JOIN courses ON subscriptions.course_id = courses.course_id 
-LEFT JOIN courses_video ON courses.course_id = courses_video.course_id
+LEFT JOIN courses_video ON courses.course_id = courses_video.course_id AND courses_video.deleted_at IS NULL
 WHERE subscriptions.user_id = $1;
 
 -- name: GetCourseByID :one
-SELECT * FROM courses LEFT JOIN courses_video ON courses.course_id = courses_video.course_id WHERE courses.course_id = $1;
+SELECT *, course_effective_price(courses.course_id, sqlc.arg(now)::TIMESTAMP) AS effective_price FROM courses LEFT JOIN courses_video ON courses.course_id = courses_video.course_id AND courses_video.deleted_at IS NULL WHERE courses.course_id = sqlc.arg(course_id) AND courses.deleted_at IS NULL AND courses.status = 'PUBLISHED';
+
+-- name: GetCourseForUpdate :one
+SELECT * FROM courses WHERE course_id = $1 AND deleted_at IS NULL;
 
--- name: GetCourseByNew :one
-SELECT * FROM courses ORDER BY created_at DESC;
+-- name: GetCourseByNew :many
+SELECT *, course_effective_price(course_id, sqlc.arg(now)::TIMESTAMP) AS effective_price FROM courses WHERE deleted_at IS NULL AND status = 'PUBLISHED' ORDER BY created_at DESC;
 
 -- name: UpdateCourse :exec
-UPDATE courses SET course_name = $1,course_description = $2, category_id = $3, price = $4, thumbnail  = $5, updated_at= $6 WHERE course_id = $7;
+UPDATE courses SET course_name = $1,course_description = $2, category_id = $3, price = $4, thumbnail  = $5, updated_at= $6 WHERE course_id = $7 AND deleted_at IS NULL;
+
+-- name: DeleteCourse :execrows
+UPDATE courses SET deleted_at = $2 WHERE course_id = $1 AND deleted_at IS NULL;
+
+-- name: GetDeletedCourses :many
+SELECT * FROM courses WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC;
+
+-- name: GetDeletedCourse :one
+SELECT * FROM courses WHERE course_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreCourse :execrows
+UPDATE courses SET deleted_at = NULL, updated_at = $2 WHERE course_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: CountCourseSubscriptions :one
+SELECT COUNT(*) FROM subscriptions WHERE course_id = $1;
+
+-- name: PurgeCourse :execrows
+WITH purged_videos AS (
+  DELETE FROM courses_video cv
+  WHERE cv.course_id = $1
+  AND EXISTS (SELECT 1 FROM courses c WHERE c.course_id = $1 AND c.deleted_at IS NOT NULL)
+)
+DELETE FROM courses pc WHERE pc.course_id = $1 AND pc.deleted_at IS NOT NULL;
+
+-- name: GetCoursesByOwner :many
+SELECT * FROM courses WHERE user_id = $1 AND deleted_at IS NULL ORDER BY updated_at DESC;
+
+-- name: GetCoursesByStatus :many
+SELECT * FROM courses WHERE status = $1 AND deleted_at IS NULL ORDER BY updated_at ASC;
+
+-- name: UpdateCourseStatus :execrows
+UPDATE courses SET status = @to_status, updated_at = @updated_at
+WHERE course_id = @course_id
+AND status = ANY(@from_status::VARCHAR[])
+AND deleted_at IS NULL;
 
--- name: DeleteCourse :exec
-DELETE FROM courses WHERE course_id = $1;
+-- name: CreateCoursePublicationLog :exec
+INSERT INTO course_publication_log (
+  course_id,
+  user_id,
+  from_status,
+  to_status,
+  comment,
+  created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: GetCoursePublicationLog :many
+SELECT l.log_id, l.course_id, l.user_id, u.nama, l.from_status, l.to_status, l.comment, l.created_at
+FROM course_publication_log l
+LEFT JOIN users u ON u.user_id = l.user_id
+WHERE l.course_id = $1
+ORDER BY l.created_at DESC, l.log_id DESC;
 
 -- name: GetLastCourseID :one
 SELECT course_id FROM courses ORDER BY course_id DESC LIMIT 1;
 
--- name: CreateCategory :exec
+-- name: CreateCategory :one
 INSERT INTO categories (
     category_name,
     icon,
@@ -122,31 +260,48 @@ INSERT INTO categories (
     updated_at
 ) VALUES (
     $1, $2, $3, $4
-);
+)
+RETURNING *;
 
 -- name: GetAllCategories :many
-SELECT * FROM categories;
+SELECT * FROM categories WHERE deleted_at IS NULL;
 
 -- name: GetCategory :many
 SELECT c.course_id, c.course_name, cr.category_id, cr.category_name 
 FROM courses c
 JOIN categories cr ON c.category_id = cr.category_id 
-WHERE cr.category_name;
+WHERE cr.category_name = $1 AND c.deleted_at IS NULL AND c.status = 'PUBLISHED' AND cr.deleted_at IS NULL;
 
 -- name: GetCoursesByCategoryID :many
-SELECT * FROM courses WHERE category_id = $1;
+SELECT *, course_effective_price(course_id, sqlc.arg(now)::TIMESTAMP) AS effective_price FROM courses WHERE category_id = sqlc.arg(category_id) AND deleted_at IS NULL AND status = 'PUBLISHED';
 
 
 -- name: GetCategoryByID :one
-SELECT * FROM categories WHERE category_id = $1;
+SELECT * FROM categories WHERE category_id = $1 AND deleted_at IS NULL;
 
 -- name: UpdateCategory :exec
- UPDATE categories SET category_id = $1, category_name = $2, icon = $3, updated_at = $4 WHERE category_id = $5;
+ UPDATE categories SET category_id = $1, category_name = $2, icon = $3, updated_at = $4 WHERE category_id = $5 AND deleted_at IS NULL;
+
+-- name: DeleteCategory :execrows
+UPDATE categories SET deleted_at = $2 WHERE category_id = $1 AND deleted_at IS NULL;
 
--- name: DeleteCategory :exec
-DELETE FROM categories WHERE category_id = $1;
+-- name: GetDeletedCategories :many
+SELECT * FROM categories WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC;
 
--- name: CreateCourseVideo :exec
+-- name: GetDeletedCategory :one
+SELECT * FROM categories WHERE category_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreCategory :execrows
+UPDATE categories SET deleted_at = NULL, updated_at = $2 WHERE category_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: CountCoursesByCategory :one
+-- counts soft deleted courses too, they can still be restored into the category
+SELECT COUNT(*) FROM courses WHERE category_id = $1;
+
+-- name: PurgeCategory :execrows
+DELETE FROM categories WHERE category_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: CreateCourseVideo :one
 INSERT INTO courses_video (
     course_id,
     course_video_name,
@@ -156,7 +311,8 @@ INSERT INTO courses_video (
     updated_at
 ) VALUES (
     $1, $2, $3, $4, $5, $6
-);
+)
+RETURNING *;
 
 -- name: GetCourse :one
  SELECT 
@@ -164,31 +320,53 @@ INSERT INTO courses_video (
  	thumbnail, 
  	course_name, 
  	price
- FROM courses c ;
+ FROM courses c
+ WHERE deleted_at IS NULL AND status = 'PUBLISHED';
 
 
 -- name: GetAllCourseVideos :many
-SELECT * FROM courses_video;
+SELECT * FROM courses_video
+WHERE deleted_at IS NULL
+AND course_id IN (SELECT course_id FROM courses WHERE deleted_at IS NULL AND status = 'PUBLISHED');
 
 -- name: GetCourseVideo :many
 SELECT c.course_id, c.course_name, c.course_description, cat.category_name,
        cv.course_video_id , cv.course_video_name , cv.path_video 
 FROM courses c
-LEFT JOIN categories cat ON c.category_id = cat.category_id 
-LEFT JOIN courses_video cv  ON c.course_id = cv.course_id;
+LEFT JOIN categories cat ON c.category_id = cat.category_id AND cat.deleted_at IS NULL
+LEFT JOIN courses_video cv  ON c.course_id = cv.course_id AND cv.deleted_at IS NULL
+WHERE c.deleted_at IS NULL AND c.status = 'PUBLISHED';
 
 
 -- name: GetCourseVideoByID :one
-SELECT * FROM courses_video WHERE course_video_id = $1;
+SELECT * FROM courses_video
+WHERE course_video_id = $1
+AND deleted_at IS NULL
+AND course_id IN (SELECT course_id FROM courses WHERE deleted_at IS NULL AND status = 'PUBLISHED');
+
+-- name: GetCourseVideoForUpdate :one
+SELECT * FROM courses_video WHERE course_video_id = $1 AND deleted_at IS NULL;
 
 -- name: GetCourseVideoByCourseID :one
-SELECT * FROM courses_video WHERE course_id = $1;
+SELECT * FROM courses_video WHERE course_id = $1 AND deleted_at IS NULL;
 
 -- name: UpdateCourseVideo :exec
-UPDATE courses_video SET course_id = $1, course_video_name = $2, path_video = $3, updated_at = $4 WHERE course_video_id = $5;
+UPDATE courses_video SET course_id = $1, course_video_name = $2, path_video = $3, updated_at = $4 WHERE course_video_id = $5 AND deleted_at IS NULL;
+
+-- name: DeleteCourseVideo :execrows
+UPDATE courses_video SET deleted_at = $2 WHERE course_video_id = $1 AND deleted_at IS NULL;
 
--- name: DeleteCourseVideo :exec
-DELETE FROM courses_video WHERE course_video_id = $1;
+-- name: GetDeletedCourseVideos :many
+SELECT * FROM courses_video WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC;
+
+-- name: GetDeletedCourseVideo :one
+SELECT * FROM courses_video WHERE course_video_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreCourseVideo :execrows
+UPDATE courses_video SET deleted_at = NULL, updated_at = $2 WHERE course_video_id = $1 AND deleted_at IS NOT NULL;
+
+-- name: PurgeCourseVideo :execrows
+DELETE FROM courses_video WHERE course_video_id = $1 AND deleted_at IS NOT NULL;
 
 
 -- name: CreateWishlist :exec
@@ -203,11 +381,11 @@ INSERT INTO wishlist (
 );
 
 -- name: GetAllWishlists :many
-SELECT *
+SELECT *, course_effective_price(courses.course_id, sqlc.arg(now)::TIMESTAMP) AS effective_price
 FROM wishlist
 LEFT JOIN courses
 ON wishlist.course_id = courses.course_id
-WHERE user_id = $1;
+WHERE wishlist.user_id = sqlc.arg(user_id) AND courses.deleted_at IS NULL;
 
 -- name: GetWishlistByID :one
 SELECT * FROM wishlist WHERE user_id = $1;
@@ -225,62 +403,90 @@ INSERT INTO cart(
   course_name ,
   price ,
   quantity ,
-  total_amount 
+  total_amount ,
+  created_at ,
+  updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $7
 );
 
 -- name: GetAllCart :many
-SELECT * FROM cart;
+SELECT * FROM cart WHERE purchased_at IS NULL;
 
 -- name: GetCartByUserID :many 
+-- the items are priced at priced_at, the price the client put in the cart is not trusted
 SELECT
 	cs.course_id,
 	cs.thumbnail,
     cs.course_name,
-    cs.price,
+    course_effective_price(cs.course_id, sqlc.arg(priced_at)::TIMESTAMP) AS price,
     cr.quantity,
-    cr.total_amount
+    (course_effective_price(cs.course_id, sqlc.arg(priced_at)::TIMESTAMP) * COALESCE(cr.quantity, 1))::INTEGER AS total_amount
 FROM
     cart cr
 LEFT JOIN courses cs 
 ON cr.course_id = cs.course_id 
-WHERE cr.user_id = $1;
+WHERE cr.user_id = sqlc.arg(user_id) AND cr.purchased_at IS NULL AND cs.deleted_at IS NULL AND cs.status = 'PUBLISHED';
 
 
 -- name: UpdateCart :exec
 UPDATE cart SET cart_id = $1, course_id = $2, course_name = $3, price = $4, quantity = $5, total_amount = $6 WHERE cart_id = $7;
 
 -- name: DeleteCart :exec
-DELETE FROM cart WHERE user_id = $1 AND course_id = $2;
+DELETE FROM cart WHERE user_id = $1 AND course_id = $2 AND purchased_at IS NULL;
 
--- name: DeleteCartByUserID :exec
-DELETE FROM cart WHERE user_id = $1;
+-- name: MarkCartPurchased :exec
+UPDATE cart SET purchased_at = $2 WHERE user_id = $1 AND purchased_at IS NULL;
 
--- name: CreateNotification :exec
+-- name: CreateNotification :one
 INSERT INTO notification (
   user_id,
   course_id,
+  type,
+  title,
   message,
-  is_read,
+  data,
   created_at,
   updated_at
 ) VALUES (
-  $1, $2, $3, $4, $5, $6
-);
+  $1, $2, $3, $4, $5, $6, $7, $7
+)
+RETURNING *;
+
+-- name: CreateCourseNotifications :many
+-- one notification for every student subscribed to the course who did not turn the event off,
+-- default_enabled applies to students without a stored preference
+INSERT INTO notification (user_id, course_id, type, title, message, data, created_at, updated_at)
+SELECT DISTINCT s.user_id, s.course_id, sqlc.arg(type), sqlc.arg(title), sqlc.arg(message)::TEXT, sqlc.arg(data)::JSONB, sqlc.arg(created_at)::TIMESTAMP, sqlc.arg(created_at)::TIMESTAMP
+FROM subscriptions s
+LEFT JOIN notification_preferences p ON p.user_id = s.user_id AND p.event_type = sqlc.arg(type) AND p.channel = 'in_app'
+WHERE s.course_id = sqlc.arg(course_id) AND s.user_id IS NOT NULL
+AND COALESCE(p.enabled, sqlc.arg(default_enabled)::BOOLEAN)
+RETURNING *;
 
--- name: GetAllNotifications :many
-SELECT * FROM notification;
+-- name: GetUserNotifications :many
+SELECT * FROM notification
+WHERE user_id = $1
+AND (NOT sqlc.arg(unread_only)::BOOLEAN OR NOT is_read)
+ORDER BY created_at DESC, notification_id DESC
+LIMIT $2 OFFSET $3;
 
--- name: UpdateNotification :exec
-UPDATE notification
-SET user_id = $1, course_id = $2, message = $3, is_read = $4, created_at = $5, updated_at = $6 WHERE notification_id = $7;
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notification WHERE user_id = $1 AND NOT is_read;
 
--- name: DeleteNotification :exec
-DELETE FROM notification WHERE notification_id = $1;
+-- name: MarkNotificationRead :execrows
+UPDATE notification SET is_read = TRUE, read_at = $3, updated_at = $3
+WHERE notification_id = $1 AND user_id = $2 AND NOT is_read;
 
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notification SET is_read = TRUE, read_at = $2, updated_at = $2
+WHERE user_id = $1 AND NOT is_read;
 
--- name: CreateSubscription :exec
+-- name: DeleteNotification :execrows
+DELETE FROM notification WHERE notification_id = $1 AND user_id = $2;
+
+
+-- name: CreateSubscription :one
 INSERT INTO subscriptions (
     user_id,
     course_id,
@@ -290,7 +496,8 @@ INSERT INTO subscriptions (
     updated_at
 ) VALUES (
     $1, $2, $3, $4, $5, $6
-);
+)
+RETURNING *;
 
 -- name: GetAllSubscriptions :many
 SELECT * FROM subscriptions;
@@ -309,6 +516,8 @@ LEFT JOIN
     subscriptions s ON s.course_id = c.course_id 
 LEFT JOIN 
     transaction_history th ON th.subscription_id = s.subscription_id 
+WHERE
+    c.deleted_at IS NULL AND c.status = 'PUBLISHED'
 GROUP BY 
     c.course_id, 
     c.course_name,
@@ -329,7 +538,7 @@ WHERE s.user_id = $1
 ORDER BY subscription_id DESC
 LIMIT 1;
 
--- name: CreatePayment :exec
+-- name: CreatePayment :one
 INSERT INTO payment (
     user_id,
     payment_method_id,
@@ -338,7 +547,8 @@ INSERT INTO payment (
     payment_date
 ) VALUES (
     $1, $2, $3, $4, $5
-);
+)
+RETURNING *;
 
 -- name: GetPayment :many
  SELECT 
@@ -358,7 +568,7 @@ INSERT INTO payment (
     ON c.cart_id = s.cart_id;
 
 -- name: GetLastPayment :one
-SELECT p.payment_id
+SELECT p.payment_id, p.payment_date
 FROM payment p
 WHERE p.user_id = $1
 ORDER BY payment_id DESC
@@ -385,13 +595,14 @@ SELECT * FROM payment;
 -- name: GetPaymentByID :one
 SELECT * FROM payment WHERE payment_id = $1;
 
--- name: CreatePaymentMethod :exec
+-- name: CreatePaymentMethod :one
 INSERT INTO payment_method (
     payment_method_name,
     created_at
 ) VALUES (
     $1, $2
-);
+)
+RETURNING *;
 
 -- name: GetAllPaymentMethod :many
 SELECT * FROM payment_method;
@@ -419,13 +630,14 @@ INSERT INTO transaction_history (
 SELECT * FROM transaction_history;
 
 
--- name: CreatePaymentStatus :exec
+-- name: CreatePaymentStatus :one
 INSERT INTO payment_status (
     payment_status_name,
     created_at
 ) VALUES (
     $1, $2
-);
+)
+RETURNING *;
 
 -- name: GetAllPaymentStatus :many
 SELECT * FROM payment_status;
@@ -442,3 +654,20 @@ SELECT * FROM payment_status WHERE payment_status_id = $1;
 
 
 
+
+-- name: GetMediaReferences :many
+SELECT thumbnail AS path FROM courses WHERE thumbnail IS NOT NULL
+UNION
+SELECT icon FROM categories
+UNION
+SELECT photo FROM "users" WHERE photo IS NOT NULL
+UNION
+SELECT avatar FROM teachers WHERE avatar IS NOT NULL
+UNION
+SELECT path_video FROM courses_video
+UNION
+SELECT proof FROM transaction_history WHERE proof IS NOT NULL
+UNION
+SELECT file_url FROM export_jobs WHERE file_url IS NOT NULL
+UNION
+SELECT file_url FROM invoices WHERE file_url IS NOT NULL;
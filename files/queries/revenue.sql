@@ -0,0 +1,152 @@
+-- name: PostSaleEntries :execrows
+-- a sale is a course of a paid payment, every payment_id when it is null
+WITH sales AS (
+    SELECT
+        th.transaction_history_id,
+        s.course_id,
+        c.user_id AS teacher_id,
+        th.total_amount::BIGINT AS amount,
+        th.total_amount::BIGINT * sqlc.arg(platform_share_bp)::BIGINT / 10000 AS platform_amount,
+        COALESCE(th.created_at, th.subcriptions_start_date, NOW())::TIMESTAMP AS sold_at
+    FROM payment p
+    JOIN subscriptions s ON s.payment_id = p.payment_id
+    JOIN transaction_history th ON th.subscription_id = s.subscription_id
+    JOIN courses c ON c.course_id = s.course_id
+    WHERE p.payment_status_id = sqlc.arg(paid_status)
+    AND (sqlc.narg(payment_id)::INTEGER IS NULL OR p.payment_id = sqlc.narg(payment_id))
+    AND th.is_paid IN ('yes', 'refunded')
+    AND th.deleted_at IS NULL
+    AND c.user_id IS NOT NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM ledger_entries le WHERE le.journal_id = 'sale:' || th.transaction_history_id
+    )
+)
+INSERT INTO ledger_entries (journal_id, account, user_id, course_id, transaction_history_id, debit, credit, created_at)
+SELECT 'sale:' || transaction_history_id, 'cash', NULL, course_id, transaction_history_id, amount, 0, sold_at FROM sales
+UNION ALL
+SELECT 'sale:' || transaction_history_id, 'teacher_payable', teacher_id, course_id, transaction_history_id, 0, amount - platform_amount, sold_at FROM sales
+UNION ALL
+SELECT 'sale:' || transaction_history_id, 'platform_revenue', NULL, course_id, transaction_history_id, 0, platform_amount, sold_at FROM sales
+ON CONFLICT (journal_id, account) DO NOTHING;
+
+-- name: PostRefundEntries :execrows
+INSERT INTO ledger_entries (journal_id, account, user_id, course_id, transaction_history_id, debit, credit, created_at)
+SELECT 'refund:' || le.transaction_history_id, le.account, le.user_id, le.course_id, le.transaction_history_id, le.credit, le.debit, COALESCE(th.updated_at, NOW())
+FROM ledger_entries le
+JOIN transaction_history th ON th.transaction_history_id = le.transaction_history_id
+WHERE le.journal_id = 'sale:' || le.transaction_history_id
+AND th.is_paid = 'refunded'
+AND (sqlc.narg(transaction_history_id)::INTEGER IS NULL OR le.transaction_history_id = sqlc.narg(transaction_history_id))
+ON CONFLICT (journal_id, account) DO NOTHING;
+
+-- name: RefundTransaction :one
+UPDATE transaction_history th SET is_paid = 'refunded', updated_at = $2
+FROM subscriptions s
+WHERE th.transaction_history_id = $1 AND th.is_paid = 'yes' AND th.deleted_at IS NULL
+AND s.subscription_id = th.subscription_id
+RETURNING th.transaction_history_id, th.total_amount, s.user_id, s.course_id, s.payment_id;
+
+-- name: GetTeacherRevenue :many
+SELECT
+    date_trunc(sqlc.arg(bucket)::TEXT, le.created_at)::TIMESTAMP AS bucket,
+    c.course_id,
+    c.course_name,
+    COUNT(*) FILTER (WHERE le.account = 'cash' AND le.journal_id LIKE 'sale:%') AS enrollments,
+    COALESCE(SUM(le.debit) FILTER (WHERE le.account = 'cash' AND le.journal_id LIKE 'sale:%'), 0)::BIGINT AS gross_revenue,
+    COALESCE(SUM(le.credit) FILTER (WHERE le.account = 'teacher_payable' AND le.journal_id LIKE 'sale:%'), 0)::BIGINT AS earnings,
+    COUNT(*) FILTER (WHERE le.account = 'cash' AND le.journal_id LIKE 'refund:%') AS refunds,
+    COALESCE(SUM(le.debit) FILTER (WHERE le.account = 'teacher_payable' AND le.journal_id LIKE 'refund:%'), 0)::BIGINT AS refunded_earnings
+FROM ledger_entries le
+JOIN courses c ON c.course_id = le.course_id
+WHERE c.user_id = sqlc.arg(user_id)
+AND le.created_at >= sqlc.arg(from_date)
+AND le.created_at < sqlc.arg(to_date)
+GROUP BY 1, c.course_id, c.course_name
+ORDER BY 1, c.course_id;
+
+-- name: GetTeacherBalance :one
+SELECT
+    COALESCE(SUM(credit) FILTER (WHERE journal_id LIKE 'sale:%'), 0)::BIGINT AS earned,
+    COALESCE(SUM(debit) FILTER (WHERE journal_id LIKE 'refund:%'), 0)::BIGINT AS refunded,
+    COALESCE(SUM(debit) FILTER (WHERE journal_id LIKE 'payout:%'), 0)::BIGINT AS paid_out,
+    COALESCE(SUM(credit) - SUM(debit), 0)::BIGINT AS balance
+FROM ledger_entries
+WHERE account = 'teacher_payable' AND user_id = $1;
+
+-- name: CreatePayoutBatch :one
+INSERT INTO payout_batches (
+    status,
+    cutoff_at,
+    created_by,
+    created_at
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: CreateBatchPayouts :many
+-- earnings are only paid once older than the cutoff, refunds and earlier payouts always count
+INSERT INTO payouts (batch_id, user_id, amount, created_at)
+SELECT sqlc.arg(batch_id), b.user_id, b.balance, sqlc.arg(created_at)
+FROM (
+    SELECT
+        le.user_id,
+        (COALESCE(SUM(le.credit) FILTER (WHERE le.created_at <= sqlc.arg(cutoff_at)), 0) - SUM(le.debit))::BIGINT AS balance
+    FROM ledger_entries le
+    WHERE le.account = 'teacher_payable' AND le.user_id IS NOT NULL
+    GROUP BY le.user_id
+) b
+WHERE b.balance >= sqlc.arg(min_amount)::BIGINT
+RETURNING *;
+
+-- name: PostPayoutEntries :execrows
+INSERT INTO ledger_entries (journal_id, account, user_id, payout_id, debit, credit, created_at)
+SELECT 'payout:' || p.payout_id, 'teacher_payable', p.user_id, p.payout_id, p.amount, 0, p.created_at FROM payouts p WHERE p.batch_id = $1
+UNION ALL
+SELECT 'payout:' || p.payout_id, 'payout_clearing', p.user_id, p.payout_id, 0, p.amount, p.created_at FROM payouts p WHERE p.batch_id = $1
+ON CONFLICT (journal_id, account) DO NOTHING;
+
+-- name: PostPayoutPaidEntries :execrows
+INSERT INTO ledger_entries (journal_id, account, user_id, payout_id, debit, credit, created_at)
+SELECT 'payout-paid:' || p.payout_id, 'payout_clearing', p.user_id, p.payout_id, p.amount, 0, sqlc.arg(paid_at)::TIMESTAMP FROM payouts p WHERE p.batch_id = sqlc.arg(batch_id)
+UNION ALL
+SELECT 'payout-paid:' || p.payout_id, 'cash', p.user_id, p.payout_id, 0, p.amount, sqlc.arg(paid_at)::TIMESTAMP FROM payouts p WHERE p.batch_id = sqlc.arg(batch_id)
+ON CONFLICT (journal_id, account) DO NOTHING;
+
+-- name: UpdatePayoutBatchTotal :exec
+UPDATE payout_batches pb SET total_amount = (SELECT COALESCE(SUM(p.amount), 0) FROM payouts p WHERE p.batch_id = pb.batch_id)
+WHERE pb.batch_id = $1;
+
+-- name: MarkPayoutBatchPaid :execrows
+UPDATE payout_batches SET status = 'PAID', reference = $2, paid_by = $3, paid_at = $4
+WHERE batch_id = $1 AND status = 'PENDING';
+
+-- name: GetPayoutBatches :many
+SELECT * FROM payout_batches ORDER BY created_at DESC;
+
+-- name: GetPayoutBatchByID :one
+SELECT * FROM payout_batches WHERE batch_id = $1;
+
+-- name: GetPayoutsByBatch :many
+SELECT p.payout_id, p.batch_id, p.user_id, u.nama, u.email, p.amount, p.created_at
+FROM payouts p
+LEFT JOIN "users" u ON u.user_id = p.user_id
+WHERE p.batch_id = $1
+ORDER BY p.amount DESC;
+
+-- name: GetPayoutsByUser :many
+SELECT p.payout_id, p.batch_id, pb.status, p.amount, p.created_at, pb.paid_at, pb.reference
+FROM payouts p
+JOIN payout_batches pb ON pb.batch_id = p.batch_id
+WHERE p.user_id = $1
+ORDER BY p.created_at DESC;
+
+-- name: GetLedgerTrialBalance :many
+SELECT account, SUM(debit)::BIGINT AS debit, SUM(credit)::BIGINT AS credit
+FROM ledger_entries
+GROUP BY account
+ORDER BY account;
+
+-- name: LockPayouts :exec
+-- serialize payout batch creation so a balance is never paid twice
+SELECT pg_advisory_xact_lock(hashtext('payout_batches'));
@@ -0,0 +1,155 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/cartreminder"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/notify"
+	"github.com/online-bnsp/backend/util/pricealert"
+	queue "github.com/online-bnsp/backend/util/queue"
+	"github.com/online-bnsp/backend/util/tracing"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	schedules  = map[string]string{}
+	paymentTTL = 24 * time.Hour
+	cartTTL    = 90 * 24 * time.Hour
+	exportTTL  = time.Hour
+)
+
+// SetConfig override the cron expression of the jobs by name, an empty expression only
+// disables the schedule. Pending payments expire after paymentTTL, open carts are
+// purged after cartTTL and exports running longer than exportTTL are failed
+func SetConfig(schedule map[string]string, paymentExpiry, cartExpiry, exportExpiry time.Duration) {
+	schedules = schedule
+	if paymentExpiry > 0 {
+		paymentTTL = paymentExpiry
+	}
+	if cartExpiry > 0 {
+		cartTTL = cartExpiry
+	}
+	if exportExpiry > 0 {
+		exportTTL = exportExpiry
+	}
+}
+
+// Job is background work that runs outside of a request, Schedule is a cron
+// expression and jobs without one are only run manually
+type Job struct {
+	Name        string
+	Description string
+	Schedule    string
+	Run         func(ctx context.Context) error
+}
+
+type Jobs struct {
+	list []Job
+}
+
+func New(db *sql.DB, rdb *redis.Client, producer queue.Producer) *Jobs {
+	dbGenerated := repo.New(tracing.WrapDB(db))
+	publisher := notify.NewPublisher(producer)
+	alerter := pricealert.New(dbGenerated, inbox.New(dbGenerated, rdb), publisher)
+	reminder := cartreminder.New(dbGenerated, publisher)
+
+	list := []Job{
+		{
+			Name:        "price-alerts",
+			Description: "Alert wishlists of courses whose sale started or ended",
+			Schedule:    "*/5 * * * *",
+			Run: func(ctx context.Context) error {
+				n, err := alerter.Check(ctx, 0)
+				logger.FromContext(ctx).Info().Int64("alerts", int64(n)).Msg("price alerts created")
+				return err
+			},
+		},
+		{
+			Name:        "price-digest",
+			Description: "Send the daily digest of wishlist price drops",
+			Schedule:    "0 8 * * *",
+			Run: func(ctx context.Context) error {
+				n, err := alerter.SendDigest(ctx)
+				logger.FromContext(ctx).Info().Int64("digests", int64(n)).Msg("price digests queued")
+				return err
+			},
+		},
+		{
+			Name:        "cart-reminders",
+			Description: "Remind the owners of idle carts to check out",
+			Schedule:    "0 * * * *",
+			Run: func(ctx context.Context) error {
+				n, err := reminder.Send(ctx)
+				logger.FromContext(ctx).Info().Int64("carts", int64(n)).Msg("carts reminded")
+				return err
+			},
+		},
+		{
+			Name:        "expire-payments",
+			Description: "Fail the payments pending for too long",
+			Schedule:    "*/15 * * * *",
+			Run: func(ctx context.Context) error {
+				n, err := dbGenerated.ExpirePendingPayments(ctx, repo.ExpirePendingPaymentsParams{
+					FailedStatus:  util.SqlInt32(constant.PaymentFailed),
+					PendingStatus: util.SqlInt32(constant.PaymentPending),
+					CreatedBefore: util.SqlTime(time.Now().Add(-paymentTTL)),
+				})
+				logger.FromContext(ctx).Info().Int64("payments", int64(n)).Msg("payments expired")
+				return err
+			},
+		},
+		{
+			Name:        "purge-carts",
+			Description: "Drop the cart items nobody touched for months",
+			Schedule:    "30 3 * * *",
+			Run: func(ctx context.Context) error {
+				n, err := dbGenerated.PurgeStaleCarts(ctx, util.SqlTime(time.Now().Add(-cartTTL)))
+				logger.FromContext(ctx).Info().Int64("items", int64(n)).Msg("cart items purged")
+				return err
+			},
+		},
+		{
+			Name:        "reap-exports",
+			Description: "Fail the exports left running by a consumer that died",
+			Schedule:    "*/10 * * * *",
+			Run: func(ctx context.Context) error {
+				now := time.Now()
+				n, err := dbGenerated.FailStaleExportJobs(ctx, repo.FailStaleExportJobsParams{
+					Error:         util.SqlString("export did not finish within " + exportTTL.String()),
+					FinishedAt:    util.SqlTime(now),
+					StartedBefore: util.SqlTime(now.Add(-exportTTL)),
+				})
+				logger.FromContext(ctx).Info().Int64("exports", int64(n)).Msg("stale exports failed")
+				return err
+			},
+		},
+	}
+
+	for i, job := range list {
+		if spec, ok := schedules[job.Name]; ok {
+			list[i].Schedule = spec
+		}
+	}
+
+	return &Jobs{list}
+}
+
+func (j *Jobs) List() []Job {
+	return j.list
+}
+
+func (j *Jobs) Find(name string) (Job, bool) {
+	for _, job := range j.list {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
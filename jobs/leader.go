@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const leaderKey = "scheduler:leader"
+
+// Leader elects one scheduler replica through a redis key that expires when its holder
+// stops renewing it, the other replicas take over within ttl
+type Leader struct {
+	rdb    *redis.Client
+	ttl    time.Duration
+	token  string
+	leader atomic.Bool
+}
+
+func NewLeader(rdb *redis.Client, ttl time.Duration) *Leader {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &Leader{rdb: rdb, ttl: ttl}
+}
+
+func (l *Leader) IsLeader() bool {
+	return l.leader.Load()
+}
+
+// Run campaign until ctx is done, then step down so another replica takes over right away
+func (l *Leader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		l.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			if l.IsLeader() {
+				release(context.Background(), l.rdb, leaderKey, l.token)
+				l.leader.Store(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Leader) campaign(ctx context.Context) {
+	if l.IsLeader() {
+		ok, err := extend(ctx, l.rdb, leaderKey, l.token, l.ttl)
+		if err != nil {
+			// without redis nobody can tell who leads, stepping down avoids running twice
+			logger.Err(ctx, err).Msg("error renewing scheduler leadership")
+		}
+		if !ok {
+			logger.FromContext(ctx).Warn().Msg("scheduler leadership lost")
+			l.leader.Store(false)
+		}
+		return
+	}
+
+	token, err := acquire(ctx, l.rdb, leaderKey, l.ttl)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error acquiring scheduler leadership")
+		return
+	}
+	if token != "" {
+		logger.FromContext(ctx).Info().Msg("scheduler leadership acquired")
+		l.token = token
+		l.leader.Store(true)
+	}
+}
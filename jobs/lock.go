@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// the lock is only released or extended by its holder
+var (
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// acquire take the key for ttl, the returned token is empty when someone else holds it
+func acquire(ctx context.Context, rdb *redis.Client, key string, ttl time.Duration) (string, error) {
+	token := uuid.NewString()
+	ok, err := rdb.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+// extend keep the key for another ttl, false when it was lost to someone else
+func extend(ctx context.Context, rdb *redis.Client, key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendScript.Run(ctx, rdb, []string{key}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func release(ctx context.Context, rdb *redis.Client, key, token string) error {
+	return releaseScript.Run(ctx, rdb, []string{key}, token).Err()
+}
@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrRunning = errors.New("job is already running")
+
+// lockTTL bounds how long a crashed run keeps its job locked
+const lockTTL = time.Hour
+
+// Runner runs a job at most once at a time across every replica and records the run
+type Runner struct {
+	db      *repo.Queries
+	rdb     *redis.Client
+	started sync.WaitGroup
+}
+
+func NewRunner(db *repo.Queries, rdb *redis.Client) *Runner {
+	return &Runner{db: db, rdb: rdb}
+}
+
+func lockKey(name string) string {
+	return "job:lock:" + name
+}
+
+// Run the job and wait for it, userID is 0 unless a user triggered it
+func (r *Runner) Run(ctx context.Context, job Job, trigger string, userID int32) (repo.JobRun, error) {
+	run, token, err := r.begin(ctx, job, trigger, userID)
+	if err != nil {
+		return run, err
+	}
+	return r.execute(ctx, job, run, token)
+}
+
+// Start the job in the background, the returned run is still running
+func (r *Runner) Start(ctx context.Context, job Job, trigger string, userID int32) (repo.JobRun, error) {
+	run, token, err := r.begin(ctx, job, trigger, userID)
+	if err != nil {
+		return run, err
+	}
+
+	// outlives the request, the run is still traced as part of it
+	bg := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+
+	r.started.Add(1)
+	go func() {
+		defer r.started.Done()
+		if _, err := r.execute(bg, job, run, token); err != nil {
+			logger.Err(bg, err).Str("job", job.Name).Msg("job failed")
+		}
+	}()
+	return run, nil
+}
+
+// Wait for the runs started in the background, or until ctx is done
+func (r *Runner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.started.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) begin(ctx context.Context, job Job, trigger string, userID int32) (repo.JobRun, string, error) {
+	token, err := acquire(ctx, r.rdb, lockKey(job.Name), lockTTL)
+	if err != nil {
+		return repo.JobRun{}, "", err
+	}
+	if token == "" {
+		return repo.JobRun{}, "", ErrRunning
+	}
+
+	run, err := r.db.CreateJobRun(ctx, repo.CreateJobRunParams{
+		JobName:     job.Name,
+		Trigger:     trigger,
+		Status:      constant.JobRunning,
+		TriggeredBy: sql.NullInt32{Int32: userID, Valid: userID != 0},
+		StartedAt:   time.Now(),
+	})
+	if err != nil {
+		release(ctx, r.rdb, lockKey(job.Name), token)
+		return run, "", err
+	}
+	return run, token, nil
+}
+
+func (r *Runner) execute(ctx context.Context, job Job, run repo.JobRun, token string) (repo.JobRun, error) {
+	defer release(context.Background(), r.rdb, lockKey(job.Name), token)
+
+	l := logger.FromContext(ctx).With().Str("job", job.Name).Int32("run_id", run.RunID).Logger()
+	ctx = logger.WithContext(ctx, &l)
+
+	ctx, span := tracing.Tracer().Start(ctx, "job "+job.Name, trace.WithAttributes(
+		attribute.String("job.name", job.Name),
+		attribute.String("job.trigger", run.Trigger),
+		attribute.Int("job.run_id", int(run.RunID)),
+	))
+	defer span.End()
+
+	jobErr := safeRun(ctx, job)
+	if jobErr != nil {
+		span.RecordError(jobErr)
+		span.SetStatus(codes.Error, jobErr.Error())
+	}
+
+	run.Status = constant.JobSuccess
+	run.FinishedAt = util.SqlTime(time.Now())
+	if jobErr != nil {
+		run.Status = constant.JobFailed
+		run.Error = util.SqlString(jobErr.Error())
+	}
+
+	// the run is finished even when the request that started it is gone
+	err := r.db.FinishJobRun(context.Background(), repo.FinishJobRunParams{
+		RunID:      run.RunID,
+		Status:     run.Status,
+		Error:      run.Error,
+		FinishedAt: run.FinishedAt,
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error storing job run")
+	}
+
+	return run, jobErr
+}
+
+// safeRun turn a panic of the job into a failed run
+func safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.Run(ctx)
+}
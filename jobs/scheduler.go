@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/robfig/cron/v3"
+)
+
+// Scheduler fires the jobs on their cron schedule, only on the leader replica
+type Scheduler struct {
+	jobs   *Jobs
+	runner *Runner
+	leader *Leader
+	cron   *cron.Cron
+}
+
+func NewScheduler(jobs *Jobs, runner *Runner, leader *Leader) (*Scheduler, error) {
+	s := &Scheduler{jobs, runner, leader, cron.New()}
+
+	for _, job := range jobs.List() {
+		if job.Schedule == "" {
+			continue
+		}
+
+		job := job
+		if _, err := s.cron.AddFunc(job.Schedule, func() { s.fire(job) }); err != nil {
+			return nil, fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Run the scheduler until ctx is done, running jobs are waited for
+func (s *Scheduler) Run(ctx context.Context) {
+	go s.leader.Run(ctx)
+	s.cron.Start()
+
+	<-ctx.Done()
+	<-s.cron.Stop().Done()
+}
+
+func (s *Scheduler) fire(job Job) {
+	if !s.leader.IsLeader() {
+		return
+	}
+
+	ctx := context.Background()
+	_, err := s.runner.Run(ctx, job, constant.JobTriggerSchedule, 0)
+	if err == ErrRunning {
+		logger.FromContext(ctx).Info().Str("job", job.Name).Msg("job skipped, the previous run is not finished")
+	} else if err != nil {
+		logger.Err(ctx, err).Str("job", job.Name).Msg("job failed")
+	}
+}
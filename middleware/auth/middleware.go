@@ -8,7 +8,8 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
 )
 
 var refreshSecret = []byte("your_refresh_secret_key")
@@ -69,13 +70,13 @@ func Middleware(next http.Handler) http.Handler {
 		authHeader := r.Header.Get("Authorization")
 
 		if authHeader == "" {
-			util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Unauthorized!", nil).WriteResponse(w, r)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized!"))
 			return
 		}
 
 		// Check if the header starts with "Bearer"
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			util.NewResponse(http.StatusBadRequest, http.StatusBadRequest, "Invalid authorization format", nil).WriteResponse(w, r)
+			apperr.WriteError(w, r, apperr.Validation("Invalid authorization format"))
 			return
 		}
 
@@ -83,7 +84,7 @@ func Middleware(next http.Handler) http.Handler {
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		identity, err := DecodeJWT(token)
 		if err != nil {
-			util.NewResponse(http.StatusUnauthorized, http.StatusUnauthorized, "Unauthorized!", nil).WriteResponse(w, r)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized!"))
 			return
 		}
 
@@ -125,17 +126,17 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		cookie, err := r.Cookie("token")
 		if err != nil {
 			if err == http.ErrNoCookie {
-				http.Error(w, "Unauthorized - No token provided", http.StatusUnauthorized)
+				apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - No token provided"))
 				return
 			}
-			http.Error(w, "Unauthorized - Unable to retrieve token", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - Unable to retrieve token"))
 			return
 		}
 
 		// Ambil token dari nilai cookie
 		tokenString := cookie.Value
 		if tokenString == "" {
-			http.Error(w, "Unauthorized - Invalid token format", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - Invalid token format"))
 			return
 		}
 
@@ -149,33 +150,34 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return jwtKey, nil
 		})
 		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized - Invalid token", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - Invalid token"))
 			return
 		}
 
 		// Ambil klaim dari token
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			http.Error(w, "Unauthorized - Invalid claims", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - Invalid claims"))
 			return
 		}
 
 		// Ambil role dari klaim dan simpan ke context
 		role, ok := claims["role"].(string)
 		if !ok {
-			http.Error(w, "Unauthorized - Role not found", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - Role not found"))
 			return
 		}
 
 		user_id, ok := claims["user_id"].(float64)
 		if !ok {
-			http.Error(w, "Unauthorized - user_id not found", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized - user_id not found"))
 			return
 		}
 
 		// Simpan role ke dalam context
 		ctx := context.WithValue(r.Context(), ContextKeyRole, role)
 		ctx = context.WithValue(ctx, ContextUserID, int32(user_id))
+		ctx = logger.WithUser(ctx, int32(user_id), role)
 		r = r.WithContext(ctx)
 
 		// Lanjutkan ke handler berikutnya
@@ -183,29 +185,6 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Middleware role
-func RequireRole(allowedRoles ...string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userRole := r.Context().Value("role")
-			if userRole == nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if userRole is present in allowedRoles slice
-			for _, allowedRole := range allowedRoles {
-				if userRole == allowedRole {
-					next.ServeHTTP(w, r)
-					return // Exit the handler function after successful role check
-				}
-			}
-
-			http.Error(w, "Forbidden", http.StatusForbidden)
-		})
-	}
-}
-
 func ExtractTokenClaims(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("token")
@@ -221,12 +200,13 @@ func ExtractTokenClaims(next http.Handler) http.Handler {
 			return jwtKey, nil
 		})
 		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized"))
 			return
 		}
 
 		// Add claims to context
 		ctx := context.WithValue(r.Context(), "role", claims.Role)
+		ctx = context.WithValue(ctx, "user_id", claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// permissions granted by the roles, named resource:action. The :any suffix extends an action to
+// the resources of the other users
+const (
+	PermProfileWrite         = "profile:write"
+	PermCourseStudy          = "course:study"
+	PermCartWrite            = "cart:write"
+	PermWishlistWrite        = "wishlist:write"
+	PermPaymentCreate        = "payment:create"
+	PermPaymentRead          = "payment:read"
+	PermPaymentReadAny       = "payment:read:any"
+	PermPaymentWriteAny      = "payment:write:any"
+	PermCourseWrite          = "course:write"
+	PermCourseWriteAny       = "course:write:any"
+	PermCourseReview         = "course:review"
+	PermVideoWrite           = "video:write"
+	PermVideoWriteAny        = "video:write:any"
+	PermCategoryWrite        = "category:write"
+	PermTrashManage          = "trash:manage"
+	PermTeacherProfile       = "teacher:profile"
+	PermTeacherWriteAny      = "teacher:write:any"
+	PermUserReadAny          = "user:read:any"
+	PermRevenueManage        = "revenue:manage"
+	PermAnalyticsRead        = "analytics:read"
+	PermReportExport         = "report:export"
+	PermNotificationWriteAny = "notification:write:any"
+	PermJobRun               = "job:run"
+	PermRoleManage           = "role:manage"
+	PermAuditRead            = "audit:read"
+)
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions is the catalog a role can be granted from
+var Permissions = []PermissionInfo{
+	{PermProfileWrite, "Update the own account"},
+	{PermCourseStudy, "Open and rate the bought courses"},
+	{PermCartWrite, "Manage the own cart"},
+	{PermWishlistWrite, "Manage the own wishlist"},
+	{PermPaymentCreate, "Pay for courses and subscriptions"},
+	{PermPaymentRead, "Download the invoices of the own payments"},
+	{PermPaymentReadAny, "List every payment, subscription and invoice"},
+	{PermPaymentWriteAny, "Mark payments paid and refund them"},
+	{PermCourseWrite, "Create courses and manage the own ones"},
+	{PermCourseWriteAny, "Manage the courses of every teacher"},
+	{PermCourseReview, "Approve and reject submitted courses"},
+	{PermVideoWrite, "Manage the videos of the own courses"},
+	{PermVideoWriteAny, "Manage the videos of every course"},
+	{PermCategoryWrite, "Manage the categories"},
+	{PermTrashManage, "Restore and purge deleted items"},
+	{PermTeacherProfile, "Manage the own teacher profile, dashboard and payouts"},
+	{PermTeacherWriteAny, "Manage the teacher profiles"},
+	{PermUserReadAny, "List and view every account"},
+	{PermRevenueManage, "Manage payout batches and read the ledger"},
+	{PermAnalyticsRead, "Read the analytics"},
+	{PermReportExport, "Export spreadsheets"},
+	{PermNotificationWriteAny, "Send notifications and read their delivery log"},
+	{PermJobRun, "List and run background jobs"},
+	{PermRoleManage, "Manage the roles and assign them to users"},
+	{PermAuditRead, "Search the audit log of the changes"},
+}
+
+// KnownPermission report whether p is in the catalog
+func KnownPermission(p string) bool {
+	for _, info := range Permissions {
+		if info.Name == p {
+			return true
+		}
+	}
+	return false
+}
+
+var permissionCacheTTL = time.Minute
+
+// SetPermissionConfig set how long the permissions of a user are cached, a role change made on
+// another replica is seen after at most ttl
+func SetPermissionConfig(ttl time.Duration) {
+	if ttl > 0 {
+		permissionCacheTTL = ttl
+	}
+}
+
+// PermissionStore load the role of a user joined with the permissions of the role
+type PermissionStore interface {
+	GetUserPermissions(ctx context.Context, userID int32) ([]repo.GetUserPermissionsRow, error)
+}
+
+type permissionsContextKey struct{}
+
+type grant struct {
+	perms   map[string]bool
+	expires time.Time
+}
+
+// cached users are swept of the expired entries past this size
+const maxCachedUsers = 10000
+
+// Authorizer check the permissions of the logged in user. The role is read from the database
+// rather than the token, so a changed role applies without signing in again
+type Authorizer struct {
+	db  PermissionStore
+	now func() time.Time
+
+	mu    sync.Mutex
+	users map[int32]grant
+}
+
+func NewAuthorizer(db PermissionStore) *Authorizer {
+	return &Authorizer{db: db, now: time.Now, users: map[int32]grant{}}
+}
+
+// SetClock replace the clock of the cache expiry, for tests
+func (a *Authorizer) SetClock(now func() time.Time) {
+	a.now = now
+}
+
+// Invalidate drop the cached permissions, called after a role or an assignment changed
+func (a *Authorizer) Invalidate() {
+	a.mu.Lock()
+	a.users = map[int32]grant{}
+	a.mu.Unlock()
+}
+
+func (a *Authorizer) permissions(ctx context.Context, userID int32) (map[string]bool, error) {
+	now := a.now()
+	a.mu.Lock()
+	g, ok := a.users[userID]
+	a.mu.Unlock()
+	if ok && now.Before(g.expires) {
+		return g.perms, nil
+	}
+
+	rows, err := a.db.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	perms := map[string]bool{}
+	for _, row := range rows {
+		if row.Permission.Valid {
+			perms[row.Permission.String] = true
+		}
+	}
+
+	a.mu.Lock()
+	if len(a.users) >= maxCachedUsers {
+		for id, g := range a.users {
+			if !now.Before(g.expires) {
+				delete(a.users, id)
+			}
+		}
+	}
+	a.users[userID] = grant{perms: perms, expires: now.Add(permissionCacheTTL)}
+	a.mu.Unlock()
+	return perms, nil
+}
+
+// RequirePermission let the users holding one of perms through, the permissions are kept in the
+// context for HasPermission and CanAccess
+func (a *Authorizer) RequirePermission(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			userID, ok := ctx.Value("user_id").(int32)
+			if !ok {
+				apperr.WriteError(w, r, apperr.Unauthorized("Unauthorized"))
+				return
+			}
+
+			granted, err := a.permissions(ctx, userID)
+			if err != nil {
+				logger.Err(ctx, err).Msg("error loading permissions")
+				apperr.WriteError(w, r, apperr.Internal("Try again later"))
+				return
+			}
+
+			for _, p := range perms {
+				if granted[p] {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, permissionsContextKey{}, granted)))
+					return
+				}
+			}
+			apperr.WriteError(w, r, apperr.Forbidden("Forbidden"))
+		})
+	}
+}
+
+// HasPermission report whether the logged in user holds perm, it is false outside of the routes
+// guarded by RequirePermission
+func HasPermission(ctx context.Context, perm string) bool {
+	granted, _ := ctx.Value(permissionsContextKey{}).(map[string]bool)
+	return granted[perm]
+}
+
+// CanAccess report whether the logged in user owns the resource of ownerID, or may act on the
+// resources of every user with anyPerm
+func CanAccess(ctx context.Context, ownerID int32, anyPerm string) bool {
+	if userID, ok := ctx.Value("user_id").(int32); ok && ownerID != 0 && userID == ownerID {
+		return true
+	}
+	return HasPermission(ctx, anyPerm)
+}
@@ -0,0 +1,134 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/online-bnsp/backend/middleware/auth"
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+type store struct {
+	roles map[int32]string
+	perms map[string][]string
+	loads int
+	err   error
+}
+
+func (s *store) GetUserPermissions(ctx context.Context, userID int32) ([]repo.GetUserPermissionsRow, error) {
+	s.loads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	role, ok := s.roles[userID]
+	if !ok {
+		return nil, nil
+	}
+	rows := []repo.GetUserPermissionsRow{{Role: role}}
+	for _, p := range s.perms[role] {
+		rows = append(rows, repo.GetUserPermissionsRow{Role: role, Permission: sql.NullString{String: p, Valid: true}})
+	}
+	return rows, nil
+}
+
+func serve(a *auth.Authorizer, userID int32, perms ...string) int {
+	h := a.RequirePermission(perms...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if userID != 0 {
+		r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	s := &store{
+		roles: map[int32]string{1: "student", 2: "admin"},
+		perms: map[string][]string{
+			"student": {auth.PermCartWrite},
+			"admin":   {auth.PermCourseWriteAny, auth.PermRoleManage},
+		},
+	}
+	a := auth.NewAuthorizer(s)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.SetClock(func() time.Time { return now })
+
+	for _, tc := range []struct {
+		userID int32
+		perms  []string
+		want   int
+	}{
+		{0, []string{auth.PermCartWrite}, http.StatusUnauthorized},
+		{1, []string{auth.PermCartWrite}, http.StatusOK},
+		{1, []string{auth.PermRoleManage}, http.StatusForbidden},
+		{2, []string{auth.PermCartWrite, auth.PermRoleManage}, http.StatusOK},
+		{3, []string{auth.PermCartWrite}, http.StatusForbidden}, // deleted account
+	} {
+		if got := serve(a, tc.userID, tc.perms...); got != tc.want {
+			t.Errorf("user %d %v: got %d, want %d", tc.userID, tc.perms, got, tc.want)
+		}
+	}
+	if s.loads != 3 {
+		t.Errorf("loaded %d times, want once per user", s.loads)
+	}
+
+	// a changed role applies after the ttl, or right away once invalidated
+	s.perms["student"] = nil
+	if serve(a, 1, auth.PermCartWrite) != http.StatusOK {
+		t.Error("cached permissions were not used")
+	}
+	now = now.Add(time.Minute)
+	if serve(a, 1, auth.PermCartWrite) != http.StatusForbidden {
+		t.Error("permissions outlived their ttl")
+	}
+	s.perms["student"] = []string{auth.PermCartWrite}
+	a.Invalidate()
+	if serve(a, 1, auth.PermCartWrite) != http.StatusOK {
+		t.Error("permissions were not reloaded after the invalidation")
+	}
+
+	a.Invalidate()
+	s.err = errors.New("connection refused")
+	if got := serve(a, 1, auth.PermCartWrite); got != http.StatusInternalServerError {
+		t.Errorf("got %d", got)
+	}
+}
+
+func TestCanAccess(t *testing.T) {
+	s := &store{
+		roles: map[int32]string{1: "teacher", 2: "admin"},
+		perms: map[string][]string{
+			"teacher": {auth.PermCourseWrite},
+			"admin":   {auth.PermCourseWrite, auth.PermCourseWriteAny},
+		},
+	}
+	a := auth.NewAuthorizer(s)
+
+	check := func(userID, ownerID int32) (ok bool) {
+		h := a.RequirePermission(auth.PermCourseWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok = auth.CanAccess(r.Context(), ownerID, auth.PermCourseWriteAny)
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), r.WithContext(context.WithValue(r.Context(), "user_id", userID)))
+		return ok
+	}
+
+	if !check(1, 1) {
+		t.Error("the owner was denied")
+	}
+	if check(1, 7) || check(1, 0) {
+		t.Error("a course of another teacher was allowed")
+	}
+	if !check(2, 7) {
+		t.Error("course:write:any was denied")
+	}
+	if auth.HasPermission(context.Background(), auth.PermCourseWriteAny) {
+		t.Error("permission granted outside of RequirePermission")
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/tracing"
+	"github.com/rs/zerolog"
+)
+
+// RequestLogger attach a child of the default logger to the request context, with the request id,
+// the route and the trace, auth.AuthMiddleware derives a child with the user from it. Then one
+// access line is logged per request, with the redacted headers for the server errors. A panic of
+// the handler is logged with its stack and answered with a 500
+func RequestLogger(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		ctx := r.Context()
+
+		c := logger.FromContext(ctx).With().
+			Str("request_id", requestID(r)).
+			Str("method", r.Method).
+			Str("path", r.URL.Path)
+		if traceID, _ := tracing.IDs(ctx); traceID != "" {
+			c = c.Str("trace_id", traceID)
+		}
+		l := c.Logger()
+		// the pattern is only complete once the router matched the request
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			l = l.Hook(zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, msg string) {
+				if route := rctx.RoutePattern(); route != "" {
+					e.Str("route", route)
+				}
+			}))
+		}
+		ctx = logger.WithContext(ctx, &l)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				l.Error().
+					Str("panic", fmt.Sprint(p)).
+					Bytes("stack", debug.Stack()).
+					Msg("handler panicked")
+				if ww.Status() == 0 {
+					apperr.WriteError(ww, r, apperr.Internal("Internal server error"))
+				}
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			e := l.Info()
+			if status >= http.StatusInternalServerError {
+				e = l.Error().Interface("headers", logger.RedactHeaders(r.Header))
+			}
+			e.Str("query", logger.RedactQuery(r.URL.Query())).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("latency", time.Since(t)).
+				Str("remote_addr", r.RemoteAddr).
+				Str("user_agent", r.UserAgent()).
+				Msg("request")
+		}()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// requestID is the id set by BirthTime, or the one sent by the client
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(middleware.RequestIDKey).(uuid.UUID); ok {
+		return id.String()
+	}
+	return r.Header.Get(middleware.RequestIDHeader)
+}
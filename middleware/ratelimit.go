@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/online-bnsp/backend/util/apperr"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/ratelimit"
+)
+
+// KeyFunc name the bucket a request is counted in
+type KeyFunc func(r *http.Request) string
+
+// ByIP count the requests per caller address
+func ByIP(r *http.Request) string {
+	return "ip:" + ratelimit.ClientIP(r)
+}
+
+// ByUser count the requests per logged in user, and per address before the login
+func ByUser(r *http.Request) string {
+	if userID, ok := r.Context().Value("user_id").(int32); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return ByIP(r)
+}
+
+// ByRoute count the requests per route, then per key when it is set. The route pattern is only
+// complete in the middlewares of the route itself, mount it with With
+func ByRoute(key KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		route := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		route = r.Method + " " + route
+		if key == nil {
+			return "route:" + route
+		}
+		return "route:" + route + ":" + key(r)
+	}
+}
+
+// RateLimit answer 429 to the callers over p, every response carries the RateLimit-* headers of
+// the policy. Allowlisted callers are not counted, a nil limiter disables it and requests are let
+// through when redis fails
+func RateLimit(l *ratelimit.Limiter, p ratelimit.Policy, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || p.Limit <= 0 {
+			return next
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if l.Allowlisted(ratelimit.ClientIP(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := l.Allow(r.Context(), p, key(r))
+			if err != nil {
+				logger.Warn(r.Context(), err).Str("policy", p.Name).Msg("error checking rate limit")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds())))
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				TooManyRequests(w, r, res.Reset)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// TooManyRequests answer 429 with the Retry-After header
+func TooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", seconds(retryAfter))
+	apperr.WriteError(w, r, apperr.TooManyRequests("Too many requests, try again in "+seconds(retryAfter)+" seconds"))
+}
+
+// seconds round d up, a client retrying after a rounded down delay would be denied again
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,213 @@
+package apperr
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+// Kind classifies an error, it decides the http status of the response
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindForbidden
+	KindUnauthorized
+	KindTooManyRequests
+)
+
+// machine readable codes of the kinds, clients branch on them instead of the message
+const (
+	CodeInternal     = "internal"
+	CodeValidation   = "validation_failed"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeForbidden    = "forbidden"
+	CodeUnauthorized = "unauthorized"
+	CodeRateLimited  = "rate_limited"
+)
+
+var kinds = map[Kind]struct {
+	status int
+	code   string
+}{
+	KindInternal:        {http.StatusInternalServerError, CodeInternal},
+	KindValidation:      {http.StatusBadRequest, CodeValidation},
+	KindNotFound:        {http.StatusNotFound, CodeNotFound},
+	KindConflict:        {http.StatusConflict, CodeConflict},
+	KindForbidden:       {http.StatusForbidden, CodeForbidden},
+	KindUnauthorized:    {http.StatusUnauthorized, CodeUnauthorized},
+	KindTooManyRequests: {http.StatusTooManyRequests, CodeRateLimited},
+}
+
+// FieldError is an invalid field of the request, Field is its json name
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an error meant for the client. Message is shown as is, the wrapped cause never is
+type Error struct {
+	Kind    Kind
+	Code    string // defaults to the code of the kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status is the http status of the response
+func (e *Error) Status() int {
+	return kinds[e.Kind].status
+}
+
+// ErrorCode returns Code, or the code of the kind when it is not set
+func (e *Error) ErrorCode() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return kinds[e.Kind].code
+}
+
+// Wrap set the cause of the error, it is logged but not sent to the client
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// WithCode set a code more specific than the one of the kind, e.g. "email_taken"
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+func newError(kind Kind, msg string) *Error {
+	return &Error{Kind: kind, Message: msg}
+}
+
+func Internal(msg string) *Error     { return newError(KindInternal, msg) }
+func Validation(msg string) *Error   { return newError(KindValidation, msg) }
+func NotFound(msg string) *Error     { return newError(KindNotFound, msg) }
+func Conflict(msg string) *Error     { return newError(KindConflict, msg) }
+func Forbidden(msg string) *Error    { return newError(KindForbidden, msg) }
+func Unauthorized(msg string) *Error { return newError(KindUnauthorized, msg) }
+
+// TooManyRequests is answered to rate limited callers, set their Retry-After header first
+func TooManyRequests(msg string) *Error { return newError(KindTooManyRequests, msg) }
+
+// FromValidation translate the errors of validator.Struct into a validation error listing
+// every invalid field
+func FromValidation(err error) *Error {
+	e := Validation("Invalid input").Wrap(err)
+
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) {
+		return e
+	}
+	for _, f := range fields {
+		e.Fields = append(e.Fields, FieldError{
+			Field:   f.Field(),
+			Rule:    f.Tag(),
+			Message: fieldMessage(f),
+		})
+	}
+	return e
+}
+
+func fieldMessage(f validator.FieldError) string {
+	switch f.Tag() {
+	case "required":
+		return f.Field() + " is required"
+	case "email":
+		return f.Field() + " must be a valid email"
+	case "oneof":
+		return f.Field() + " must be one of " + f.Param()
+	case "min", "gte":
+		return f.Field() + " must be at least " + f.Param()
+	case "max", "lte":
+		return f.Field() + " must be at most " + f.Param()
+	}
+	return f.Field() + " is invalid"
+}
+
+// JSONFieldName is a validator.TagNameFunc naming the fields after their json tag
+func JSONFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// postgres error codes (SQLSTATE)
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgInvalidText         = "22P02"
+	pgNumericOutOfRange   = "22003"
+	pgStringTooLong       = "22001"
+)
+
+// FromDB translate a database error into the error of the client by its SQLSTATE, subject
+// names the record or field in the message (e.g. "Email" gives "Email already exists").
+// Errors that are not the client's fault become internal errors
+func FromDB(err error, subject string) *Error {
+	if e := (*Error)(nil); errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound(subject + " not found").Wrap(err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return Internal("Try again later").Wrap(err)
+	}
+
+	switch pqErr.Code {
+	case pgUniqueViolation:
+		return Conflict(subject + " already exists").WithCode(constraintCode(pqErr, "duplicate")).Wrap(err)
+	case pgForeignKeyViolation:
+		return Conflict(subject + " is referenced by or references a missing record").WithCode(constraintCode(pqErr, "reference")).Wrap(err)
+	case pgNotNullViolation, pgCheckViolation, pgInvalidText, pgNumericOutOfRange, pgStringTooLong:
+		e := Validation("Invalid " + strings.ToLower(subject)).Wrap(err)
+		if pqErr.Column != "" {
+			e.Fields = []FieldError{{Field: pqErr.Column, Rule: "db", Message: pqErr.Column + " is invalid"}}
+		}
+		return e
+	}
+	return Internal("Try again later").Wrap(err)
+}
+
+// constraintCode returns a code naming the violated constraint, e.g. duplicate:users_email_key
+func constraintCode(err *pq.Error, prefix string) string {
+	if err.Constraint == "" {
+		return prefix
+	}
+	return fmt.Sprintf("%s:%s", prefix, err.Constraint)
+}
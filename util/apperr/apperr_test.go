@@ -0,0 +1,105 @@
+package apperr_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+	"github.com/online-bnsp/backend/util/apperr"
+)
+
+func TestFromDB(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{sql.ErrNoRows, http.StatusNotFound, apperr.CodeNotFound},
+		{&pq.Error{Code: "23505", Constraint: "users_email_key"}, http.StatusConflict, "duplicate:users_email_key"},
+		{fmt.Errorf("create user: %w", &pq.Error{Code: "23505"}), http.StatusConflict, "duplicate"},
+		{&pq.Error{Code: "23503", Constraint: "cart_course_id_fkey"}, http.StatusConflict, "reference:cart_course_id_fkey"},
+		{&pq.Error{Code: "23502", Column: "email"}, http.StatusBadRequest, apperr.CodeValidation},
+		{&pq.Error{Code: "22P02"}, http.StatusBadRequest, apperr.CodeValidation},
+		{&pq.Error{Code: "40P01"}, http.StatusInternalServerError, apperr.CodeInternal},
+		{errors.New("connection refused"), http.StatusInternalServerError, apperr.CodeInternal},
+		{apperr.Forbidden("Not your course"), http.StatusForbidden, apperr.CodeForbidden},
+	}
+	for _, tt := range tests {
+		e := apperr.FromDB(tt.err, "Email")
+		if e.Status() != tt.status || e.ErrorCode() != tt.code {
+			t.Errorf("%v: got %d %s, want %d %s", tt.err, e.Status(), e.ErrorCode(), tt.status, tt.code)
+		}
+	}
+
+	if e := apperr.FromDB(&pq.Error{Code: "23505"}, "Email"); e.Message != "Email already exists" {
+		t.Errorf("got %q", e.Message)
+	}
+	if e := apperr.FromDB(&pq.Error{Code: "23502", Column: "email"}, "Email"); len(e.Fields) != 1 || e.Fields[0].Field != "email" {
+		t.Errorf("got %+v", e.Fields)
+	}
+}
+
+func TestFromValidation(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperr.JSONFieldName)
+
+	var req struct {
+		Email string `json:"email" validate:"required,email"`
+		Role  string `json:"role,omitempty" validate:"oneof=student teacher"`
+	}
+	req.Email = "not an email"
+	req.Role = "admin"
+
+	e := apperr.FromValidation(validate.Struct(req))
+	if e.Status() != http.StatusBadRequest || len(e.Fields) != 2 {
+		t.Fatalf("got %d %+v", e.Status(), e.Fields)
+	}
+	if f := e.Fields[0]; f.Field != "email" || f.Rule != "email" {
+		t.Errorf("got %+v", f)
+	}
+	if f := e.Fields[1]; f.Field != "role" || f.Message != "role must be one of student teacher" {
+		t.Errorf("got %+v", f)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		message string
+		code    string
+	}{
+		{apperr.NotFound("Course not found"), http.StatusNotFound, "Course not found", apperr.CodeNotFound},
+		{apperr.Unauthorized("User atau password salah").WithCode("invalid_credentials"), http.StatusUnauthorized, "User atau password salah", "invalid_credentials"},
+		// the cause stays in the logs
+		{apperr.Internal("Try again later").Wrap(errors.New(`pq: relation "users" does not exist`)), http.StatusInternalServerError, "Try again later", apperr.CodeInternal},
+		{errors.New("dial tcp 10.0.0.3:5432: connection refused"), http.StatusInternalServerError, "Internal server error", apperr.CodeInternal},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		apperr.WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+		var body struct {
+			Code    int
+			Status  int
+			Message string
+			Data    apperr.Detail
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body %s: %v", w.Body, err)
+		}
+		if w.Code != tt.status || body.Code != tt.status || body.Message != tt.message || body.Data.Code != tt.code {
+			t.Errorf("got %d %s", w.Code, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "pq:") || strings.Contains(w.Body.String(), "dial tcp") {
+			t.Errorf("leaked the cause: %s", w.Body)
+		}
+	}
+}
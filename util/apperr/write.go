@@ -0,0 +1,32 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+// Detail is the data of an error response
+type Detail struct {
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// WriteError write err to the client in the util.Response envelope. Errors that are not an
+// *Error are answered as internal errors, internal errors are logged with their cause and only
+// their message is sent
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("Internal server error").Wrap(err)
+	}
+
+	if e.Kind == KindInternal && e.Err != nil {
+		logger.Err(r.Context(), e.Err).Str("code", e.ErrorCode()).Msg(e.Message)
+	}
+
+	status := e.Status()
+	util.NewResponse(status, status, e.Message, Detail{Code: e.ErrorCode(), Fields: e.Fields}).WriteResponse(w, r)
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/ratelimit"
+	"github.com/online-bnsp/backend/util/tracing"
+)
+
+// Entry is a change to record. Before and After are the resource around the change, nil when it
+// was created or removed, and only their differing fields are stored
+type Entry struct {
+	Action     string // <resource>.<verb>, e.g. course.update
+	Resource   string
+	ResourceID any
+	Before     any
+	After      any
+}
+
+// Record append e to the audit log with the actor, address and request id of r. q has to run in
+// the transaction of the change, so the change is not committed without its entry
+func Record(r *http.Request, q *repo.Queries, e Entry) error {
+	ctx := r.Context()
+	before, after, err := Diff(e.Before, e.After)
+	if err != nil {
+		return err
+	}
+
+	userID, ok := ctx.Value("user_id").(int32)
+	role, _ := ctx.Value("role").(string)
+	resourceID := ""
+	if e.ResourceID != nil {
+		resourceID = fmt.Sprint(e.ResourceID)
+	}
+
+	err = q.CreateAuditLog(ctx, repo.CreateAuditLogParams{
+		ActorID:      sql.NullInt32{Int32: userID, Valid: ok},
+		ActorRole:    role,
+		Action:       e.Action,
+		ResourceType: e.Resource,
+		ResourceID:   resourceID,
+		Before:       before,
+		After:        after,
+		Ip:           ratelimit.ClientIP(r),
+		RequestID:    requestID(r),
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("record audit log: %w", err)
+	}
+	return nil
+}
+
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(middleware.RequestIDKey).(uuid.UUID); ok {
+		return id.String()
+	}
+	return r.Header.Get(middleware.RequestIDHeader)
+}
+
+// Diff marshal before and after to json objects and keep the fields that differ, a nil side is
+// stored as an empty object
+func Diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := toFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range b {
+		if w, ok := a[k]; ok && bytes.Equal(v, w) {
+			delete(b, k)
+			delete(a, k)
+		}
+	}
+
+	beforeJSON, err := json.Marshal(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := json.Marshal(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+// toFields split the json object of v in its fields, each compacted so equal values compare equal
+func toFields(v any) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit value: %w", err)
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("audit value is not an object: %w", err)
+	}
+	for k, raw := range fields {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, unwrapNull(raw)); err != nil {
+			return nil, err
+		}
+		fields[k] = buf.Bytes()
+	}
+	return fields, nil
+}
+
+// unwrapNull replace the {"String": "x", "Valid": true} of the sql.Null types of the generated
+// models with "x", or null when it is not valid
+func unwrapNull(raw json.RawMessage) json.RawMessage {
+	var v map[string]json.RawMessage
+	if json.Unmarshal(raw, &v) != nil || len(v) != 2 {
+		return raw
+	}
+	valid, ok := v["Valid"]
+	if !ok {
+		return raw
+	}
+	if string(valid) != "true" {
+		return json.RawMessage("null")
+	}
+	for k, value := range v {
+		if k != "Valid" {
+			return value
+		}
+	}
+	return raw
+}
+
+// Log run changes in a transaction with their audit entry
+type Log struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Log {
+	return &Log{conn}
+}
+
+// Do run fn in a transaction and record the entry it returns before committing. The transaction
+// is rolled back when fn fails, or when the entry can not be recorded. An entry without an
+// action is not recorded, for a change that turned out to change nothing
+func (l *Log) Do(r *http.Request, fn func(q *repo.Queries) (Entry, error)) error {
+	return l.Tx(r.Context(), func(q *repo.Queries) error {
+		e, err := fn(q)
+		if err != nil || e.Action == "" {
+			return err
+		}
+		return Record(r, q, e)
+	})
+}
+
+// Tx run fn in a transaction, for changes recording several entries with Record
+func (l *Log) Tx(ctx context.Context, fn func(q *repo.Queries) error) error {
+	tx, err := l.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(repo.New(tracing.WrapDB(tx))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
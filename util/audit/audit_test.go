@@ -0,0 +1,47 @@
+package audit_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/online-bnsp/backend/util/audit"
+)
+
+type course struct {
+	CourseID  int32          `json:"course_id"`
+	Price     int32          `json:"price"`
+	Thumbnail sql.NullString `json:"thumbnail"`
+	DeletedAt sql.NullTime   `json:"deleted_at"`
+	Tags      []string       `json:"tags"`
+}
+
+func TestDiff(t *testing.T) {
+	before := course{CourseID: 1, Price: 100000, Thumbnail: sql.NullString{String: "a.png", Valid: true}, Tags: []string{"go"}}
+	after := before
+	after.Price = 75000
+	after.DeletedAt = sql.NullTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	for _, tc := range []struct {
+		name          string
+		before, after any
+		want          [2]string
+	}{
+		{"update", before, after, [2]string{`{"deleted_at":null,"price":100000}`, `{"deleted_at":"2024-01-01T00:00:00Z","price":75000}`}},
+		{"create", nil, map[string]int{"sale_id": 3}, [2]string{`{}`, `{"sale_id":3}`}},
+		{"delete", map[string]int{"sale_id": 3}, nil, [2]string{`{"sale_id":3}`, `{}`}},
+		{"unchanged", before, before, [2]string{`{}`, `{}`}},
+	} {
+		b, a, err := audit.Diff(tc.before, tc.after)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if string(b) != tc.want[0] || string(a) != tc.want[1] {
+			t.Errorf("%s: got %s %s", tc.name, b, a)
+		}
+	}
+
+	if _, _, err := audit.Diff([]int{1}, nil); err == nil {
+		t.Error("expected an error for a value that is not an object")
+	}
+}
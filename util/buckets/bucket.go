@@ -1,7 +1,35 @@
 package buckets
 
-import "io"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type Bucket interface {
 	Upload(filename string, file io.Reader) (string, error)
 }
+
+// Object describes a single stored file
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Lister is implemented by buckets whose content can be enumerated and cleaned up,
+// e.g. by the media garbage collector
+type Lister interface {
+	// Key maps a reference stored in the database (URL or path) to an object key,
+	// returns false when the reference does not belong to the bucket
+	Key(ref string) (string, bool)
+	List() ([]Object, error)
+	Delete(key string) error
+	Move(key, dst string) error
+}
+
+// Checker is implemented by buckets that can tell whether they are reachable,
+// e.g. for the readiness probe
+type Checker interface {
+	Check(ctx context.Context) error
+}
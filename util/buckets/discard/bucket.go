@@ -1,8 +1,11 @@
 package discard
 
 import (
+	"context"
 	"errors"
 	"io"
+
+	"github.com/online-bnsp/backend/util/buckets"
 )
 
 type Bucket struct{}
@@ -10,3 +13,24 @@ type Bucket struct{}
 func (b *Bucket) Upload(filename string, file io.Reader) (string, error) {
 	return "", errors.New("discarding file: " + filename)
 }
+
+func (b *Bucket) Key(ref string) (string, bool) {
+	return "", false
+}
+
+func (b *Bucket) List() ([]buckets.Object, error) {
+	return nil, nil
+}
+
+func (b *Bucket) Delete(key string) error {
+	return nil
+}
+
+func (b *Bucket) Move(key, dst string) error {
+	return nil
+}
+
+// Check always succeed, nothing is stored
+func (b *Bucket) Check(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package gc
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/online-bnsp/backend/util/buckets"
+)
+
+// QuarantinePrefix is where unreferenced objects are moved when quarantine is enabled,
+// objects under this prefix are never collected again
+const QuarantinePrefix = ".quarantine/"
+
+const (
+	ActionDryRun     = "dry-run"
+	ActionDelete     = "delete"
+	ActionQuarantine = "quarantine"
+)
+
+type (
+	Store struct {
+		Name   string
+		Bucket buckets.Lister
+	}
+
+	Config struct {
+		Grace      time.Duration // objects modified within this period are left alone
+		DryRun     bool
+		Quarantine bool
+		Keep       []string // keys that are never collected, e.g. default.png
+		Now        time.Time
+	}
+
+	Item struct {
+		Store   string
+		Key     string
+		Size    int64
+		ModTime time.Time
+		Action  string
+		Err     error
+	}
+
+	Report struct {
+		Scanned    int
+		Referenced int
+		Young      int
+		Items      []Item
+	}
+)
+
+// Freed returns total size of the collected objects
+func (r Report) Freed() (size int64) {
+	for _, it := range r.Items {
+		if it.Err == nil {
+			size += it.Size
+		}
+	}
+	return
+}
+
+// Collect walk every store and remove objects that are not referenced by refs.
+//
+// A reference without any directory component (legacy rows that only kept the file name)
+// protects every object with the same base name, it is better to keep a file than to lose one.
+func Collect(stores []Store, refs []string, cfg Config) (Report, error) {
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+
+	keep := make(map[string]bool, len(cfg.Keep))
+	for _, k := range cfg.Keep {
+		keep[k] = true
+	}
+
+	bareNames := make(map[string]bool)
+	for _, ref := range refs {
+		if ref != "" && path.Base(ref) == ref {
+			bareNames[ref] = true
+		}
+	}
+
+	var report Report
+	for _, s := range stores {
+		referenced := make(map[string]bool)
+		for _, ref := range refs {
+			if key, ok := s.Bucket.Key(ref); ok {
+				referenced[key] = true
+			}
+		}
+
+		objects, err := s.Bucket.List()
+		if err != nil {
+			return report, err
+		}
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+		for _, o := range objects {
+			if strings.HasPrefix(o.Key, QuarantinePrefix) {
+				continue
+			}
+			report.Scanned++
+
+			if referenced[o.Key] || bareNames[path.Base(o.Key)] || keep[o.Key] {
+				report.Referenced++
+				continue
+			}
+			if cfg.Now.Sub(o.ModTime) < cfg.Grace {
+				report.Young++
+				continue
+			}
+
+			it := Item{Store: s.Name, Key: o.Key, Size: o.Size, ModTime: o.ModTime}
+			switch {
+			case cfg.DryRun:
+				it.Action = ActionDryRun
+			case cfg.Quarantine:
+				it.Action = ActionQuarantine
+				it.Err = s.Bucket.Move(o.Key, QuarantinePrefix+o.Key)
+			default:
+				it.Action = ActionDelete
+				it.Err = s.Bucket.Delete(o.Key)
+			}
+			report.Items = append(report.Items, it)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,114 @@
+package gc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-bnsp/backend/util/buckets"
+	"github.com/online-bnsp/backend/util/buckets/gc"
+)
+
+type memBucket struct {
+	objects map[string]buckets.Object
+}
+
+func (b *memBucket) Key(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, "static/") {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, "static/"), true
+}
+
+func (b *memBucket) List() (res []buckets.Object, err error) {
+	for _, o := range b.objects {
+		res = append(res, o)
+	}
+	return
+}
+
+func (b *memBucket) Delete(key string) error {
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *memBucket) Move(key, dst string) error {
+	o := b.objects[key]
+	delete(b.objects, key)
+	o.Key = dst
+	b.objects[dst] = o
+	return nil
+}
+
+func newBucket(now time.Time) *memBucket {
+	old := now.Add(-48 * time.Hour)
+	return &memBucket{objects: map[string]buckets.Object{
+		"course/used.png":   {Key: "course/used.png", Size: 10, ModTime: old},
+		"course/orphan.png": {Key: "course/orphan.png", Size: 20, ModTime: old},
+		"course/fresh.png":  {Key: "course/fresh.png", Size: 30, ModTime: now},
+		"videos/legacy.mp4": {Key: "videos/legacy.mp4", Size: 40, ModTime: old},
+		"default.png":       {Key: "default.png", Size: 50, ModTime: old},
+		".quarantine/x.png": {Key: ".quarantine/x.png", Size: 60, ModTime: old},
+	}}
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	refs := []string{"static/course/used.png", "legacy.mp4"}
+
+	tests := []struct {
+		name      string
+		cfg       gc.Config
+		remaining []string
+		action    string
+	}{
+		{
+			name:      "dry run keeps everything",
+			cfg:       gc.Config{Grace: time.Hour, DryRun: true, Keep: []string{"default.png"}, Now: now},
+			remaining: []string{"course/orphan.png"},
+			action:    gc.ActionDryRun,
+		},
+		{
+			name:   "delete orphan only",
+			cfg:    gc.Config{Grace: time.Hour, Keep: []string{"default.png"}, Now: now},
+			action: gc.ActionDelete,
+		},
+		{
+			name:      "quarantine orphan",
+			cfg:       gc.Config{Grace: time.Hour, Quarantine: true, Keep: []string{"default.png"}, Now: now},
+			remaining: []string{".quarantine/course/orphan.png"},
+			action:    gc.ActionQuarantine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(now)
+			report, err := gc.Collect([]gc.Store{{Name: "mem", Bucket: b}}, refs, tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(report.Items) != 1 || report.Items[0].Key != "course/orphan.png" {
+				t.Fatalf("expect only course/orphan.png to be collected, got %+v", report.Items)
+			}
+			if report.Items[0].Action != tt.action {
+				t.Errorf("expect action %v, got %v", tt.action, report.Items[0].Action)
+			}
+			if report.Scanned != 5 || report.Young != 1 || report.Referenced != 3 {
+				t.Errorf("unexpected counters %+v", report)
+			}
+
+			for _, key := range []string{"course/used.png", "course/fresh.png", "videos/legacy.mp4", "default.png"} {
+				if _, ok := b.objects[key]; !ok {
+					t.Errorf("%v should not be collected", key)
+				}
+			}
+			for _, key := range tt.remaining {
+				if _, ok := b.objects[key]; !ok {
+					t.Errorf("expect %v to exist", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package local
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// HideDotFiles serve fsys without the files and directories starting with a dot, e.g. the
+// .quarantine/ directory of the media gc, so they are not downloadable nor listed
+func HideDotFiles(fsys http.FileSystem) http.FileSystem {
+	return dotFileHidingFS{fsys}
+}
+
+type dotFileHidingFS struct {
+	http.FileSystem
+}
+
+func (fsys dotFileHidingFS) Open(name string) (http.File, error) {
+	if hasDotSegment(name) {
+		return nil, fs.ErrNotExist
+	}
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return dotFileHidingFile{f}, nil
+}
+
+type dotFileHidingFile struct {
+	http.File
+}
+
+// Readdir drop the dot files from the directory listing
+func (f dotFileHidingFile) Readdir(n int) ([]fs.FileInfo, error) {
+	files, err := f.File.Readdir(n)
+	visible := files[:0]
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), ".") {
+			visible = append(visible, file)
+		}
+	}
+	return visible, err
+}
+
+func hasDotSegment(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package local_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/buckets/local"
+)
+
+func TestHideDotFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"course/a.png", ".quarantine/course/b.png"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("png"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := http.FileServer(local.HideDotFiles(http.Dir(dir)))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/course/a.png"); w.Code != http.StatusOK {
+		t.Errorf("visible file: got %d", w.Code)
+	}
+	for _, path := range []string{"/.quarantine/course/b.png", "/.quarantine/", "/course/../.quarantine/course/b.png"} {
+		if w := get(path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+	if w := get("/"); strings.Contains(w.Body.String(), ".quarantine") {
+		t.Errorf("dot directory is listed: %s", w.Body.String())
+	}
+}
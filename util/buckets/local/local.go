@@ -1,13 +1,17 @@
 package local
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/online-bnsp/backend/util/buckets"
 )
 
 type Bucket struct {
@@ -47,6 +51,98 @@ func (b *Bucket) Upload(filename string, file io.Reader) (string, error) {
 	return url.JoinPath(b.BaseURL, filename)
 }
 
+func (b *Bucket) Key(ref string) (string, bool) {
+	if ref == "" {
+		return "", false
+	}
+
+	// full url returned by Upload
+	if b.BaseURL != "" && strings.HasPrefix(ref, b.BaseURL) {
+		return strings.TrimPrefix(strings.TrimPrefix(ref, b.BaseURL), "/"), true
+	}
+
+	// absolute path on disk
+	if filepath.IsAbs(ref) {
+		base, err := filepath.Abs(b.BaseDir)
+		if err != nil {
+			return "", false
+		}
+		rel, err := filepath.Rel(base, ref)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		return filepath.ToSlash(rel), true
+	}
+
+	// url path served by the bucket (e.g. static/course/a.png) or path relative to working dir
+	ref = strings.TrimPrefix(ref, "/")
+	for _, prefix := range []string{strings.Trim(b.Prefix, "/"), filepath.ToSlash(filepath.Clean(b.BaseDir))} {
+		if prefix != "" && prefix != "." && strings.HasPrefix(ref, prefix+"/") {
+			return strings.TrimPrefix(ref, prefix+"/"), true
+		}
+	}
+
+	return "", false
+}
+
+func (b *Bucket) List() ([]buckets.Object, error) {
+	var objects []buckets.Object
+	err := filepath.WalkDir(b.BaseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		key, err := filepath.Rel(b.BaseDir, p)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, buckets.Object{
+			Key:     filepath.ToSlash(key),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return objects, err
+}
+
+func (b *Bucket) Delete(key string) error {
+	return os.Remove(filepath.Join(b.BaseDir, filepath.FromSlash(key)))
+}
+
+func (b *Bucket) Move(key, dst string) error {
+	target := filepath.Join(b.BaseDir, filepath.FromSlash(dst))
+	err := os.MkdirAll(filepath.Dir(target), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(b.BaseDir, filepath.FromSlash(key)), target)
+}
+
+// Check that the base directory exists
+func (b *Bucket) Check(ctx context.Context) error {
+	info, err := os.Stat(b.BaseDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", b.BaseDir)
+	}
+	return nil
+}
+
 func (b *Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !strings.HasPrefix(r.URL.Path, b.Prefix) {
 		if b.Handler != nil {
@@ -58,7 +154,7 @@ func (b *Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	imgPath := r.URL.Path[len(b.Prefix):]
 	path := filepath.Join(b.BaseDir, imgPath)
 	contents, err := os.ReadFile(path)
-	if err != nil {
+	if err != nil || hasDotSegment(imgPath) {
 		w.WriteHeader(http.StatusNotFound)
 		fmt.Fprint(w, "Not found")
 		return
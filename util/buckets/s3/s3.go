@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -23,3 +24,56 @@ func (b *Bucket) Upload(filename string, file io.Reader) (string, error) {
 	}
 	return b.Bucket.Upload(filename, file)
 }
+
+// Check that the bucket exists and the credentials can access it
+func (b *Bucket) Check(ctx context.Context) error {
+	err := b.Bucket.Connect()
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+	return b.Bucket.Head(ctx)
+}
+
+func (b *Bucket) Key(ref string) (string, bool) {
+	return b.Bucket.ObjectKey(ref)
+}
+
+func (b *Bucket) List() ([]buckets.Object, error) {
+	err := b.Bucket.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("connect error: %w", err)
+	}
+
+	objects, err := b.Bucket.List()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]buckets.Object, 0, len(objects))
+	for _, o := range objects {
+		res = append(res, buckets.Object{Key: o.Key, Size: o.Size, ModTime: o.LastModified})
+	}
+	return res, nil
+}
+
+func (b *Bucket) Delete(key string) error {
+	err := b.Bucket.Connect()
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+	return b.Bucket.Delete(key)
+}
+
+// Move copy the object to the new key then remove the original, s3 has no rename
+func (b *Bucket) Move(key, dst string) error {
+	err := b.Bucket.Connect()
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+
+	err = b.Bucket.Copy(key, dst)
+	if err != nil {
+		return err
+	}
+	return b.Bucket.Delete(key)
+}
@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// tags of the cached data, a mutation invalidates every entry carrying its tag
+const (
+	TagCourses    = "courses"
+	TagCategories = "categories"
+	TagVideos     = "videos"
+)
+
+// Entry is the cached json of a response and its entity tag
+type Entry struct {
+	Body []byte
+	ETag string
+}
+
+func newEntry(body []byte) Entry {
+	sum := sha256.Sum256(body)
+	// weak since the envelope around the body differs between responses
+	return Entry{Body: body, ETag: `W/"` + hex.EncodeToString(sum[:16]) + `"`}
+}
+
+// Cache is a read-through cache in redis. Entries are keyed by the versions of their tags, so
+// invalidating a tag only bumps its version and the stale entries expire on their own.
+// A nil cache loads every time
+type Cache struct {
+	rdb    *redis.Client
+	ttl    time.Duration
+	maxAge time.Duration
+
+	// a miss is loaded once per process, and once across processes while the fill lock is held
+	group   singleflight.Group
+	lockTTL time.Duration
+	poll    time.Duration
+}
+
+// New create a cache keeping the entries for ttl, clients may reuse a response for maxAge
+// before revalidating it
+func New(rdb *redis.Client, ttl, maxAge time.Duration) *Cache {
+	return &Cache{rdb: rdb, ttl: ttl, maxAge: maxAge, lockTTL: 5 * time.Second, poll: 50 * time.Millisecond}
+}
+
+// Load return the entry of key, on a miss it is loaded with load, marshalled to json and stored
+// with tags. When redis fails the data is loaded without caching
+func (c *Cache) Load(ctx context.Context, key string, tags []string, load func(ctx context.Context) (any, error)) (Entry, error) {
+	if c == nil {
+		return loadEntry(ctx, load)
+	}
+
+	versioned, err := c.versionedKey(ctx, key, tags)
+	if err != nil {
+		logger.Warn(ctx, err).Str("key", key).Msg("error reading cache tags")
+		return loadEntry(ctx, load)
+	}
+
+	v, err, _ := c.group.Do(versioned, func() (any, error) {
+		return c.fill(ctx, versioned, load)
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	return v.(Entry), nil
+}
+
+func (c *Cache) fill(ctx context.Context, key string, load func(ctx context.Context) (any, error)) (Entry, error) {
+	if e, ok := c.get(ctx, key); ok {
+		return e, nil
+	}
+
+	// the other replicas wait for the holder of the lock instead of all running the query
+	lock := key + ":lock"
+	acquired, err := c.rdb.SetNX(ctx, lock, 1, c.lockTTL).Result()
+	if err != nil {
+		logger.Warn(ctx, err).Str("key", key).Msg("error locking cache entry")
+		return loadEntry(ctx, load)
+	}
+	if acquired {
+		defer c.rdb.Del(ctx, lock)
+	} else if e, ok := c.wait(ctx, key); ok {
+		return e, nil
+	}
+
+	e, err := loadEntry(ctx, load)
+	if err != nil {
+		return e, err
+	}
+	if err := c.rdb.Set(ctx, key, e.ETag+"\n"+string(e.Body), c.ttl).Err(); err != nil {
+		logger.Warn(ctx, err).Str("key", key).Msg("error storing cache entry")
+	}
+	return e, nil
+}
+
+// wait for the holder of the fill lock, until the lock would have expired
+func (c *Cache) wait(ctx context.Context, key string) (Entry, bool) {
+	deadline := time.Now().Add(c.lockTTL)
+	t := time.NewTicker(c.poll)
+	defer t.Stop()
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return Entry{}, false
+		case <-t.C:
+		}
+		if e, ok := c.get(ctx, key); ok {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+func (c *Cache) get(ctx context.Context, key string) (Entry, bool) {
+	v, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Warn(ctx, err).Str("key", key).Msg("error reading cache entry")
+		}
+		return Entry{}, false
+	}
+	etag, body, ok := bytes.Cut(v, []byte("\n"))
+	if !ok {
+		return Entry{}, false
+	}
+	return Entry{Body: body, ETag: string(etag)}, true
+}
+
+func (c *Cache) versionedKey(ctx context.Context, key string, tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "cache:" + key, nil
+	}
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = tagKey(tag)
+	}
+	versions, err := c.rdb.MGet(ctx, tagKeys...).Result()
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = "0"
+		if s, ok := v.(string); ok {
+			parts[i] = s
+		}
+	}
+	return "cache:" + key + ":" + strings.Join(parts, "."), nil
+}
+
+func tagKey(tag string) string {
+	return "cache:tag:" + tag
+}
+
+// Invalidate drop the entries carrying one of tags. It is called after the mutation is stored,
+// a failure is only logged and the entries expire after their ttl
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) {
+	if c == nil || len(tags) == 0 {
+		return
+	}
+	pipe := c.rdb.Pipeline()
+	for _, tag := range tags {
+		pipe.Incr(ctx, tagKey(tag))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn(ctx, err).Strs("tags", tags).Msg("error invalidating cache")
+	}
+}
+
+func loadEntry(ctx context.Context, load func(ctx context.Context) (any, error)) (Entry, error) {
+	v, err := load(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return Entry{}, fmt.Errorf("marshal cache entry: %w", err)
+	}
+	return newEntry(body), nil
+}
+
+// NotModified set the ETag and Cache-Control headers of e, then answer 304 and return true when
+// the client already has it
+func (c *Cache) NotModified(w http.ResponseWriter, r *http.Request, e Entry) bool {
+	cacheControl := "no-cache"
+	if c != nil && c.maxAge > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(c.maxAge.Seconds()))
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", e.ETag)
+
+	if !matches(r.Header.Get("If-None-Match"), e.ETag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Write answer r with e in the response envelope, or 304 when the client already has it
+func (c *Cache) Write(w http.ResponseWriter, r *http.Request, e Entry) {
+	if c.NotModified(w, r, e) {
+		return
+	}
+	util.NewResponse(http.StatusOK, http.StatusOK, "", json.RawMessage(e.Body)).WriteResponse(w, r)
+}
+
+// matches compare the tags of If-None-Match weakly, as RFC 9110 asks for GET requests
+func matches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/online-bnsp/backend/util/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func setup(t *testing.T) (*cache.Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, 30*time.Second), mr
+}
+
+func TestLoadAndInvalidate(t *testing.T) {
+	c, mr := setup(t)
+	ctx := context.Background()
+
+	var loads int32
+	load := func(ctx context.Context) (any, error) {
+		n := atomic.AddInt32(&loads, 1)
+		return []int32{n}, nil
+	}
+
+	first, err := c.Load(ctx, "public:home", []string{cache.TagCourses}, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := c.Load(ctx, "public:home", []string{cache.TagCourses}, load)
+	if loads != 1 || string(second.Body) != "[1]" || second.ETag != first.ETag {
+		t.Fatalf("got %d loads, %s %s", loads, second.Body, second.ETag)
+	}
+
+	// other tags keep their entries
+	c.Invalidate(ctx, cache.TagCategories)
+	if c.Load(ctx, "public:home", []string{cache.TagCourses}, load); loads != 1 {
+		t.Errorf("reloaded after an unrelated invalidation")
+	}
+
+	c.Invalidate(ctx, cache.TagCourses)
+	third, _ := c.Load(ctx, "public:home", []string{cache.TagCourses}, load)
+	if loads != 2 || string(third.Body) != "[2]" || third.ETag == first.ETag {
+		t.Errorf("got %d loads, %s %s", loads, third.Body, third.ETag)
+	}
+
+	mr.FastForward(time.Minute)
+	if c.Load(ctx, "public:home", []string{cache.TagCourses}, load); loads != 3 {
+		t.Errorf("entry outlived its ttl")
+	}
+
+	// errors are not cached
+	failing := func(ctx context.Context) (any, error) { return nil, errors.New("connection refused") }
+	if _, err := c.Load(ctx, "public:popular", nil, failing); err == nil {
+		t.Error("expected the load error")
+	}
+	if e, err := c.Load(ctx, "public:popular", nil, load); err != nil || string(e.Body) != "[4]" {
+		t.Errorf("got %s %v", e.Body, err)
+	}
+}
+
+func TestLoadOnce(t *testing.T) {
+	c, _ := setup(t)
+	ctx := context.Background()
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (any, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "catalog", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e, err := c.Load(ctx, "public:getall-course", []string{cache.TagCourses}, load); err != nil || string(e.Body) != `"catalog"` {
+				t.Errorf("got %s %v", e.Body, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("loaded %d times", loads)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	c, _ := setup(t)
+	e, _ := c.Load(context.Background(), "public:category", nil, func(ctx context.Context) (any, error) {
+		return []string{"design"}, nil
+	})
+
+	w := httptest.NewRecorder()
+	c.Write(w, httptest.NewRequest(http.MethodGet, "/public/category", nil), e)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != e.ETag || w.Header().Get("Cache-Control") != "public, max-age=30" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+
+	for _, ifNoneMatch := range []string{e.ETag, `"other", ` + e.ETag[2:], "*"} {
+		r := httptest.NewRequest(http.MethodGet, "/public/category", nil)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		c.Write(w, r, e)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: got %d %s", ifNoneMatch, w.Code, w.Body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/public/category", nil)
+	r.Header.Set("If-None-Match", `W/"stale"`)
+	w = httptest.NewRecorder()
+	c.Write(w, r, e)
+	if w.Code != http.StatusOK {
+		t.Errorf("got %d", w.Code)
+	}
+
+	// without a cache the responses are still revalidated
+	var disabled *cache.Cache
+	w = httptest.NewRecorder()
+	if disabled.NotModified(w, httptest.NewRequest(http.MethodGet, "/", nil), e) || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("got %v", w.Header())
+	}
+}
@@ -0,0 +1,158 @@
+package cartreminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/notify"
+	"github.com/online-bnsp/backend/util/signedtoken"
+)
+
+var (
+	idle         = 24 * time.Hour
+	maxReminders = 3
+	returnURL    string
+	checkoutURL  string
+	tokens       signedtoken.Signer
+)
+
+var ErrInvalidToken = errors.New("invalid cart reminder token")
+
+// SetConfig set how long a cart stays untouched before a reminder and how many reminders a cart
+// gets, returnURL is the public link of the return endpoint and checkoutURL the page it redirects to
+func SetConfig(idleFor time.Duration, max int, returnLink, checkoutLink, key string) {
+	if idleFor > 0 {
+		idle = idleFor
+	}
+	if max > 0 {
+		maxReminders = max
+	}
+	returnURL = returnLink
+	checkoutURL = checkoutLink
+	tokens = signedtoken.New(key, "cart")
+}
+
+func CheckoutURL() string {
+	return checkoutURL
+}
+
+// Token sign the reminder so the link in the message identifies it without a login
+func Token(reminderID int32) string {
+	return tokens.Sign(strconv.Itoa(int(reminderID)))
+}
+
+// ParseToken return the reminder of a token made by Token
+func ParseToken(token string) (int32, error) {
+	payload, err := tokens.Parse(token)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	id, err := strconv.ParseInt(payload, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return int32(id), nil
+}
+
+// Link is the one click link back to the checkout, it goes through the return
+// endpoint so the click is recorded, or straight to the checkout without one
+func Link(reminderID int32) string {
+	if returnURL == "" {
+		return checkoutURL
+	}
+	return signedtoken.Link(returnURL, Token(reminderID))
+}
+
+// Reminder follows up on carts nobody checked out
+type Reminder struct {
+	db        *repo.Queries
+	publisher *notify.Publisher
+}
+
+func New(db *repo.Queries, publisher *notify.Publisher) *Reminder {
+	return &Reminder{db, publisher}
+}
+
+// Send remind the owners of idle carts by email, and by whatsapp when they have a phone number,
+// the consumer drops the channels the user turned off. Carts stop getting reminders once
+// bought or after the configured number of reminders
+func (c *Reminder) Send(ctx context.Context) (int, error) {
+	now := time.Now()
+	carts, err := c.db.GetAbandonedCarts(ctx, repo.GetAbandonedCartsParams{
+		MaxReminders: int32(maxReminders),
+		IdleBefore:   now.Add(-idle),
+		Now:          now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, cart := range carts {
+		items, err := c.db.GetCartByUserID(ctx, repo.GetCartByUserIDParams{PricedAt: now, UserID: util.SqlInt32(cart.UserID)})
+		if err != nil {
+			return sent, err
+		}
+		courses := []string{}
+		for _, it := range items {
+			courses = append(courses, it.CourseName.String)
+		}
+
+		// stored first so the link can point at it
+		reminder, err := c.db.CreateCartReminder(ctx, repo.CreateCartReminderParams{
+			UserID:        cart.UserID,
+			CartStartedAt: cart.StartedAt,
+			Items:         int32(cart.Items),
+			TotalAmount:   int32(cart.TotalAmount),
+			SentAt:        now,
+		})
+		if err != nil {
+			return sent, err
+		}
+		link := Link(reminder.ReminderID)
+
+		to := mailer.Recipient{Email: cart.Email, Name: cart.Nama, UserID: cart.UserID}
+		_, err = c.publisher.Email(ctx, to, mailer.TemplateCartReminder, mailer.CartReminderMail{
+			Courses: courses,
+			Total:   cart.TotalAmount,
+			Link:    link,
+		})
+		if err != nil {
+			logger.Err(ctx, err).Msg("error queueing cart reminder email")
+		}
+
+		if cart.Phone.Valid && cart.Phone.String != "" {
+			text := fmt.Sprintf("Hi %s, you still have %d course(s) in your cart worth %s. Continue to checkout: %s",
+				cart.Nama, cart.Items, util.FormatRupiah(cart.TotalAmount), link)
+			if _, err := c.publisher.Whatsapp(ctx, cart.UserID, constant.NotificationCartReminder, cart.Phone.String, text); err != nil {
+				logger.Err(ctx, err).Msg("error queueing cart reminder whatsapp")
+			}
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+// Click record that the link of the reminder was followed
+func (c *Reminder) Click(ctx context.Context, token string) error {
+	id, err := ParseToken(token)
+	if err != nil {
+		return err
+	}
+
+	return c.db.MarkCartReminderClicked(ctx, repo.MarkCartReminderClickedParams{
+		ReminderID: id,
+		ClickedAt:  util.SqlTime(time.Now()),
+	})
+}
@@ -0,0 +1,52 @@
+package cartreminder_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/cartreminder"
+)
+
+func TestToken(t *testing.T) {
+	cartreminder.SetConfig(0, 0, "", "", "secret")
+
+	token := cartreminder.Token(42)
+	id, err := cartreminder.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Errorf("got reminder %d", id)
+	}
+
+	for _, tok := range []string{"", "42", "NDM" + token[3:], token + "x"} {
+		if _, err := cartreminder.ParseToken(tok); err != cartreminder.ErrInvalidToken {
+			t.Errorf("%q: expected an invalid token, got %v", tok, err)
+		}
+	}
+
+	// tokens are bound to the key
+	cartreminder.SetConfig(0, 0, "", "", "other")
+	if _, err := cartreminder.ParseToken(token); err != cartreminder.ErrInvalidToken {
+		t.Error("token signed with another key was accepted")
+	}
+}
+
+func TestLink(t *testing.T) {
+	cartreminder.SetConfig(0, 0, "", "https://example.com/checkout", "secret")
+	if link := cartreminder.Link(1); link != "https://example.com/checkout" {
+		t.Errorf("without a return url the link goes to the checkout, got %q", link)
+	}
+
+	cartreminder.SetConfig(0, 0, "https://api.example.com/cart-return?lang=id", "https://example.com/checkout", "secret")
+	link, err := url.Parse(cartreminder.Link(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Query().Get("lang") != "id" {
+		t.Errorf("existing query is lost: %s", link)
+	}
+	if id, err := cartreminder.ParseToken(link.Query().Get("token")); err != nil || id != 7 {
+		t.Errorf("got reminder %d, %v", id, err)
+	}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// DateRange is a reporting period grouped in day, week or month buckets,
+// the bucket names match postgres date_trunc fields
+type DateRange struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"` // inclusive
+	Interval string    `json:"interval"`
+}
+
+// End returns the exclusive upper bound of the range, to be used as `< end` in queries
+func (d DateRange) End() time.Time {
+	return d.To.AddDate(0, 0, 1)
+}
+
+// ParseDateRange read `from`, `to` (YYYY-MM-DD) and `interval` from the query string.
+// Without `to` the range ends today, without `from` it covers the last month,
+// or the last year for monthly buckets.
+func ParseDateRange(q url.Values, defaultInterval string) (DateRange, error) {
+	d := DateRange{Interval: q.Get("interval")}
+	if d.Interval == "" {
+		d.Interval = defaultInterval
+	}
+	if d.Interval != "day" && d.Interval != "week" && d.Interval != "month" {
+		return d, errors.New("interval must be one of day, week or month")
+	}
+
+	d.To = time.Now().Truncate(24 * time.Hour)
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return d, errors.New("invalid to date, use YYYY-MM-DD")
+		}
+		d.To = t
+	}
+
+	d.From = d.To.AddDate(0, -1, 0)
+	if d.Interval == "month" {
+		d.From = d.To.AddDate(-1, 0, 0)
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return d, errors.New("invalid from date, use YYYY-MM-DD")
+		}
+		d.From = t
+	}
+
+	if d.From.After(d.To) {
+		return d, errors.New("from must be before to")
+	}
+
+	return d, nil
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSV create a csv writer, cells that a spreadsheet would evaluate as a formula are escaped
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(record []string) error {
+	row := make([]string, len(record))
+	for i, v := range record {
+		row[i] = escapeFormula(v)
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+// escapeFormula prefix values starting with a formula character, negative numbers are kept as is
+func escapeFormula(v string) string {
+	if v == "" {
+		return v
+	}
+
+	switch v[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v
+		}
+		return "'" + v
+	}
+
+	return v
+}
@@ -0,0 +1,44 @@
+package export
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer writes a table row by row, nothing but the current row is kept in memory
+type Writer interface {
+	Write(record []string) error
+	// Flush pushes the buffered rows to the underlying writer
+	Flush() error
+	// Close finishes the document, it does not close the underlying writer
+	Close() error
+}
+
+// New create a writer for the given format
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSV(w), nil
+	case FormatXLSX:
+		return NewXLSX(w)
+	}
+
+	return nil, errors.New("format must be one of csv or xlsx")
+}
+
+// ContentType returns the mime type of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	return "application/octet-stream"
+}
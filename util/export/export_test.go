@@ -0,0 +1,105 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/export"
+)
+
+func write(t *testing.T, format string, rows [][]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := export.New(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	out := write(t, export.FormatCSV, [][]string{
+		{"id", "name", "amount"},
+		{"1", "Budi, S.Kom", "-1500"},
+		{"2", "=HYPERLINK(\"x\")", "2000"},
+	})
+
+	want := "id,name,amount\n" +
+		"1,\"Budi, S.Kom\",-1500\n" +
+		"2,\"'=HYPERLINK(\"\"x\"\")\",2000\n"
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	wide := make([]string, 28)
+	for i := range wide {
+		wide[i] = "x"
+	}
+
+	out := write(t, export.FormatXLSX, [][]string{
+		{"id", "name", "amount"},
+		{"1", "<Ani & Co>", "0812"},
+		wide,
+	})
+
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A2"><v>1</v></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">&lt;Ani &amp; Co&gt;</t></is></c>`,
+		`<c r="C2" t="inlineStr"><is><t xml:space="preserve">0812</t></is></c>`,
+		`<c r="AB3" t="inlineStr">`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet does not contain %s", want)
+		}
+	}
+	if !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Error("sheet is not closed")
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := export.New("pdf", io.Discard); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
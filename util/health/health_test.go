@@ -0,0 +1,74 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/online-bnsp/backend/util/health"
+)
+
+func TestCheck(t *testing.T) {
+	c := health.New(0, 50*time.Millisecond,
+		health.Check{Name: "db", Fn: func(ctx context.Context) error { return nil }},
+		health.Check{Name: "redis", Fn: func(ctx context.Context) error { return errors.New("connection refused") }},
+	)
+
+	report := c.Check(context.Background())
+	if report.Up() {
+		t.Error("a failing check must fail the report")
+	}
+	if len(report.Checks) != 2 {
+		t.Fatalf("got %d checks", len(report.Checks))
+	}
+	if report.Checks[0].Name != "db" || report.Checks[0].Status != health.StatusUp {
+		t.Errorf("got %+v", report.Checks[0])
+	}
+	if report.Checks[1].Status != health.StatusDown || report.Checks[1].Error != "connection refused" {
+		t.Errorf("got %+v", report.Checks[1])
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	c := health.New(0, 20*time.Millisecond, health.Check{Name: "nsq", Fn: func(ctx context.Context) error {
+		<-block // ignores the context
+		return nil
+	}})
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	if report.Up() || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("got %+v", report)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("a hanging check must not hold the report")
+	}
+}
+
+func TestCache(t *testing.T) {
+	var calls atomic.Int32
+	c := health.New(time.Hour, time.Second, health.Check{Name: "db", Fn: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	for i := 0; i < 3; i++ {
+		if !c.Check(context.Background()).Up() {
+			t.Fatal("expected up")
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("checks ran %d times", n)
+	}
+
+	c.Shutdown()
+	report := c.Check(context.Background())
+	if report.Up() || report.Error != health.ErrShuttingDown.Error() {
+		t.Errorf("got %+v", report)
+	}
+}
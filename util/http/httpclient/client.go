@@ -8,6 +8,7 @@ import (
 
 type option struct {
 	httpTransport http.RoundTripper
+	traced        bool
 }
 
 type Option func(*option)
@@ -15,6 +16,9 @@ type Option func(*option)
 // WithHTTPTransport set base transport before optionally traced. e.g., logged transport.
 func WithHTTPTransport(t http.RoundTripper) Option { return func(o *option) { o.httpTransport = t } }
 
+// WithTracing wrap the transport in a TracedTransport.
+func WithTracing() Option { return func(o *option) { o.traced = true } }
+
 type Config struct {
 	DialTimeout       time.Duration
 	ConnectionTimeout time.Duration
@@ -46,5 +50,8 @@ func New(cfg Config, opts ...Option) *http.Client {
 	if opt.httpTransport == nil {
 		opt.httpTransport = cfg.NewTransport()
 	}
+	if opt.traced {
+		opt.httpTransport = NewTracedTransport(opt.httpTransport)
+	}
 	return &http.Client{Transport: opt.httpTransport, Timeout: cfg.ConnectionTimeout}
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/tracing"
 	"github.com/rs/zerolog"
 )
 
@@ -43,11 +44,15 @@ func (t *LoggedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if res != nil {
 		statusCode = res.StatusCode
 	}
+	// correlates the timings with the span of the call, see TracedTransport
+	if traceID, spanID := tracing.IDs(req.Context()); traceID != "" {
+		lvl = lvl.Str("trace_id", traceID).Str("span_id", spanID)
+	}
 	lvl.
 		Str("module", t.logModuleName). // to make sure context or non-context logger has this value (for log filtering)
 		Str("req_method", req.Method).
 		Str("req_path", req.URL.Path).
-		Str("req_query", req.URL.RawQuery).
+		Str("req_query", logger.RedactQuery(req.URL.Query())).
 		Str("req_scheme", req.URL.Scheme).
 		Str("req_host", req.URL.Host).
 		Int("res_status", statusCode).
@@ -0,0 +1,177 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/preference"
+	"github.com/redis/go-redis/v9"
+)
+
+// stream events
+const (
+	EventNotification = "notification"
+	EventRead         = "read"
+)
+
+// Event is a domain event that ends up in the inbox of its recipients
+type Event struct {
+	Type     string // constant.Notification*
+	CourseID int32
+	Title    string
+	Message  string
+	Data     map[string]any
+}
+
+type Notification struct {
+	NotificationID int32           `json:"notification_id"`
+	UserID         int32           `json:"user_id"`
+	CourseID       int32           `json:"course_id,omitempty"`
+	Type           string          `json:"type"`
+	Title          string          `json:"title"`
+	Message        string          `json:"message"`
+	Data           json.RawMessage `json:"data"`
+	IsRead         bool            `json:"is_read"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ReadAt         *time.Time      `json:"read_at,omitempty"`
+}
+
+// Update is published on the channel of a user and forwarded to its open streams
+type Update struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Inbox stores notifications and pushes them to the connected clients over redis pub/sub,
+// a failed push is only logged since the notification is already stored
+type Inbox struct {
+	db    *repo.Queries
+	rdb   *redis.Client
+	prefs *preference.Preferences
+}
+
+func New(db *repo.Queries, rdb *redis.Client) *Inbox {
+	return &Inbox{db, rdb, preference.New(db)}
+}
+
+// WithQueries return a copy of the inbox storing the notifications through q, to notify in the
+// transaction of q. The push still goes out before the transaction commits
+func (i *Inbox) WithQueries(q *repo.Queries) *Inbox {
+	return &Inbox{q, i.rdb, i.prefs}
+}
+
+// Channel is the redis pub/sub channel of a user
+func Channel(userID int32) string {
+	return fmt.Sprintf("notification:%d", userID)
+}
+
+// Notify add the event to the inbox of the user, nothing is stored when the user
+// turned the in-app channel of the event off
+func (i *Inbox) Notify(ctx context.Context, userID int32, e Event) (Notification, error) {
+	allowed, err := i.prefs.Allowed(ctx, userID, e.Type, preference.ChannelInApp)
+	if err != nil || !allowed {
+		return Notification{}, err
+	}
+
+	data, err := json.Marshal(e.data())
+	if err != nil {
+		return Notification{}, err
+	}
+
+	d, err := i.db.CreateNotification(ctx, repo.CreateNotificationParams{
+		UserID:    sql.NullInt32{Int32: userID, Valid: true},
+		CourseID:  sql.NullInt32{Int32: e.CourseID, Valid: e.CourseID != 0},
+		Type:      e.Type,
+		Title:     e.Title,
+		Message:   sql.NullString{String: e.Message, Valid: true},
+		Data:      data,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+
+	n := ToNotification(d)
+	i.Publish(ctx, userID, EventNotification, n)
+
+	return n, nil
+}
+
+// NotifyCourse add the event to the inbox of every student subscribed to the course
+// who did not turn its in-app channel off
+func (i *Inbox) NotifyCourse(ctx context.Context, courseID int32, e Event) (int, error) {
+	data, err := json.Marshal(e.data())
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := i.db.CreateCourseNotifications(ctx, repo.CreateCourseNotificationsParams{
+		Type:      e.Type,
+		Title:     e.Title,
+		Message:   e.Message,
+		Data:      data,
+		CreatedAt: time.Now(),
+		CourseID:  sql.NullInt32{Int32: courseID, Valid: true},
+
+		DefaultEnabled: preference.Default(constant.RoleStudent, e.Type, preference.ChannelInApp),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range rows {
+		i.Publish(ctx, d.UserID.Int32, EventNotification, ToNotification(d))
+	}
+
+	return len(rows), nil
+}
+
+// Publish push an update to the open streams of the user
+func (i *Inbox) Publish(ctx context.Context, userID int32, event string, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		logger.Err(ctx, err).Msg("error encoding notification update")
+		return
+	}
+
+	payload, _ := json.Marshal(Update{Event: event, Data: raw})
+	if err := i.rdb.Publish(ctx, Channel(userID), payload).Err(); err != nil {
+		logger.Err(ctx, err).Msg("error publishing notification update")
+	}
+}
+
+// Subscribe to the updates of the user, the caller closes the subscription
+func (i *Inbox) Subscribe(ctx context.Context, userID int32) *redis.PubSub {
+	return i.rdb.Subscribe(ctx, Channel(userID))
+}
+
+func (e Event) data() map[string]any {
+	if e.Data == nil {
+		return map[string]any{}
+	}
+	return e.Data
+}
+
+func ToNotification(d repo.Notification) Notification {
+	n := Notification{
+		NotificationID: d.NotificationID,
+		UserID:         d.UserID.Int32,
+		CourseID:       d.CourseID.Int32,
+		Type:           d.Type,
+		Title:          d.Title,
+		Message:        d.Message.String,
+		Data:           d.Data,
+		IsRead:         d.IsRead,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.ReadAt.Valid {
+		n.ReadAt = &d.ReadAt.Time
+	}
+	return n
+}
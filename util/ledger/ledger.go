@@ -0,0 +1,43 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+// platform cut of every sale in basis points, the teacher earns the rest
+var platformShareBP int64 = 2000
+
+// SetConfig set the platform revenue share in percent (0-100)
+func SetConfig(platformShare float64) {
+	if platformShare >= 0 && platformShare <= 100 {
+		platformShareBP = int64(platformShare * 100)
+	}
+}
+
+// PlatformShare is the platform revenue share in percent
+func PlatformShare() float64 {
+	return float64(platformShareBP) / 100
+}
+
+// PostSales post the sale journals of a paid payment, or of every paid payment when paymentID
+// is 0. q has to run in the transaction that marks the payment paid, journals already posted
+// are skipped
+func PostSales(ctx context.Context, q *repo.Queries, paymentID int32) error {
+	_, err := q.PostSaleEntries(ctx, repo.PostSaleEntriesParams{
+		PlatformShareBp: platformShareBP,
+		PaidStatus:      sql.NullInt32{Int32: constant.PaymentPaid, Valid: true},
+		PaymentID:       sql.NullInt32{Int32: paymentID, Valid: paymentID != 0},
+	})
+	return err
+}
+
+// PostRefunds reverse the sale journal of a refunded transaction, or of every refunded one when
+// transactionID is 0
+func PostRefunds(ctx context.Context, q *repo.Queries, transactionID int32) error {
+	_, err := q.PostRefundEntries(ctx, sql.NullInt32{Int32: transactionID, Valid: transactionID != 0})
+	return err
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the sensitive values in the logs
+const Redacted = "[REDACTED]"
+
+// sensitive are the lower case key fragments whose values are never logged
+var sensitive = []string{"password", "token", "secret", "cookie", "authorization", "otp", "api_key", "apikey"}
+
+// IsSensitive report whether the value of a header, query or json key must not be logged
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactQuery returns the encoded query with the sensitive values replaced
+func RedactQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	out := url.Values{}
+	for k, v := range q {
+		if IsSensitive(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out.Encode()
+}
+
+// RedactHeaders returns a copy of the headers with the sensitive values replaced
+func RedactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if IsSensitive(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// RedactJSON returns the json document with the values of the sensitive keys replaced at any
+// depth, a payload that is not json is fully redacted since its content is unknown
+func RedactJSON(payload []byte) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	out, err := json.Marshal(redact(v))
+	if err != nil {
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	return out
+}
+
+func redact(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if IsSensitive(k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = redact(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = redact(val)
+		}
+	}
+	return v
+}
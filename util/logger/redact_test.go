@@ -0,0 +1,43 @@
+package logger_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/logger"
+)
+
+func TestRedactJSON(t *testing.T) {
+	got := string(logger.RedactJSON([]byte(`{"email":"ani@example.com","password":"hunter2","to":{"phone":"0812","otp_code":"123456"},"items":[{"refresh_token":"abc"}]}`)))
+	want := `{"email":"ani@example.com","items":[{"refresh_token":"[REDACTED]"}],"password":"[REDACTED]","to":{"otp_code":"[REDACTED]","phone":"0812"}}`
+	if got != want {
+		t.Errorf("got %s", got)
+	}
+
+	if got := string(logger.RedactJSON([]byte("password=hunter2"))); got != `"[REDACTED]"` {
+		t.Errorf("non json payload leaked: %s", got)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	q, _ := url.ParseQuery("token=a.b&page=2&Password=x")
+	if got := logger.RedactQuery(q); got != "Password=%5BREDACTED%5D&page=2&token=%5BREDACTED%5D" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cookie", "token=abc")
+	h.Set("Authorization", "Bearer abc")
+	h.Set("Accept", "application/json")
+
+	got := logger.RedactHeaders(h)
+	if got.Get("Cookie") != logger.Redacted || got.Get("Authorization") != logger.Redacted {
+		t.Errorf("got %v", got)
+	}
+	if got.Get("Accept") != "application/json" || h.Get("Cookie") != "token=abc" {
+		t.Error("other headers and the original must be kept")
+	}
+}
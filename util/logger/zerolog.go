@@ -2,13 +2,21 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"io"
+	"log"
 	"os"
+	"runtime/debug"
 	"strings"
 
 	"github.com/rs/zerolog"
 )
 
+// Err asks for the stack of every error, whichever logger it goes to
+func init() {
+	zerolog.ErrorStackMarshaler = callerStack
+}
+
 type Config struct {
 	Level  string
 	Output string
@@ -36,5 +44,68 @@ func New(cfg Config) zerolog.Logger {
 	return zerolog.New(output).With().Timestamp().Logger()
 }
 
-// FromContext fetch logger from given context or return disabled logger if not exist.
-func FromContext(ctx context.Context) *zerolog.Logger { return zerolog.Ctx(ctx) }
+// SetDefault make l the logger of the contexts without a request logger, the
+// standard log package is redirected to it as well
+func SetDefault(l zerolog.Logger) {
+	zerolog.DefaultContextLogger = &l
+	log.SetFlags(0)
+	log.SetOutput(l)
+}
+
+type requestKey struct{}
+
+// WithContext returns ctx carrying l, the request scoped logger returned by FromContext
+func WithContext(ctx context.Context, l *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, requestKey{}, l)
+}
+
+// FromContext fetch logger from given context, the default logger when there is none or
+// a disabled logger if no default is set.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(requestKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	return zerolog.Ctx(ctx)
+}
+
+// WithUser returns ctx carrying a child of its logger with the authenticated user
+func WithUser(ctx context.Context, userID int32, role string) context.Context {
+	l := FromContext(ctx).With().Int32("user_id", userID).Str("role", role).Logger()
+	return WithContext(ctx, &l)
+}
+
+// Err starts an error level event with err, its root cause, the caller and the stack
+func Err(ctx context.Context, err error) *zerolog.Event {
+	return withCause(FromContext(ctx).Error().Stack(), err)
+}
+
+// Warn starts a warn level event with err, e.g. for requests rejected because of the client
+func Warn(ctx context.Context, err error) *zerolog.Event {
+	return withCause(FromContext(ctx).Warn(), err)
+}
+
+func withCause(e *zerolog.Event, err error) *zerolog.Event {
+	e = e.Err(err).Caller(2)
+	if cause := rootCause(err); cause != err {
+		e = e.Str("cause", cause.Error())
+	}
+	return e
+}
+
+// callerStack is the stack of the goroutine logging the error, the errors of the app do not
+// record where they were made
+func callerStack(err error) interface{} {
+	return string(debug.Stack())
+}
+
+// rootCause follows the wrapped errors down to the innermost one
+func rootCause(err error) error {
+	for err != nil {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/rs/zerolog"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf).With().Str("request_id", "r1").Logger()
+	ctx := logger.WithContext(context.Background(), &l)
+
+	// the user is authenticated after the logger was attached
+	userCtx := logger.WithUser(ctx, 7, "student")
+
+	cause := errors.New("connection refused")
+	logger.Err(userCtx, fmt.Errorf("fetching cart: %w", cause)).Msg("error in getting cart")
+
+	got := buf.String()
+	for _, want := range []string{`"request_id":"r1"`, `"user_id":7`, `"role":"student"`, `"error":"fetching cart: connection refused"`, `"cause":"connection refused"`, `zerolog_test.go`, `"stack":"goroutine`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %s", want, got)
+		}
+	}
+}
+
+func TestWithUserDerivesLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger.SetDefault(zerolog.New(&buf))
+
+	// the logger of ctx is shared, the user only goes to the child
+	ctx := logger.WithUser(context.Background(), 7, "student")
+	logger.FromContext(context.Background()).Info().Msg("job done")
+	if strings.Contains(buf.String(), "user_id") {
+		t.Errorf("default logger was modified: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.FromContext(ctx).Info().Msg("cart fetched")
+	if !strings.Contains(buf.String(), `"user_id":7`) {
+		t.Errorf("missing user in %s", buf.String())
+	}
+}
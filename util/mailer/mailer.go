@@ -1,29 +1,143 @@
 package mailer
 
 import (
-	"fmt"
+	"bytes"
 	"time"
 
 	"github.com/go-mail/mail"
 )
 
-// TODO: Templating
-
 type Mailer struct {
-	mail   *mail.Dialer
-	sender string
+	mail      *mail.Dialer
+	sender    string
+	app       string
+	templates *Templates
+}
+
+// Recipient of a message, an empty locale uses the default locale of the templates
+type Recipient struct {
+	Email  string
+	Name   string
+	Locale string
+	UserID int32 // 0 when the address does not belong to an account
+
+	// Unsubscribe is the link shown in the footer of marketing mails
+	Unsubscribe string
+}
+
+type attachment struct {
+	filename string
+	content  []byte
 }
 
-func New(m *mail.Dialer, senderEmail string) *Mailer {
-	return &Mailer{m, senderEmail}
+func New(m *mail.Dialer, senderEmail, appName string, templates *Templates) *Mailer {
+	return &Mailer{m, senderEmail, appName, templates}
 }
 
-func (m *Mailer) SendResetCode(targetEmail string, code string, validity time.Time) error {
+// send renders the template as a plain text message with an html alternative
+func (m *Mailer) send(to Recipient, name string, data any, attachments ...attachment) error {
+	rendered, err := m.templates.Render(name, Page{App: m.app, Locale: to.Locale, To: to, Data: data})
+	if err != nil {
+		return err
+	}
+
 	msg := mail.NewMessage()
 	msg.SetHeader("From", m.sender)
-	msg.SetHeader("To", targetEmail)
-	msg.SetHeader("Subject", "Forgot Password")
-	msg.SetBody("text/html", fmt.Sprintf("Your reset code is: %v\nValid before: %v\n", code, validity.Format("2006-01-02 15:04:05 MST")))
+	msg.SetAddressHeader("To", to.Email, to.Name)
+	msg.SetHeader("Subject", rendered.Subject)
+	if to.Unsubscribe != "" {
+		// one click unsubscribe from the mail client (RFC 8058)
+		msg.SetHeader("List-Unsubscribe", "<"+to.Unsubscribe+">")
+		msg.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	msg.SetBody("text/plain", rendered.Text)
+	msg.AddAlternative("text/html", rendered.HTML)
+	for _, a := range attachments {
+		msg.AttachReader(a.filename, bytes.NewReader(a.content))
+	}
 
 	return m.mail.DialAndSend(msg)
 }
+
+func (m *Mailer) SendWelcome(to Recipient) error {
+	return m.send(to, TemplateWelcome, nil)
+}
+
+type CodeMail struct {
+	Code       string
+	ValidUntil time.Time
+}
+
+func (m *Mailer) SendVerification(to Recipient, code string, validity time.Time) error {
+	return m.send(to, TemplateVerification, CodeMail{code, validity})
+}
+
+func (m *Mailer) SendResetCode(to Recipient, code string, validity time.Time) error {
+	return m.send(to, TemplateResetCode, CodeMail{code, validity})
+}
+
+type ReceiptMail struct {
+	InvoiceNumber string
+	Total         int64
+	IssuedAt      time.Time
+	Filename      string // of the attached pdf
+	PDF           []byte
+}
+
+// SendReceipt send the pdf receipt of a paid payment
+func (m *Mailer) SendReceipt(to Recipient, receipt ReceiptMail) error {
+	return m.send(to, TemplateReceipt, receipt, attachment{receipt.Filename, receipt.PDF})
+}
+
+type RefundMail struct {
+	CourseName string
+	Amount     int64
+	Reason     string
+	RefundedAt time.Time
+}
+
+func (m *Mailer) SendRefund(to Recipient, refund RefundMail) error {
+	return m.send(to, TemplateRefund, refund)
+}
+
+type CourseCompletionMail struct {
+	CourseName  string
+	CompletedAt time.Time
+}
+
+func (m *Mailer) SendCourseCompletion(to Recipient, completion CourseCompletionMail) error {
+	return m.send(to, TemplateCourseCompletion, completion)
+}
+
+// PriceDropMail is a wishlisted course that got cheaper, SaleEndsAt is zero
+// when the price itself was lowered
+type PriceDropMail struct {
+	CourseName string
+	OldPrice   int64
+	NewPrice   int64
+	SaleEndsAt time.Time
+}
+
+func (m *Mailer) SendPriceDrop(to Recipient, drop PriceDropMail) error {
+	return m.send(to, TemplatePriceDrop, drop)
+}
+
+// PriceDigestMail batches the price drops of a day
+type PriceDigestMail struct {
+	Alerts []PriceDropMail
+}
+
+func (m *Mailer) SendPriceDigest(to Recipient, digest PriceDigestMail) error {
+	return m.send(to, TemplatePriceDigest, digest)
+}
+
+// CartReminderMail points the owner of an abandoned cart back to the checkout
+type CartReminderMail struct {
+	Courses []string
+	Total   int64
+	Link    string
+}
+
+func (m *Mailer) SendCartReminder(to Recipient, reminder CartReminderMail) error {
+	return m.send(to, TemplateCartReminder, reminder)
+}
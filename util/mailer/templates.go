@@ -0,0 +1,197 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/online-bnsp/backend/util"
+)
+
+// default templates, every message has a `<locale>/<name>.txt` defining the `subject`
+// and the plain text `content`, and a `<locale>/<name>.html` defining the html `content`.
+// The shared layouts wrap the content, the `greeting` and `footer` come from `<locale>/partials`.
+//
+//go:embed templates
+var embedded embed.FS
+
+const (
+	LocaleEnglish    = "en"
+	LocaleIndonesian = "id"
+)
+
+// message templates
+const (
+	TemplateWelcome          = "welcome"
+	TemplateVerification     = "verification"
+	TemplateResetCode        = "reset_code"
+	TemplateReceipt          = "receipt"
+	TemplateRefund           = "refund"
+	TemplateCourseCompletion = "course_completion"
+	TemplatePriceDrop        = "price_drop"
+	TemplatePriceDigest      = "price_digest"
+	TemplateCartReminder     = "cart_reminder"
+)
+
+var templateNames = []string{
+	TemplateWelcome,
+	TemplateVerification,
+	TemplateResetCode,
+	TemplateReceipt,
+	TemplateRefund,
+	TemplateCourseCompletion,
+	TemplatePriceDrop,
+	TemplatePriceDigest,
+	TemplateCartReminder,
+}
+
+// Page is the data every template is executed with
+type Page struct {
+	App    string
+	Locale string
+	To     Recipient
+	Data   any
+}
+
+// Rendered is a message ready to be sent
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template // by locale/name
+	html          map[string]*htmltemplate.Template
+}
+
+var funcs = map[string]any{
+	"rupiah": util.FormatRupiah,
+	"date": func(t time.Time) string {
+		return t.Format("02 Jan 2006 15:04 MST")
+	},
+}
+
+// LoadTemplates parse the embedded templates, a file with the same path in dir replaces the embedded one.
+// Messages missing in a locale are sent in the default locale.
+func LoadTemplates(dir, defaultLocale string) (*Templates, error) {
+	base, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS = base
+	if dir != "" {
+		fsys = overlay{os.DirFS(dir), base}
+	}
+
+	if defaultLocale == "" {
+		defaultLocale = LocaleIndonesian
+	}
+
+	t := &Templates{
+		defaultLocale: defaultLocale,
+		text:          map[string]*texttemplate.Template{},
+		html:          map[string]*htmltemplate.Template{},
+	}
+
+	locales, err := fs.ReadDir(base, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range locales {
+		if !l.IsDir() {
+			continue
+		}
+		locale := l.Name()
+
+		for _, name := range templateNames {
+			txt := path.Join(locale, name+".txt")
+			if _, err := fs.Stat(fsys, txt); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			tt, err := texttemplate.New(name).Funcs(funcs).ParseFS(fsys, "layout.txt", path.Join(locale, "partials.txt"), txt)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", txt, err)
+			}
+			ht, err := htmltemplate.New(name).Funcs(funcs).ParseFS(fsys, "layout.html", path.Join(locale, "partials.html"), path.Join(locale, name+".html"))
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path.Join(locale, name+".html"), err)
+			}
+
+			t.text[locale+"/"+name] = tt
+			t.html[locale+"/"+name] = ht
+		}
+	}
+
+	if len(t.text) == 0 {
+		return nil, errors.New("no mail templates found")
+	}
+
+	return t, nil
+}
+
+// Render executes the subject, text and html templates of the message
+func (t *Templates) Render(name string, page Page) (Rendered, error) {
+	if page.Locale == "" {
+		page.Locale = t.defaultLocale
+	}
+
+	key := page.Locale + "/" + name
+	if _, ok := t.text[key]; !ok {
+		page.Locale = t.defaultLocale
+		key = page.Locale + "/" + name
+	}
+
+	tt, ok := t.text[key]
+	if !ok {
+		return Rendered{}, fmt.Errorf("mail template %s not found", name)
+	}
+
+	var res Rendered
+	var buf bytes.Buffer
+
+	if err := tt.ExecuteTemplate(&buf, "subject", page); err != nil {
+		return res, err
+	}
+	res.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tt.ExecuteTemplate(&buf, "layout", page); err != nil {
+		return res, err
+	}
+	res.Text = buf.String()
+
+	buf.Reset()
+	if err := t.html[key].ExecuteTemplate(&buf, "layout", page); err != nil {
+		return res, err
+	}
+	res.HTML = buf.String()
+
+	return res, nil
+}
+
+// overlay reads files from the override directory first
+type overlay struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlay) Open(name string) (fs.File, error) {
+	f, err := o.override.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
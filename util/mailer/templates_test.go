@@ -0,0 +1,162 @@
+package mailer_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-bnsp/backend/util/mailer"
+)
+
+var validUntil = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+var pages = map[string]any{
+	mailer.TemplateWelcome:          nil,
+	mailer.TemplateVerification:     mailer.CodeMail{Code: "123456", ValidUntil: validUntil},
+	mailer.TemplateResetCode:        mailer.CodeMail{Code: "654321", ValidUntil: validUntil},
+	mailer.TemplateReceipt:          mailer.ReceiptMail{InvoiceNumber: "INV/2024/000001", Total: 1250000, IssuedAt: validUntil},
+	mailer.TemplateRefund:           mailer.RefundMail{CourseName: "Golang", Amount: 150000, Reason: "duplicate", RefundedAt: validUntil},
+	mailer.TemplateCourseCompletion: mailer.CourseCompletionMail{CourseName: "Golang", CompletedAt: validUntil},
+	mailer.TemplatePriceDrop:        mailer.PriceDropMail{CourseName: "Golang", OldPrice: 200000, NewPrice: 150000, SaleEndsAt: validUntil},
+	mailer.TemplatePriceDigest: mailer.PriceDigestMail{Alerts: []mailer.PriceDropMail{
+		{CourseName: "Golang", OldPrice: 200000, NewPrice: 150000},
+		{CourseName: "Rust", OldPrice: 300000, NewPrice: 100000, SaleEndsAt: validUntil},
+	}},
+	mailer.TemplateCartReminder: mailer.CartReminderMail{Courses: []string{"Golang", "Rust"}, Total: 500000, Link: "https://example.com/cart/return?token=a.b"},
+}
+
+func TestRenderAllTemplates(t *testing.T) {
+	templates, err := mailer.LoadTemplates("", mailer.LocaleIndonesian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, locale := range []string{mailer.LocaleEnglish, mailer.LocaleIndonesian} {
+		for name, data := range pages {
+			res, err := templates.Render(name, mailer.Page{
+				App:    "Online BNSP",
+				Locale: locale,
+				To:     mailer.Recipient{Email: "ani@example.com", Name: "Ani <b>"},
+				Data:   data,
+			})
+			if err != nil {
+				t.Errorf("%s/%s: %v", locale, name, err)
+				continue
+			}
+
+			if res.Subject == "" || strings.Contains(res.Subject, "\n") {
+				t.Errorf("%s/%s: invalid subject %q", locale, name, res.Subject)
+			}
+			if !strings.Contains(res.Text, "Ani <b>") {
+				t.Errorf("%s/%s: plain text is missing the greeting", locale, name)
+			}
+			if !strings.Contains(res.HTML, "Ani &lt;b&gt;") || strings.Contains(res.HTML, "Ani <b>") {
+				t.Errorf("%s/%s: name is not escaped in html", locale, name)
+			}
+			if !strings.Contains(res.HTML, `lang="`+locale+`"`) {
+				t.Errorf("%s/%s: html is not wrapped in the layout", locale, name)
+			}
+		}
+	}
+}
+
+func TestRenderContent(t *testing.T) {
+	templates, err := mailer.LoadTemplates("", mailer.LocaleIndonesian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := templates.Render(mailer.TemplateReceipt, mailer.Page{
+		Locale: mailer.LocaleEnglish,
+		To:     mailer.Recipient{Name: "Ani"},
+		Data:   pages[mailer.TemplateReceipt],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Subject != "Payment Receipt INV/2024/000001" {
+		t.Errorf("got subject %q", res.Subject)
+	}
+	if !strings.Contains(res.Text, "Total paid: Rp 1.250.000") {
+		t.Errorf("plain text does not contain the total:\n%s", res.Text)
+	}
+
+	// unknown locales use the default one
+	res, err = templates.Render(mailer.TemplateResetCode, mailer.Page{
+		Locale: "fr",
+		To:     mailer.Recipient{Name: "Ani"},
+		Data:   pages[mailer.TemplateResetCode],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Subject != "Lupa Kata Sandi" {
+		t.Errorf("got subject %q", res.Subject)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filepath.Join(dir, "en", "welcome.txt"), []byte(`{{define "subject"}}Hello from {{.App}}{{end}}{{define "content"}}custom{{end}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := mailer.LoadTemplates(dir, mailer.LocaleEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := templates.Render(mailer.TemplateWelcome, mailer.Page{App: "Kaos", To: mailer.Recipient{Name: "Ani"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Subject != "Hello from Kaos" || !strings.Contains(res.Text, "custom") {
+		t.Errorf("override not applied: %q %q", res.Subject, res.Text)
+	}
+
+	// templates that are not overridden are still embedded
+	if _, err := templates.Render(mailer.TemplateRefund, mailer.Page{Data: pages[mailer.TemplateRefund]}); err != nil {
+		t.Error(err)
+	}
+
+	// a broken override is reported
+	os.WriteFile(filepath.Join(dir, "en", "welcome.txt"), []byte(`{{define "subject"}}`), 0o644)
+	if _, err := mailer.LoadTemplates(dir, mailer.LocaleEnglish); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestUnsubscribeFooter(t *testing.T) {
+	templates, err := mailer.LoadTemplates("", mailer.LocaleEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := mailer.Page{To: mailer.Recipient{Name: "Ani"}, Data: pages[mailer.TemplateRefund]}
+	res, err := templates.Render(mailer.TemplateRefund, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res.Text, "Unsubscribe") {
+		t.Error("transactional mails have no unsubscribe link")
+	}
+
+	page.To.Unsubscribe = "https://example.com/unsubscribe?token=a.b&x=1"
+	res, err = templates.Render(mailer.TemplateRefund, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "Unsubscribe: https://example.com/unsubscribe?token=a.b&x=1") {
+		t.Errorf("plain text is missing the unsubscribe link:\n%s", res.Text)
+	}
+	if !strings.Contains(res.HTML, `href="https://example.com/unsubscribe?token=a.b&amp;x=1"`) {
+		t.Errorf("html is missing the unsubscribe link:\n%s", res.HTML)
+	}
+}
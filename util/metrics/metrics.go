@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kaos"
+
+// RouteNotFound is the route label of the requests no route matched, the raw path
+// would give every scanned url its own series
+const RouteNotFound = "not_found"
+
+// outcome of a consumed message
+const (
+	ResultSuccess    = "success"
+	ResultRetry      = "retry"
+	ResultDeadLetter = "dead_letter"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, chi route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	queuePublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_published_total",
+		Help:      "Messages published to the queue by topic.",
+	}, []string{"topic"})
+
+	queuePublishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_publish_failures_total",
+		Help:      "Messages that could not be published by topic.",
+	}, []string{"topic"})
+
+	queueConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_consumed_total",
+		Help:      "Messages handled by topic, channel and result (success, retry or dead_letter).",
+	}, []string{"topic", "channel", "result"})
+
+	queueDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_handler_duration_seconds",
+		Help:      "Time spent in the message handlers by topic and channel.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"topic", "channel"})
+
+	registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Accounts registered by role.",
+	}, []string{"role"})
+
+	checkouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkouts_total",
+		Help:      "Payments created from a cart.",
+	})
+
+	paymentsPaid = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_paid_total",
+		Help:      "Payments confirmed as paid.",
+	})
+
+	revenue = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_rupiah_total",
+		Help:      "Invoiced amount of the paid payments in rupiah, tax included.",
+	})
+)
+
+var roles = map[string]bool{"student": true, "teacher": true, "admin": true}
+
+// Handler serves the metrics of the default registry in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB export the connection pool stats of the database, registering the same
+// database twice is a no-op
+func RegisterDB(db *sql.DB, name string) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, name))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+// ObserveRequest record a served request, route is the chi route pattern
+func ObserveRequest(method, route, status string, seconds float64) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route, status).Observe(seconds)
+}
+
+// ObservePublish record a message published to the topic, err is the publish error
+func ObservePublish(topic string, err error) {
+	if err != nil {
+		queuePublishFailures.WithLabelValues(topic).Inc()
+		return
+	}
+	queuePublished.WithLabelValues(topic).Inc()
+}
+
+// ObserveConsume record a handled message, result is one of the Result* constants
+func ObserveConsume(topic, channel, result string, seconds float64) {
+	queueConsumed.WithLabelValues(topic, channel, result).Inc()
+	queueDuration.WithLabelValues(topic, channel).Observe(seconds)
+}
+
+// Registered count a new account, unknown roles are counted as "other"
+func Registered(role string) {
+	if !roles[role] {
+		role = "other"
+	}
+	registrations.WithLabelValues(role).Inc()
+}
+
+// CheckedOut count a payment created from a cart
+func CheckedOut() {
+	checkouts.Inc()
+}
+
+// PaymentPaid count a confirmed payment and its amount
+func PaymentPaid(amount int64) {
+	paymentsPaid.Inc()
+	revenue.Add(float64(amount))
+}
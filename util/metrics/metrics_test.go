@@ -0,0 +1,65 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/online-bnsp/backend/util/metrics"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestExposition(t *testing.T) {
+	metrics.ObserveRequest("GET", "/public/get-course/{course_id}", "200", 0.02)
+	metrics.ObservePublish("notification", nil)
+	metrics.ObservePublish("notification", errors.New("connection refused"))
+	metrics.ObserveConsume("notification", "kaos_notification", metrics.ResultRetry, 0.5)
+	metrics.Registered("student")
+	metrics.Registered("root")
+	metrics.PaymentPaid(150000)
+
+	body := scrape(t)
+	for _, want := range []string{
+		`kaos_http_requests_total{method="GET",route="/public/get-course/{course_id}",status="200"} 1`,
+		`kaos_http_request_duration_seconds_count{method="GET",route="/public/get-course/{course_id}",status="200"} 1`,
+		`kaos_queue_published_total{topic="notification"} 1`,
+		`kaos_queue_publish_failures_total{topic="notification"} 1`,
+		`kaos_queue_consumed_total{channel="kaos_notification",result="retry",topic="notification"} 1`,
+		`kaos_registrations_total{role="student"} 1`,
+		`kaos_registrations_total{role="other"} 1`,
+		`kaos_payments_paid_total 1`,
+		`kaos_revenue_rupiah_total 150000`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestRegisterDB(t *testing.T) {
+	// the collector only reads db.Stats, no connection is opened
+	db, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		t.Errorf("registering twice: %v", err)
+	}
+	if !strings.Contains(scrape(t), `go_sql_open_connections{db_name="postgres"} 0`) {
+		t.Error("pool stats are not exported")
+	}
+}
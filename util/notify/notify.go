@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/preference"
+	queue "github.com/online-bnsp/backend/util/queue"
+)
+
+const (
+	ChannelEmail    = preference.ChannelEmail
+	ChannelWhatsapp = preference.ChannelWhatsapp
+)
+
+// templateEvents is the preference event of a mail template,
+// templates missing here (verification, password reset) are always delivered
+var templateEvents = map[string]string{
+	mailer.TemplateReceipt:          constant.NotificationPurchase,
+	mailer.TemplateRefund:           constant.NotificationRefund,
+	mailer.TemplateCourseCompletion: constant.NotificationCompletion,
+	mailer.TemplatePriceDrop:        constant.NotificationPriceAlert,
+	mailer.TemplatePriceDigest:      constant.NotificationPriceAlert,
+	mailer.TemplateCartReminder:     constant.NotificationCartReminder,
+}
+
+// Message is published to the notification topic and delivered by the consumer,
+// ID identifies the delivery in the delivery log across retries
+type Message struct {
+	ID        string           `json:"id"`
+	Channel   string           `json:"channel"`
+	Event     string           `json:"event,omitempty"`    // checked against the preferences of To.UserID
+	Template  string           `json:"template,omitempty"` // mail template, see mailer.Template*
+	To        mailer.Recipient `json:"to"`                 // UserID is also set for whatsapp messages
+	Phone     string           `json:"phone,omitempty"`
+	Text      string           `json:"text,omitempty"` // whatsapp message
+	Data      json.RawMessage  `json:"data,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Publisher queues outbound notifications instead of sending them inline in a request
+type Publisher struct {
+	producer queue.Producer
+}
+
+func NewPublisher(producer queue.Producer) *Publisher {
+	return &Publisher{producer}
+}
+
+// Email queue a templated mail, data is the typed mail of the template (e.g. mailer.ReceiptMail)
+func (p *Publisher) Email(ctx context.Context, to mailer.Recipient, template string, data any) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	return p.publish(ctx, Message{
+		Channel:  ChannelEmail,
+		Event:    templateEvents[template],
+		Template: template,
+		To:       to,
+		Data:     raw,
+	})
+}
+
+// Whatsapp queue a plain text whatsapp message, an empty event is always delivered (e.g. OTP codes)
+func (p *Publisher) Whatsapp(ctx context.Context, userID int32, event, phone, text string) (string, error) {
+	return p.publish(ctx, Message{
+		Channel: ChannelWhatsapp,
+		Event:   event,
+		To:      mailer.Recipient{UserID: userID},
+		Phone:   phone,
+		Text:    text,
+	})
+}
+
+func (p *Publisher) publish(ctx context.Context, m Message) (string, error) {
+	m.ID = uuid.NewString()
+	m.CreatedAt = time.Now()
+
+	return m.ID, p.producer.Publish(ctx, constant.Notification, m)
+}
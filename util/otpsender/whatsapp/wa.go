@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 )
 
@@ -28,7 +27,7 @@ func (cl *Client) Send(ctx context.Context, phone, msg string) error {
 	var buf bytes.Buffer
 	err := json.NewEncoder(&buf).Encode(payload)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error on encoding request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cl.url, &buf)
@@ -44,14 +43,14 @@ func (cl *Client) Send(ctx context.Context, phone, msg string) error {
 	if err != nil {
 		return fmt.Errorf("error on sending request: %w", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		errResp := WhatsaappSendResponse{}
 		if err = json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return err
+			return fmt.Errorf("unexpected status %d: %w", resp.StatusCode, err)
 		}
-		fmt.Printf("%+v \n\n", errResp)
-		return fmt.Errorf("try again later")
+		return fmt.Errorf("unexpected status %d: %s %s", resp.StatusCode, errResp.Code, errResp.Message)
 	}
 
 	return nil
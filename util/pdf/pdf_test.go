@@ -0,0 +1,63 @@
+package pdf_test
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/pdf"
+)
+
+func TestDocument(t *testing.T) {
+	d := pdf.New()
+	d.Text(40, 60, 18, true, "Receipt (INV/2026/000001)")
+	d.Line(40, 70, 555, 70)
+	d.TextRight(555, 90, 10, false, "Rp 150.000")
+	d.AddPage()
+	d.Text(40, 60, 10, false, `C:\path and naïve ☃`)
+
+	out := d.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing pdf header or trailer")
+	}
+	if !bytes.Contains(out, []byte(`(Receipt \(INV/2026/000001\)) Tj`)) {
+		t.Error("parentheses are not escaped")
+	}
+	if !bytes.Contains(out, []byte("(C:\\\\path and na\xefve ?) Tj")) {
+		t.Error("text is not encoded as WinAnsi")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("expected two pages")
+	}
+
+	// every xref entry points at the start of its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(out[xref:]), "\n")
+	for i, l := range lines[3:] {
+		if !strings.HasSuffix(l, " n ") {
+			break
+		}
+		offset, _ := strconv.Atoi(l[:10])
+		want := strconv.Itoa(i+1) + " 0 obj"
+		if !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Errorf("xref entry %d does not point at %q", i+1, want)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	// digits are 556/1000 em in both weights
+	if w := pdf.TextWidth("100", 10, false); w != 16.68 {
+		t.Errorf("got width %v", w)
+	}
+	if pdf.TextWidth("Total", 10, true) <= pdf.TextWidth("Total", 10, false) {
+		t.Error("bold text should be wider")
+	}
+}
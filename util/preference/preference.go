@@ -0,0 +1,222 @@
+package preference
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+)
+
+// delivery channels
+const (
+	ChannelInApp    = "in_app"
+	ChannelEmail    = "email"
+	ChannelWhatsapp = "whatsapp"
+)
+
+var Channels = []string{ChannelInApp, ChannelEmail, ChannelWhatsapp}
+
+// how often the emails of a digest event are sent
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+)
+
+// Event is a type of notification users can turn on or off per channel,
+// messages without an event (verification, password reset) are always sent
+type Event struct {
+	Type      string `json:"event"`
+	Marketing bool   `json:"marketing"` // emails carry an unsubscribe link
+	Digest    bool   `json:"digest"`    // emails can be batched in a daily digest
+}
+
+var Events = []Event{
+	{Type: constant.NotificationGeneral},
+	{Type: constant.NotificationPurchase},
+	{Type: constant.NotificationNewLesson},
+	{Type: constant.NotificationReviewReply},
+	{Type: constant.NotificationRefund},
+	{Type: constant.NotificationCompletion},
+	{Type: constant.NotificationMarketing, Marketing: true},
+	{Type: constant.NotificationPriceAlert, Marketing: true, Digest: true},
+	{Type: constant.NotificationCartReminder, Marketing: true},
+}
+
+// defaults are the channels enabled for a role until the user changes them
+var defaults = map[string]map[string][]string{
+	constant.RoleStudent: {
+		constant.NotificationGeneral:      {ChannelInApp},
+		constant.NotificationPurchase:     {ChannelInApp, ChannelEmail},
+		constant.NotificationNewLesson:    {ChannelInApp, ChannelEmail},
+		constant.NotificationReviewReply:  {ChannelInApp, ChannelEmail},
+		constant.NotificationRefund:       {ChannelInApp, ChannelEmail},
+		constant.NotificationCompletion:   {ChannelInApp, ChannelEmail},
+		constant.NotificationMarketing:    {ChannelEmail},
+		constant.NotificationPriceAlert:   {ChannelInApp, ChannelEmail},
+		constant.NotificationCartReminder: {ChannelEmail},
+	},
+	constant.RoleTeacher: {
+		constant.NotificationGeneral:     {ChannelInApp, ChannelEmail},
+		constant.NotificationPurchase:    {ChannelInApp, ChannelEmail},
+		constant.NotificationNewLesson:   {ChannelInApp},
+		constant.NotificationReviewReply: {ChannelInApp},
+		constant.NotificationRefund:      {ChannelInApp, ChannelEmail},
+		constant.NotificationCompletion:  {ChannelInApp},
+	},
+	constant.RoleAdmin: {
+		constant.NotificationGeneral: {ChannelInApp},
+		constant.NotificationRefund:  {ChannelInApp},
+	},
+}
+
+// Default report whether the channel is on for the role when the user did not choose
+func Default(role, event, channel string) bool {
+	for _, c := range defaults[role][event] {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func Find(event string) (Event, bool) {
+	for _, e := range Events {
+		if e.Type == event {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+func validChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Setting is one event × channel choice of a user, Frequency is only set
+// on the email channel of digest events
+type Setting struct {
+	Event     string `json:"event"`
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	Frequency string `json:"frequency,omitempty"`
+}
+
+// Preferences decides whether a notification is delivered
+type Preferences struct {
+	db *repo.Queries
+}
+
+func New(db *repo.Queries) *Preferences {
+	return &Preferences{db}
+}
+
+// Allowed report whether the user wants the event on the channel, an empty event
+// or a message without a registered user is always allowed
+func (p *Preferences) Allowed(ctx context.Context, userID int32, event, channel string) (bool, error) {
+	if event == "" || userID == 0 {
+		return true, nil
+	}
+
+	d, err := p.db.GetNotificationPreference(ctx, repo.GetNotificationPreferenceParams{
+		UserID:    userID,
+		EventType: event,
+		Channel:   channel,
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if d.Enabled.Valid {
+		return d.Enabled.Bool, nil
+	}
+	return Default(d.Role, event, channel), nil
+}
+
+// Get every event × channel setting of the user, stored choices over role defaults
+func (p *Preferences) Get(ctx context.Context, userID int32, role string) ([]Setting, error) {
+	stored, err := p.db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := map[string]repo.NotificationPreference{}
+	for _, s := range stored {
+		chosen[s.EventType+"/"+s.Channel] = s
+	}
+
+	res := []Setting{}
+	for _, e := range Events {
+		for _, c := range Channels {
+			s := Setting{Event: e.Type, Channel: c, Enabled: Default(role, e.Type, c)}
+			if e.Digest && c == ChannelEmail {
+				s.Frequency = FrequencyInstant
+			}
+
+			if stored, ok := chosen[e.Type+"/"+c]; ok {
+				s.Enabled = stored.Enabled
+				if s.Frequency != "" {
+					s.Frequency = stored.Frequency
+				}
+			}
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// Validate reject unknown events or channels
+func Validate(settings []Setting) error {
+	for _, s := range settings {
+		if _, ok := Find(s.Event); !ok {
+			return fmt.Errorf("unknown event %q", s.Event)
+		}
+		if !validChannel(s.Channel) {
+			return fmt.Errorf("unknown channel %q", s.Channel)
+		}
+		if s.Frequency == "" {
+			continue
+		}
+		if e, _ := Find(s.Event); !e.Digest || s.Channel != ChannelEmail {
+			return fmt.Errorf("%s %s has no digest", s.Event, s.Channel)
+		}
+		if s.Frequency != FrequencyInstant && s.Frequency != FrequencyDaily {
+			return fmt.Errorf("unknown frequency %q", s.Frequency)
+		}
+	}
+	return nil
+}
+
+// Set store the choices of the user
+func (p *Preferences) Set(ctx context.Context, userID int32, settings []Setting) error {
+	if err := Validate(settings); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, s := range settings {
+		err := p.db.SetNotificationPreference(ctx, repo.SetNotificationPreferenceParams{
+			UserID:    userID,
+			EventType: s.Event,
+			Channel:   s.Channel,
+			Enabled:   s.Enabled,
+			Frequency: sql.NullString{String: s.Frequency, Valid: s.Frequency != ""},
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
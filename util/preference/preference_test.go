@@ -0,0 +1,103 @@
+package preference_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/util/preference"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	preference.SetUnsubscribeConfig("secret", "https://example.com/unsubscribe")
+
+	token := preference.UnsubscribeToken(42, constant.NotificationMarketing)
+	userID, event, err := preference.ParseUnsubscribeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 42 || event != constant.NotificationMarketing {
+		t.Errorf("got %d %q", userID, event)
+	}
+
+	// tampering with the payload or the signature is rejected
+	payload, mac, _ := strings.Cut(token, ".")
+	forged := preference.UnsubscribeToken(43, constant.NotificationMarketing)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	for _, tok := range []string{forgedPayload + "." + mac, payload + ".AAAA", payload, "", "..."} {
+		if _, _, err := preference.ParseUnsubscribeToken(tok); err != preference.ErrInvalidToken {
+			t.Errorf("token %q: expected invalid token, got %v", tok, err)
+		}
+	}
+
+	// a token signed with another key is rejected
+	preference.SetUnsubscribeConfig("other", "")
+	if _, _, err := preference.ParseUnsubscribeToken(token); err != preference.ErrInvalidToken {
+		t.Errorf("expected invalid token, got %v", err)
+	}
+}
+
+func TestUnsubscribeURL(t *testing.T) {
+	preference.SetUnsubscribeConfig("secret", "")
+	if link := preference.UnsubscribeURL(1, constant.NotificationMarketing); link != "" {
+		t.Errorf("expected no link without a configured url, got %q", link)
+	}
+
+	preference.SetUnsubscribeConfig("secret", "https://example.com/unsubscribe?lang=id")
+	link, err := url.Parse(preference.UnsubscribeURL(1, constant.NotificationMarketing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Query().Get("lang") != "id" {
+		t.Errorf("existing query lost: %s", link)
+	}
+	if _, _, err := preference.ParseUnsubscribeToken(link.Query().Get("token")); err != nil {
+		t.Errorf("token in link: %v", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cases := []struct {
+		role, event, channel string
+		want                 bool
+	}{
+		{constant.RoleStudent, constant.NotificationPurchase, preference.ChannelEmail, true},
+		{constant.RoleStudent, constant.NotificationMarketing, preference.ChannelInApp, false},
+		{constant.RoleStudent, constant.NotificationNewLesson, preference.ChannelWhatsapp, false},
+		{constant.RoleTeacher, constant.NotificationMarketing, preference.ChannelEmail, false},
+		{"unknown", constant.NotificationGeneral, preference.ChannelInApp, false},
+	}
+	for _, c := range cases {
+		if got := preference.Default(c.role, c.event, c.channel); got != c.want {
+			t.Errorf("%s/%s/%s: got %v", c.role, c.event, c.channel, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := preference.Validate([]preference.Setting{{Event: constant.NotificationRefund, Channel: preference.ChannelWhatsapp}}); err != nil {
+		t.Error(err)
+	}
+	if err := preference.Validate([]preference.Setting{{Event: "nope", Channel: preference.ChannelEmail}}); err == nil {
+		t.Error("expected an unknown event error")
+	}
+	if err := preference.Validate([]preference.Setting{{Event: constant.NotificationRefund, Channel: "sms"}}); err == nil {
+		t.Error("expected an unknown channel error")
+	}
+
+	// only the email of digest events can be batched
+	daily := preference.Setting{Event: constant.NotificationPriceAlert, Channel: preference.ChannelEmail, Enabled: true, Frequency: preference.FrequencyDaily}
+	if err := preference.Validate([]preference.Setting{daily}); err != nil {
+		t.Error(err)
+	}
+	for _, s := range []preference.Setting{
+		{Event: constant.NotificationPriceAlert, Channel: preference.ChannelInApp, Frequency: preference.FrequencyDaily},
+		{Event: constant.NotificationRefund, Channel: preference.ChannelEmail, Frequency: preference.FrequencyDaily},
+		{Event: constant.NotificationPriceAlert, Channel: preference.ChannelEmail, Frequency: "weekly"},
+	} {
+		if err := preference.Validate([]preference.Setting{s}); err == nil {
+			t.Errorf("expected an error for %+v", s)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package preference
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/online-bnsp/backend/util/signedtoken"
+)
+
+var (
+	unsubscribeTokens signedtoken.Signer
+	unsubscribeURL    string
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// SetUnsubscribeConfig set the key signing the unsubscribe tokens and the public url of the unsubscribe endpoint
+func SetUnsubscribeConfig(secret, link string) {
+	unsubscribeTokens = signedtoken.New(secret, "unsubscribe")
+	unsubscribeURL = link
+}
+
+// UnsubscribeToken sign the user and event, the token does not expire so links in old emails keep working
+func UnsubscribeToken(userID int32, event string) string {
+	return unsubscribeTokens.Sign(fmt.Sprintf("%d:%s", userID, event))
+}
+
+// ParseUnsubscribeToken return the user and event of a token made by UnsubscribeToken
+func ParseUnsubscribeToken(token string) (int32, string, error) {
+	payload, err := unsubscribeTokens.Parse(token)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+
+	id, event, ok := strings.Cut(payload, ":")
+	if !ok {
+		return 0, "", ErrInvalidToken
+	}
+	userID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+
+	return int32(userID), event, nil
+}
+
+// UnsubscribeURL is the link put in marketing emails, empty when no url is configured
+func UnsubscribeURL(userID int32, event string) string {
+	if unsubscribeURL == "" {
+		return ""
+	}
+	return signedtoken.Link(unsubscribeURL, UnsubscribeToken(userID, event))
+}
@@ -0,0 +1,187 @@
+package pricealert
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/online-bnsp/backend/constant"
+	repo "github.com/online-bnsp/backend/repo/generated"
+	"github.com/online-bnsp/backend/util"
+	"github.com/online-bnsp/backend/util/inbox"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/mailer"
+	"github.com/online-bnsp/backend/util/notify"
+)
+
+// Alerter tells the users who wishlisted a course that it got cheaper,
+// either because its price was lowered or because a sale started
+type Alerter struct {
+	db        *repo.Queries
+	inbox     *inbox.Inbox
+	publisher *notify.Publisher
+}
+
+func New(db *repo.Queries, inbox *inbox.Inbox, publisher *notify.Publisher) *Alerter {
+	return &Alerter{db, inbox, publisher}
+}
+
+// Check record the effective price changes of the course (every course when courseID is 0)
+// and alert the wishlists of the ones that dropped, it is safe to run concurrently since a
+// change is recorded once and a user is alerted at most once per change
+func (a *Alerter) Check(ctx context.Context, courseID int32) (int, error) {
+	now := time.Now()
+	changes, err := a.db.RecordPriceChanges(ctx, repo.RecordPriceChangesParams{
+		Now:      now,
+		CourseID: sql.NullInt32{Int32: courseID, Valid: courseID != 0},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	alerted := 0
+	for _, ch := range changes {
+		if ch.NewPrice >= ch.OldPrice {
+			continue
+		}
+
+		alerts, err := a.db.CreatePriceDropAlerts(ctx, repo.CreatePriceDropAlertsParams{
+			CreatedAt: now,
+			ChangeID:  ch.ChangeID,
+		})
+		if err != nil {
+			return alerted, err
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+		alerted += len(alerts)
+
+		pending, err := a.db.GetPendingPriceAlerts(ctx, repo.GetPendingPriceAlertsParams{
+			Digest:   false,
+			ChangeID: util.SqlInt32(ch.ChangeID),
+		})
+		if err != nil {
+			return alerted, err
+		}
+		// in-app alerts are never batched, emails of digest users wait for SendDigest
+		for _, d := range pending {
+			a.notify(ctx, d)
+		}
+		a.notifyDigestUsers(ctx, ch, alerts)
+
+		sent := []int32{}
+		for _, d := range pending {
+			_, err := a.publisher.Email(ctx, recipient(d), mailer.TemplatePriceDrop, toMail(d))
+			if err != nil {
+				logger.Err(ctx, err).Msg("error queueing price drop email")
+				continue
+			}
+			sent = append(sent, d.AlertID)
+		}
+		if err := a.markSent(ctx, sent); err != nil {
+			return alerted, err
+		}
+	}
+
+	return alerted, nil
+}
+
+// SendDigest send one email per user with the alerts waiting for the daily digest
+func (a *Alerter) SendDigest(ctx context.Context) (int, error) {
+	pending, err := a.db.GetPendingPriceAlerts(ctx, repo.GetPendingPriceAlertsParams{Digest: true})
+	if err != nil {
+		return 0, err
+	}
+
+	// the rows are ordered by user
+	sent := 0
+	for start := 0; start < len(pending); {
+		end := start
+		for end < len(pending) && pending[end].UserID == pending[start].UserID {
+			end++
+		}
+		batch := pending[start:end]
+		start = end
+
+		digest := mailer.PriceDigestMail{}
+		ids := []int32{}
+		for _, d := range batch {
+			digest.Alerts = append(digest.Alerts, toMail(d))
+			ids = append(ids, d.AlertID)
+		}
+
+		if _, err := a.publisher.Email(ctx, recipient(batch[0]), mailer.TemplatePriceDigest, digest); err != nil {
+			logger.Err(ctx, err).Msg("error queueing price digest email")
+			continue
+		}
+		if err := a.markSent(ctx, ids); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// notifyDigestUsers add the in-app alert of the users whose email waits for the digest
+func (a *Alerter) notifyDigestUsers(ctx context.Context, ch repo.CoursePriceChange, alerts []repo.WishlistPriceAlert) {
+	var course *repo.Course
+	for _, al := range alerts {
+		if !al.Digest {
+			continue
+		}
+		if course == nil {
+			c, err := a.db.GetCourseForUpdate(ctx, ch.CourseID)
+			if err != nil {
+				logger.Err(ctx, err).Msg("error getting course in db")
+				return
+			}
+			course = &c
+		}
+		a.notify(ctx, repo.GetPendingPriceAlertsRow{
+			UserID:     al.UserID,
+			CourseID:   ch.CourseID,
+			CourseName: course.CourseName,
+			OldPrice:   ch.OldPrice,
+			NewPrice:   ch.NewPrice,
+		})
+	}
+}
+
+func (a *Alerter) notify(ctx context.Context, d repo.GetPendingPriceAlertsRow) {
+	_, err := a.inbox.Notify(ctx, d.UserID, inbox.Event{
+		Type:     constant.NotificationPriceAlert,
+		CourseID: d.CourseID,
+		Title:    "A course in your wishlist is cheaper",
+		Message:  fmt.Sprintf("%s is now %s", d.CourseName, util.FormatRupiah(int64(d.NewPrice))),
+		Data:     map[string]any{"old_price": d.OldPrice, "new_price": d.NewPrice},
+	})
+	if err != nil {
+		logger.Err(ctx, err).Msg("error notifying price drop")
+	}
+}
+
+func (a *Alerter) markSent(ctx context.Context, ids []int32) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return a.db.MarkPriceAlertsSent(ctx, repo.MarkPriceAlertsSentParams{
+		SentAt:   util.SqlTime(time.Now()),
+		AlertIds: ids,
+	})
+}
+
+func recipient(d repo.GetPendingPriceAlertsRow) mailer.Recipient {
+	return mailer.Recipient{Email: d.Email, Name: d.Nama, UserID: d.UserID}
+}
+
+func toMail(d repo.GetPendingPriceAlertsRow) mailer.PriceDropMail {
+	return mailer.PriceDropMail{
+		CourseName: d.CourseName,
+		OldPrice:   int64(d.OldPrice),
+		NewPrice:   int64(d.NewPrice),
+		SaleEndsAt: d.SaleEndsAt.Time,
+	}
+}
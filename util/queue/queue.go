@@ -7,6 +7,13 @@ import (
 	"time"
 
 	"github.com/online-bnsp/backend/constant"
+	"github.com/online-bnsp/backend/util/logger"
+	"github.com/online-bnsp/backend/util/metrics"
+	"github.com/online-bnsp/backend/util/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 
 	nsq "github.com/nsqio/go-nsq"
 )
@@ -20,10 +27,11 @@ type Queuer interface {
 
 // Producer publish message to queue
 type Producer interface {
-	Publish(topic string, data interface{}) error
-	DeferredPublish(topic string, data interface{}, delaySecond int) error
-	DeferredPublishWithoutPrefix(topic string, data interface{}, delaySecond int) error
+	Publish(ctx context.Context, topic string, data interface{}) error
+	DeferredPublish(ctx context.Context, topic string, data interface{}, delaySecond int) error
+	DeferredPublishWithoutPrefix(ctx context.Context, topic string, data interface{}, delaySecond int) error
 	Ping() error
+	Stop()
 }
 
 // Consumer consumer message from queue
@@ -75,6 +83,31 @@ type NsqConsumerArgs struct {
 	Name, Topic, Channel, Prefix, NsqLookUpds string
 	MaxInFlight, MaxAttempts, Workers         int
 	HandlerFn                                 ConsumerPayloadHandlerFn
+
+	// Backoff is the delay before the first retry, doubled on every attempt up to MaxBackoff
+	Backoff, MaxBackoff time.Duration
+	// DeadLetter receives the messages that failed MaxAttempts times on `<topic>_dead`, nil drops them
+	DeadLetter Producer
+}
+
+// DeadLetterSuffix is appended to a topic to get its dead-letter topic
+const DeadLetterSuffix = "_dead"
+
+// default retry delays
+const (
+	DefaultBackoff    = 2 * time.Second
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// DeadLetter is published to the dead-letter topic, Body is the original message
+type DeadLetter struct {
+	Topic     string    `json:"topic"`
+	Channel   string    `json:"channel"`
+	MessageID string    `json:"message_id"`
+	Attempts  uint16    `json:"attempts"`
+	Error     string    `json:"error"`
+	Body      []byte    `json:"body"`
+	FailedAt  time.Time `json:"failed_at"`
 }
 
 // ConsumerPayloadHandlerFn is the signature for all payload handlers for NSQ consumers
@@ -83,6 +116,11 @@ type ConsumerPayloadHandlerFn func(context.Context, *nsq.Message) error
 // MessageHandler consumer message handler
 type MessageHandler struct {
 	PayloadHandlerFn ConsumerPayloadHandlerFn
+
+	Topic, Channel      string
+	MaxAttempts         int
+	Backoff, MaxBackoff time.Duration
+	DeadLetter          Producer
 }
 
 // nsqProducer contain producer connection and config
@@ -96,7 +134,7 @@ type nsqProducer struct {
 type nsqConsumer struct {
 	consumer    *nsq.Consumer
 	workers     int
-	handlerFn   ConsumerPayloadHandlerFn
+	handler     *MessageHandler
 	nsqLookUpds string
 }
 
@@ -121,43 +159,47 @@ func (q *Nsq) NewProducer(v interface{}) (Producer, error) {
 	return producer, nil
 }
 
-// Publish message to queue
-func (p *nsqProducer) Publish(topic string, data interface{}) error {
+// Publish message to queue, the trace context of ctx is added to the payload
+func (p *nsqProducer) Publish(ctx context.Context, topic string, data interface{}) error {
+	topicWithPrefix := p.prefix + topic
+
+	ctx, span := startPublish(ctx, topicWithPrefix)
+	defer span.End()
+
 	payload, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
 
-	topicWithPrefix := p.prefix + topic
-
-	err = p.producer.Publish(topicWithPrefix, payload)
+	err = p.producer.Publish(topicWithPrefix, tracing.InjectPayload(ctx, payload))
+	metrics.ObservePublish(topicWithPrefix, err)
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
 
 	return nil
 }
 
 // DeferredPublish message to queue
-func (p *nsqProducer) DeferredPublish(topic string, data interface{}, delaySecond int) error {
+func (p *nsqProducer) DeferredPublish(ctx context.Context, topic string, data interface{}, delaySecond int) error {
 	topicWithPrefix := p.prefix + topic
-	p.deferredPublish(topicWithPrefix, data, delaySecond)
 
-	return nil
+	return p.deferredPublish(ctx, topicWithPrefix, data, delaySecond)
 }
 
 // DeferredPublish message to queue without prefix
-func (p *nsqProducer) DeferredPublishWithoutPrefix(topic string, data interface{}, delaySecond int) error {
-	p.deferredPublish(topic, data, delaySecond)
-
-	return nil
+func (p *nsqProducer) DeferredPublishWithoutPrefix(ctx context.Context, topic string, data interface{}, delaySecond int) error {
+	return p.deferredPublish(ctx, topic, data, delaySecond)
 }
 
 // DeferredPublish message to queue
-func (p *nsqProducer) deferredPublish(topic string, data interface{}, delaySecond int) error {
+func (p *nsqProducer) deferredPublish(ctx context.Context, topic string, data interface{}, delaySecond int) error {
+	ctx, span := startPublish(ctx, topic)
+	defer span.End()
+
 	payload, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
 
 	delay := time.Second * time.Duration(p.delaySecond)
@@ -165,9 +207,10 @@ func (p *nsqProducer) deferredPublish(topic string, data interface{}, delaySecon
 		delay = time.Second * time.Duration(delaySecond)
 	}
 
-	err = p.producer.DeferredPublish(topic, delay, payload)
+	err = p.producer.DeferredPublish(topic, delay, tracing.InjectPayload(ctx, payload))
+	metrics.ObservePublish(topic, err)
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
 
 	return nil
@@ -178,6 +221,11 @@ func (p *nsqProducer) Ping() error {
 	return p.producer.Ping()
 }
 
+// Stop wait for the pending publishes and close the connection, the producer can not be used afterwards
+func (p *nsqProducer) Stop() {
+	p.producer.Stop()
+}
+
 // NewConsumer create new consumer
 func (q *Nsq) NewConsumer(v interface{}) (Consumer, error) {
 	args, ok := v.(NsqConsumerArgs)
@@ -196,9 +244,17 @@ func (q *Nsq) NewConsumer(v interface{}) (Consumer, error) {
 	}
 
 	consumer := &nsqConsumer{
-		consumer:    c,
-		workers:     args.Workers,
-		handlerFn:   args.HandlerFn,
+		consumer: c,
+		workers:  args.Workers,
+		handler: &MessageHandler{
+			PayloadHandlerFn: args.HandlerFn,
+			Topic:            args.Topic,
+			Channel:          args.Channel,
+			MaxAttempts:      args.MaxAttempts,
+			Backoff:          args.Backoff,
+			MaxBackoff:       args.MaxBackoff,
+			DeadLetter:       args.DeadLetter,
+		},
 		nsqLookUpds: args.NsqLookUpds,
 	}
 
@@ -207,12 +263,7 @@ func (q *Nsq) NewConsumer(v interface{}) (Consumer, error) {
 
 // Run assign handlers for consumer and run it
 func (c *nsqConsumer) Run() error {
-	c.consumer.AddConcurrentHandlers(
-		&MessageHandler{
-			PayloadHandlerFn: c.handlerFn,
-		},
-		c.workers,
-	)
+	c.consumer.AddConcurrentHandlers(c.handler, c.workers)
 
 	if err := c.consumer.ConnectToNSQLookupds([]string{c.nsqLookUpds}); err != nil {
 		return err
@@ -232,21 +283,116 @@ func (h *MessageHandler) HandleMessage(m *nsq.Message) error {
 
 	t := time.Now()
 
-	ctx := context.Background()
+	// continues the trace of the publisher
+	ctx := tracing.ExtractPayload(context.Background(), m.Body)
+	ctx, span := tracing.Tracer().Start(ctx, h.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nsq"),
+			semconv.MessagingDestinationName(h.Topic),
+			semconv.MessagingMessageID(string(m.ID[:])),
+			attribute.String("messaging.nsq.channel", h.Channel),
+			attribute.Int("messaging.nsq.attempts", int(m.Attempts)),
+		),
+	)
+	defer span.End()
+
 	ctx = context.WithValue(ctx, constant.ContextMessageID, string(m.ID[:]))
 	ctx = context.WithValue(ctx, constant.ContextBirthTime, t)
 
+	l := logger.FromContext(ctx).With().
+		Str("topic", h.Topic).
+		Str("channel", h.Channel).
+		Str("message_id", string(m.ID[:])).
+		Uint16("attempts", m.Attempts).
+		Logger()
+	if traceID, _ := tracing.IDs(ctx); traceID != "" {
+		l = l.With().Str("trace_id", traceID).Logger()
+	}
+	ctx = logger.WithContext(ctx, &l)
+
 	err := h.PayloadHandlerFn(ctx, m)
+	elapsed := time.Since(t).Seconds()
 	if err != nil {
-		m.Requeue(2 * time.Second)
+		spanError(span, err)
+		if h.MaxAttempts > 0 && int(m.Attempts) >= h.MaxAttempts && h.deadLetter(ctx, m, err) == nil {
+			metrics.ObserveConsume(h.Topic, h.Channel, metrics.ResultDeadLetter, elapsed)
+			m.Finish()
+			return err
+		}
+
+		// only this message waits, the consumer keeps its pace for the others
+		metrics.ObserveConsume(h.Topic, h.Channel, metrics.ResultRetry, elapsed)
+		m.RequeueWithoutBackoff(RetryDelay(m.Attempts, h.Backoff, h.MaxBackoff))
 		return err
 	}
 
+	metrics.ObserveConsume(h.Topic, h.Channel, metrics.ResultSuccess, elapsed)
 	m.Finish()
 
 	return nil
 }
 
+// LogFailedMessage is called by nsq when a message is dropped after MaxAttempts,
+// which only happens when it could not be dead-lettered in HandleMessage
+func (h *MessageHandler) LogFailedMessage(m *nsq.Message) {
+	h.deadLetter(context.Background(), m, errors.New("max attempts exceeded"))
+}
+
+func (h *MessageHandler) deadLetter(ctx context.Context, m *nsq.Message, cause error) error {
+	if h.DeadLetter == nil {
+		return nil
+	}
+
+	return h.DeadLetter.Publish(ctx, h.Topic+DeadLetterSuffix, DeadLetter{
+		Topic:     h.Topic,
+		Channel:   h.Channel,
+		MessageID: string(m.ID[:]),
+		Attempts:  m.Attempts,
+		Error:     cause.Error(),
+		Body:      m.Body,
+		FailedAt:  time.Now(),
+	})
+}
+
+func startPublish(ctx context.Context, topic string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nsq"),
+			semconv.MessagingDestinationName(topic),
+		),
+	)
+}
+
+func spanError(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+// RetryDelay returns the exponential backoff before the given attempt is retried
+func RetryDelay(attempt uint16, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = DefaultBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	delay := base
+	for i := uint16(1); i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
 // Setup set queue address
 func (q *Nsq) setup(address string) {
 	q.Address = address
@@ -0,0 +1,31 @@
+package queue_test
+
+import (
+	"testing"
+	"time"
+
+	queue "github.com/online-bnsp/backend/util/queue"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt   uint16
+		base, max time.Duration
+		want      time.Duration
+	}{
+		{1, time.Second, time.Minute, time.Second},
+		{2, time.Second, time.Minute, 2 * time.Second},
+		{4, time.Second, time.Minute, 8 * time.Second},
+		{7, time.Second, time.Minute, time.Minute},
+		{60000, time.Second, time.Minute, time.Minute},
+		{0, time.Second, time.Minute, time.Second},
+		{3, 0, 0, 4 * queue.DefaultBackoff},
+		{1, time.Hour, time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := queue.RetryDelay(tt.attempt, tt.base, tt.max); got != tt.want {
+			t.Errorf("RetryDelay(%d, %v, %v) = %v, want %v", tt.attempt, tt.base, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies are the load balancers and reverse proxies in front of the api, only their
+// X-Forwarded-For and X-Real-IP headers are believed
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies set the ips and cidrs of the proxies in front of the api, without any the
+// forwarding headers are ignored and the caller is the peer of the connection
+func SetTrustedProxies(entries []string) error {
+	nets, err := ParseAllowlist(entries)
+	if err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+// ClientIP is the address of the caller, without its port. Behind a trusted proxy it is the
+// right most X-Forwarded-For hop that is not a trusted proxy, or X-Real-IP without the header,
+// the hops left of it are written by the client and can be forged
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host) {
+		return host
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return host // garbled by the client, the proxy is the last address known for sure
+		}
+		if !trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return host
+}
+
+func trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
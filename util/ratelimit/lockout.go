@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutPolicy lock a key once it failed MaxFailures times within Window. The lock lasts Base,
+// doubled on every further failure up to Max
+type LockoutPolicy struct {
+	Name        string
+	MaxFailures int
+	Window      time.Duration
+	Base        time.Duration
+	Max         time.Duration
+}
+
+func (p LockoutPolicy) enabled() bool {
+	return p.MaxFailures > 0 && p.Window > 0 && p.Base > 0
+}
+
+// the failures are kept for a window after the last lock, so failing again right after it
+// expired locks for longer
+var failScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local max_failures = tonumber(ARGV[2])
+local base = tonumber(ARGV[3])
+local max_lock = tonumber(ARGV[4])
+
+local failures = redis.call("INCR", KEYS[1])
+if failures < max_failures then
+	if failures == 1 then
+		redis.call("PEXPIRE", KEYS[1], window)
+	end
+	return 0
+end
+
+local lock = base * 2 ^ math.min(failures - max_failures, 30)
+if max_lock > 0 and lock > max_lock then
+	lock = max_lock
+end
+lock = math.floor(lock)
+redis.call("SET", KEYS[2], failures, "PX", lock)
+redis.call("PEXPIRE", KEYS[1], lock + window)
+return lock`)
+
+func (l *Limiter) lockoutKeys(p LockoutPolicy, key string) (failures, lock string) {
+	return "lockout:" + p.Name + ":failures:" + key, "lockout:" + p.Name + ":lock:" + key
+}
+
+// Locked return how long key stays locked, zero when it is not
+func (l *Limiter) Locked(ctx context.Context, p LockoutPolicy, key string) (time.Duration, error) {
+	if !p.enabled() {
+		return 0, nil
+	}
+	_, lock := l.lockoutKeys(p, key)
+	ttl, err := l.rdb.PTTL(ctx, lock).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// Fail count a failure of key, it returns how long key is now locked
+func (l *Limiter) Fail(ctx context.Context, p LockoutPolicy, key string) (time.Duration, error) {
+	if !p.enabled() {
+		return 0, nil
+	}
+	failures, lock := l.lockoutKeys(p, key)
+	ms, err := failScript.Run(ctx, l.rdb, []string{failures, lock},
+		p.Window.Milliseconds(), p.MaxFailures, p.Base.Milliseconds(), p.Max.Milliseconds()).Int64()
+	return time.Duration(ms) * time.Millisecond, err
+}
+
+// Reset forget the failures of key and unlock it
+func (l *Limiter) Reset(ctx context.Context, p LockoutPolicy, key string) error {
+	if !p.enabled() {
+		return nil
+	}
+	failures, lock := l.lockoutKeys(p, key)
+	return l.rdb.Del(ctx, failures, lock).Err()
+}
+
+// LoginGuard lock out the accounts and the addresses that keep failing to log in. Addresses are
+// shared by many users behind a NAT, their policy should allow more failures than the account one.
+// A nil guard never locks
+type LoginGuard struct {
+	limiter          *Limiter
+	account, address LockoutPolicy
+}
+
+func NewLoginGuard(l *Limiter, account, address LockoutPolicy) *LoginGuard {
+	if l == nil {
+		return nil
+	}
+	account.Name, address.Name = "login-account", "login-address"
+	return &LoginGuard{limiter: l, account: account, address: address}
+}
+
+// Locked return how long the account or the address of the caller stays locked
+func (g *LoginGuard) Locked(ctx context.Context, account, ip string) (time.Duration, error) {
+	if g == nil {
+		return 0, nil
+	}
+	wait, err := g.limiter.Locked(ctx, g.account, account)
+	if err != nil {
+		return 0, err
+	}
+	if g.limiter.Allowlisted(ip) {
+		return wait, nil
+	}
+	addressWait, err := g.limiter.Locked(ctx, g.address, ip)
+	return longest(wait, addressWait), err
+}
+
+// Failed count a failed login, it returns how long the caller is now locked out
+func (g *LoginGuard) Failed(ctx context.Context, account, ip string) (time.Duration, error) {
+	if g == nil {
+		return 0, nil
+	}
+	wait, err := g.limiter.Fail(ctx, g.account, account)
+	if err != nil {
+		return 0, err
+	}
+	if g.limiter.Allowlisted(ip) {
+		return wait, nil
+	}
+	addressWait, err := g.limiter.Fail(ctx, g.address, ip)
+	return longest(wait, addressWait), err
+}
+
+// Succeeded forget the failures of the account, those of the address are kept so an attacker
+// can not reset them by logging into their own account
+func (g *LoginGuard) Succeeded(ctx context.Context, account string) error {
+	if g == nil {
+		return nil
+	}
+	return g.limiter.Reset(ctx, g.account, account)
+}
+
+func longest(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
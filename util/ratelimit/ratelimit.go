@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy allows Limit requests per key in any Window long period
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+func (p Policy) enabled() bool {
+	return p.Limit > 0 && p.Window > 0
+}
+
+// Result of a request against a policy
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the oldest request counted leaves the window, a denied request can be
+	// retried then
+	Reset time.Duration
+}
+
+// the requests are kept in a sorted set scored by their time, those older than the window are
+// dropped before counting so the window slides with every request
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", KEYS[1], window)
+
+local reset = window
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}`)
+
+// Limiter count the requests in redis, so the limits hold across the replicas of the api
+type Limiter struct {
+	rdb       *redis.Client
+	allowlist []*net.IPNet
+	now       func() time.Time
+}
+
+// New create a limiter, the callers in allowlist are never limited
+func New(rdb *redis.Client, allowlist []*net.IPNet) *Limiter {
+	return &Limiter{rdb: rdb, allowlist: allowlist, now: time.Now}
+}
+
+// ParseAllowlist parse ips and cidrs, e.g. 127.0.0.1 or 10.0.0.0/8
+func ParseAllowlist(entries []string) ([]*net.IPNet, error) {
+	var allowlist []*net.IPNet
+	for _, s := range entries {
+		cidr := s
+		if ip := net.ParseIP(s); ip != nil {
+			cidr = ip.String() + "/128"
+			if ip.To4() != nil {
+				cidr = ip.String() + "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %w", s, err)
+		}
+		allowlist = append(allowlist, n)
+	}
+	return allowlist, nil
+}
+
+// SetClock replace time.Now, for tests
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// Allowlisted tell whether the caller at ip is not limited
+func (l *Limiter) Allowlisted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range l.allowlist {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow count a request of key against p
+func (l *Limiter) Allow(ctx context.Context, p Policy, key string) (Result, error) {
+	if !p.enabled() {
+		return Result{Allowed: true}, nil
+	}
+	res, err := slidingWindowScript.Run(ctx, l.rdb, []string{"ratelimit:" + p.Name + ":" + key},
+		l.now().UnixMilli(), p.Window.Milliseconds(), p.Limit, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+	return Result{
+		Allowed:   res[0] == 1,
+		Limit:     p.Limit,
+		Remaining: p.Limit - int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
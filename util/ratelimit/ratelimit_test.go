@@ -0,0 +1,174 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/online-bnsp/backend/util/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+func setup(t *testing.T, allowlist ...string) (*ratelimit.Limiter, *miniredis.Miniredis, *time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	nets, err := ratelimit.ParseAllowlist(allowlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := ratelimit.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nets)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.SetClock(func() time.Time { return now })
+	return l, mr, &now
+}
+
+func TestAllowSlidingWindow(t *testing.T) {
+	l, _, now := setup(t)
+	ctx := context.Background()
+	p := ratelimit.Policy{Name: "test", Limit: 2, Window: time.Minute}
+
+	for i, want := range []bool{true, true, false} {
+		res, err := l.Allow(ctx, p, "ip:10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != want {
+			t.Fatalf("request %d: got allowed %v", i, res.Allowed)
+		}
+		*now = now.Add(10 * time.Second)
+	}
+
+	// the first request leaves the window 60s after it was made
+	res, _ := l.Allow(ctx, p, "ip:10.0.0.1")
+	if res.Allowed || res.Remaining != 0 || res.Reset != 30*time.Second {
+		t.Errorf("got %+v", res)
+	}
+	*now = now.Add(30 * time.Second)
+	if res, _ := l.Allow(ctx, p, "ip:10.0.0.1"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("got %+v", res)
+	}
+
+	// other keys have their own window
+	if res, _ := l.Allow(ctx, p, "ip:10.0.0.2"); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	if _, err := ratelimit.ParseAllowlist([]string{"localhost"}); err == nil {
+		t.Error("expected an invalid entry")
+	}
+
+	l, _, _ := setup(t, "127.0.0.1", "10.0.0.0/8", "::1")
+	for ip, want := range map[string]bool{
+		"127.0.0.1":   true,
+		"10.20.30.40": true,
+		"::1":         true,
+		"192.168.1.1": false,
+		"":            false,
+	} {
+		if got := l.Allowlisted(ip); got != want {
+			t.Errorf("%q: got %v", ip, got)
+		}
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	l, mr, _ := setup(t, "10.0.0.0/8")
+	ctx := context.Background()
+	account := ratelimit.LockoutPolicy{MaxFailures: 3, Window: 15 * time.Minute, Base: time.Minute, Max: 3 * time.Minute}
+	address := ratelimit.LockoutPolicy{MaxFailures: 5, Window: 15 * time.Minute, Base: time.Minute, Max: time.Hour}
+	g := ratelimit.NewLoginGuard(l, account, address)
+
+	// the lock doubles on every failure past the limit, up to max
+	for i, want := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		wait, err := g.Failed(ctx, "budi", "192.168.1.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != want {
+			t.Errorf("failure %d: locked for %v, want %v", i+1, wait, want)
+		}
+	}
+	if wait, _ := g.Locked(ctx, "budi", "192.168.1.2"); wait != 3*time.Minute {
+		t.Errorf("account locked for %v", wait)
+	}
+	// the address failed 5 times too, whichever account it tries
+	if wait, _ := g.Locked(ctx, "siti", "192.168.1.1"); wait != time.Minute {
+		t.Errorf("address locked for %v", wait)
+	}
+
+	mr.FastForward(3 * time.Minute)
+	if wait, _ := g.Locked(ctx, "budi", "192.168.1.2"); wait != 0 {
+		t.Errorf("account still locked for %v", wait)
+	}
+	// the failures are remembered past the lock
+	if wait, _ := g.Failed(ctx, "budi", "192.168.1.2"); wait != 3*time.Minute {
+		t.Errorf("locked for %v", wait)
+	}
+
+	if err := g.Succeeded(ctx, "budi"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := g.Locked(ctx, "budi", "192.168.1.2"); wait != 0 {
+		t.Errorf("account still locked for %v after a login", wait)
+	}
+
+	// internal callers only count against the account
+	for i := 0; i < 10; i++ {
+		g.Failed(ctx, "andi", "10.1.1.1")
+	}
+	if wait, _ := g.Locked(ctx, "other", "10.1.1.1"); wait != 0 {
+		t.Errorf("allowlisted address locked for %v", wait)
+	}
+
+	// a nil guard never locks
+	var disabled *ratelimit.LoginGuard
+	if wait, err := disabled.Failed(ctx, "budi", "192.168.1.1"); wait != 0 || err != nil {
+		t.Errorf("got %v %v", wait, err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	if err := ratelimit.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	defer ratelimit.SetTrustedProxies(nil)
+
+	tests := []struct {
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"203.0.113.7:4000", nil, "", "203.0.113.7"},
+		// only a trusted proxy can forward the address
+		{"203.0.113.7:4000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7"},
+		{"10.0.0.2:4000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		// the hops left of the first untrusted one are written by the client
+		{"10.0.0.2:4000", []string{"1.2.3.4, 198.51.100.1, 10.0.0.3"}, "", "198.51.100.1"},
+		{"10.0.0.2:4000", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		{"10.0.0.2:4000", []string{"garbage, 10.0.0.3"}, "", "10.0.0.3"},
+		{"10.0.0.2:4000", nil, "198.51.100.2", "198.51.100.2"},
+		{"10.0.0.2:4000", nil, "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := ratelimit.ClientIP(r); got != tt.want {
+			t.Errorf("%s %v %q: got %s, want %s", tt.remote, tt.forwarded, tt.realIP, got, tt.want)
+		}
+	}
+
+	if err := ratelimit.SetTrustedProxies([]string{"proxy"}); err == nil {
+		t.Error("expected an invalid entry")
+	}
+}
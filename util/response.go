@@ -47,12 +47,16 @@ func NewResponse(code, status int, msg string, data interface{}, username ...str
 
 // WriteResponse - write response to the client
 func (resp *Response) WriteResponse(w http.ResponseWriter, r *http.Request) {
-	birthTime := r.Context().Value(constant.ContextBirthTime).(time.Time)
-	latency := time.Since(birthTime).Seconds() * 1000
-	resp.Meta.Latency = fmt.Sprintf("%.2f ms", latency)
+	// errors may be written by middlewares running before BirthTime, or for a request whose
+	// id was sent by the client
+	if birthTime, ok := r.Context().Value(constant.ContextBirthTime).(time.Time); ok {
+		latency := time.Since(birthTime).Seconds() * 1000
+		resp.Meta.Latency = fmt.Sprintf("%.2f ms", latency)
+	}
 
-	requestID := r.Context().Value(middleware.RequestIDKey).(uuid.UUID)
-	resp.RequestID = requestID
+	if requestID, ok := r.Context().Value(middleware.RequestIDKey).(uuid.UUID); ok {
+		resp.RequestID = requestID
+	}
 
 	// return ctx.JSON(r.Status, r)
 	responseJSON, err := json.Marshal(resp)
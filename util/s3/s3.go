@@ -1,17 +1,33 @@
 package s3
 
 import (
+	"context"
 	"io"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 type Bucket interface {
 	Connect() error
 	Upload(filename string, file io.Reader) (string, error)
+	ObjectKey(location string) (string, bool)
+	List() ([]Object, error)
+	Delete(key string) error
+	Copy(src, dst string) error
+	Head(ctx context.Context) error
+}
+
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
 }
 
 type s3 struct {
@@ -70,3 +86,69 @@ func (b *s3) Upload(filename string, file io.Reader) (string, error) {
 
 	return up.Location, nil
 }
+
+// ObjectKey extract object key from the location returned by Upload,
+// supports both path-style and virtual-hosted-style urls
+func (b *s3) ObjectKey(location string) (string, bool) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if strings.HasPrefix(u.Host, b.bucketName+".") {
+		return key, key != ""
+	}
+	if strings.HasPrefix(key, b.bucketName+"/") {
+		key = strings.TrimPrefix(key, b.bucketName+"/")
+		return key, key != ""
+	}
+
+	return "", false
+}
+
+func (b *s3) List() ([]Object, error) {
+	var objects []Object
+	err := awss3.New(b.session).ListObjectsV2Pages(&awss3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucketName),
+	}, func(page *awss3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(o.Key),
+				Size:         aws.Int64Value(o.Size),
+				LastModified: aws.TimeValue(o.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+func (b *s3) Delete(key string) error {
+	_, err := awss3.New(b.session).DeleteObject(&awss3.DeleteObjectInput{
+		Bucket: aws.String(b.bucketName),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *s3) Copy(src, dst string) error {
+	_, err := awss3.New(b.session).CopyObject(&awss3.CopyObjectInput{
+		Bucket:     aws.String(b.bucketName),
+		CopySource: aws.String(url.PathEscape(b.bucketName + "/" + src)),
+		Key:        aws.String(dst),
+	})
+	return err
+}
+
+// Head check that the bucket exists and is accessible
+func (b *s3) Head(ctx context.Context) error {
+	_, err := awss3.New(b.session).HeadBucketWithContext(ctx, &awss3.HeadBucketInput{
+		Bucket: aws.String(b.bucketName),
+	})
+	return err
+}
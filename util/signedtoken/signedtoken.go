@@ -0,0 +1,66 @@
+package signedtoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid signed token")
+
+// Signer make tokens for one purpose, the purpose is signed with the payload so a token
+// made for one link is refused by the others sharing the key
+type Signer struct {
+	purpose string
+	key     []byte
+}
+
+func New(key, purpose string) Signer {
+	return Signer{purpose: purpose, key: []byte(key)}
+}
+
+// Sign the payload, the token does not expire and is safe to put in an url
+func (s Signer) Sign(payload string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// Parse return the payload of a token made by Sign with the same key and purpose
+func (s Signer) Parse(token string) (string, error) {
+	encoded, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(sig, s.mac(string(payload))) {
+		return "", ErrInvalid
+	}
+
+	return string(payload), nil
+}
+
+func (s Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(s.purpose + ":" + payload))
+	return h.Sum(nil)
+}
+
+// Link add the token to the query of base, empty when base is not configured
+func Link(base, token string) string {
+	if base == "" {
+		return ""
+	}
+
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,40 @@
+package signedtoken_test
+
+import (
+	"testing"
+
+	"github.com/online-bnsp/backend/util/signedtoken"
+)
+
+func TestSigner(t *testing.T) {
+	cart := signedtoken.New("secret", "cart")
+	token := cart.Sign("7")
+	if payload, err := cart.Parse(token); err != nil || payload != "7" {
+		t.Fatalf("got %q %v", payload, err)
+	}
+
+	// the same key signs the tokens of other purposes
+	for _, s := range []signedtoken.Signer{signedtoken.New("secret", "unsubscribe"), signedtoken.New("other", "cart")} {
+		if _, err := s.Parse(token); err != signedtoken.ErrInvalid {
+			t.Errorf("got %v, want ErrInvalid", err)
+		}
+	}
+
+	for _, tok := range []string{"", "garbage", token + "x", "Nw." + token} {
+		if _, err := cart.Parse(tok); err != signedtoken.ErrInvalid {
+			t.Errorf("%q: got %v, want ErrInvalid", tok, err)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                          "",
+		"https://example.com/u":     "https://example.com/u?token=a%2Bb",
+		"https://example.com/u?x=1": "https://example.com/u?x=1&token=a%2Bb",
+	} {
+		if got := signedtoken.Link(base, "a+b"); got != want {
+			t.Errorf("%q: got %q, want %q", base, got, want)
+		}
+	}
+}
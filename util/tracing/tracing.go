@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/online-bnsp/backend"
+
+// exporters
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config of the tracer provider, Endpoint is the host:port of the OTLP/HTTP collector
+// and SampleRatio the share of the new traces that are recorded
+type Config struct {
+	Service     string
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// Setup install the global tracer provider and the W3C trace context propagator, the
+// returned function flushes the pending spans. Without an exporter nothing is recorded
+// but the trace context received from upstream is still passed on
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter `%s`", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.Service)))
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// a sampled parent, e.g. from the frontend or the publisher of a message, is always followed
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// Tracer of the application, it follows the provider installed by Setup
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// IDs returns the trace and span id of the span in ctx, empty when there is none
+func IDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+// payloadKey is the field of the queue messages carrying the trace context
+const payloadKey = "trace_context"
+
+// InjectPayload add the trace context of ctx to a json object, other payloads are returned as is.
+// The consumers decode the messages into structs, so the extra field is ignored by them
+func InjectPayload(ctx context.Context, payload []byte) []byte {
+	if len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return payload
+	}
+	raw, err := json.Marshal(carrier)
+	if err != nil {
+		return payload
+	}
+
+	// payloads come from json.Marshal, so there is no whitespace to expect
+	out := append([]byte(`{"`+payloadKey+`":`), raw...)
+	if string(payload) != "{}" {
+		out = append(out, ',')
+	}
+	return append(out, payload[1:]...)
+}
+
+// ExtractPayload returns ctx with the remote span of a payload made by InjectPayload
+func ExtractPayload(ctx context.Context, payload []byte) context.Context {
+	var msg struct {
+		TraceContext propagation.MapCarrier `json:"trace_context"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil || len(msg.TraceContext) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, msg.TraceContext)
+}
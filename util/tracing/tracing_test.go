@@ -0,0 +1,97 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/online-bnsp/backend/util/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setup(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestPayloadPropagation(t *testing.T) {
+	setup(t)
+	ctx, span := tracing.Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	payload := tracing.InjectPayload(ctx, []byte(`{"job_id":7}`))
+
+	// consumers decode into their own struct and do not see the trace context
+	var msg struct {
+		JobID int32 `json:"job_id"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.JobID != 7 {
+		t.Fatalf("payload broken: %s (%v)", payload, err)
+	}
+
+	remote := trace.SpanContextFromContext(tracing.ExtractPayload(context.Background(), payload))
+	if remote.TraceID() != span.SpanContext().TraceID() || remote.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("got %v, want the span of the publisher", remote)
+	}
+
+	if got := tracing.InjectPayload(ctx, []byte(`{}`)); !json.Valid(got) {
+		t.Errorf("invalid payload %s", got)
+	}
+	// only objects carry a trace context
+	if got := tracing.InjectPayload(ctx, []byte(`[1,2]`)); string(got) != `[1,2]` {
+		t.Errorf("got %s", got)
+	}
+	// without a span there is nothing to add
+	if got := tracing.InjectPayload(context.Background(), []byte(`{"a":1}`)); string(got) != `{"a":1}` {
+		t.Errorf("got %s", got)
+	}
+}
+
+type fakeDB struct {
+	err error
+}
+
+func (f fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestDBSpans(t *testing.T) {
+	recorder := setup(t)
+	ctx := context.Background()
+
+	tracing.WrapDB(fakeDB{}).ExecContext(ctx, "-- name: DeleteCart :exec\nDELETE FROM cart WHERE user_id = $1", 1)
+	tracing.WrapDB(fakeDB{errors.New("deadlock detected")}).QueryContext(ctx, "SELECT 1")
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	if spans[0].Name() != "DeleteCart" || spans[0].Status().Code == codes.Error {
+		t.Errorf("got %s %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "SELECT" || spans[1].Status().Code != codes.Error {
+		t.Errorf("got %s %v", spans[1].Name(), spans[1].Status())
+	}
+}